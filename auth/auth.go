@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+)
+
+// Ways a principal can be authenticated.
+const (
+	MethodSession = "session"
+	MethodJWT     = "jwt"
+	MethodProxy   = "proxy"
+	MethodToken   = "token"
+)
+
+// Principal is the verified identity behind a request. The zero value is an
+// anonymous request. Scopes is nil unless a personal access token limited
+// what the request may do.
+type Principal struct {
+	UserID  string
+	Method  string
+	TokenID string
+	Scopes  []string
+}
+
+func (p Principal) Anonymous() bool {
+	return p.UserID == ""
+}
+
+// Authenticator verifies bearer tokens, session cookies and, from trusted
+// proxies, the proxy header.
+type Authenticator struct {
+	config    config.Auth
+	tokens    model.TokenStore
+	publicKey *rsa.PublicKey
+	proxies   []*net.IPNet
+}
+
+// New builds an Authenticator. tokens looks up personal access tokens and
+// may be nil to reject them.
+func New(c config.Auth, tokens model.TokenStore) (*Authenticator, error) {
+	a := &Authenticator{config: c, tokens: tokens}
+	if c.JWTPublicKey != "" {
+		data, err := ioutil.ReadFile(c.JWTPublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %v", err)
+		}
+		a.publicKey, err = jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("auth: %s: %v", c.JWTPublicKey, err)
+		}
+	}
+	for _, proxy := range c.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("auth: trusted proxy %q: %v", proxy, err)
+		}
+		a.proxies = append(a.proxies, network)
+	}
+	return a, nil
+}
+
+// Authenticate returns who sent req. A bearer token that fails to verify is
+// an error; a bad session cookie or an untrusted proxy header is ignored
+// and the request stays anonymous.
+func (a *Authenticator) Authenticate(req *http.Request) (Principal, error) {
+	header := req.Header.Get("Authorization")
+	if header != "" {
+		token := strings.TrimPrefix(header, "Bearer ")
+		if token == header {
+			return Principal{}, model.Unauthorized("invalid_token", "authorization must be a bearer token")
+		}
+		if isToken(token) {
+			return a.verifyToken(token)
+		}
+		userID, err := a.verifyJWT(token)
+		if err != nil {
+			return Principal{}, err
+		}
+		return Principal{UserID: userID, Method: MethodJWT}, nil
+	}
+
+	if cookie, err := req.Cookie(a.config.SessionCookie); err == nil && a.config.SessionSecret != "" {
+		userID, ok := a.verifySession(cookie.Value)
+		if ok {
+			return Principal{UserID: userID, Method: MethodSession}, nil
+		}
+	}
+
+	if userID := req.Header.Get(a.config.ProxyHeader); userID != "" && a.trusted(req.RemoteAddr) {
+		return Principal{UserID: userID, Method: MethodProxy}, nil
+	}
+	return Principal{}, nil
+}
+
+func (a *Authenticator) verifyJWT(token string) (string, error) {
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		switch t.Method.(type) {
+		case *jwt.SigningMethodHMAC:
+			if a.config.JWTSecret != "" {
+				return []byte(a.config.JWTSecret), nil
+			}
+		case *jwt.SigningMethodRSA:
+			if a.publicKey != nil {
+				return a.publicKey, nil
+			}
+		}
+		return nil, fmt.Errorf("unexpected signing method %s", t.Header["alg"])
+	})
+	if err != nil {
+		return "", model.Unauthorized("invalid_token", "bearer token is invalid: "+err.Error())
+	}
+	if claims.Subject == "" {
+		return "", model.Unauthorized("invalid_token", "bearer token has no subject")
+	}
+	if a.config.JWTIssuer != "" && claims.Issuer != a.config.JWTIssuer {
+		return "", model.Unauthorized("invalid_token", "bearer token has the wrong issuer")
+	}
+	if a.config.JWTAudience != "" && !claims.VerifyAudience(a.config.JWTAudience, true) {
+		return "", model.Unauthorized("invalid_token", "bearer token has the wrong audience")
+	}
+	return claims.Subject, nil
+}
+
+func (a *Authenticator) trusted(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range a.proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/n-inja/go-blog/config"
+	"golang.org/x/oauth2"
+)
+
+// Identity is the user an OAuth provider vouches for. Subject is the
+// provider's stable ID; Login is only a suggestion for a new user ID.
+type Identity struct {
+	Provider  string
+	Subject   string
+	Login     string
+	Name      string
+	AvatarURL string
+}
+
+// Provider is an OAuth2 login. GitHub and OpenID Connect are built in;
+// other providers only need to implement this.
+type Provider interface {
+	Name() string
+	// AuthCodeURL is where the browser goes to sign in. redirectURL must
+	// match the one passed to Identify.
+	AuthCodeURL(state, redirectURL string) string
+	// Identify exchanges the code from the callback for the identity.
+	Identify(ctx context.Context, code, redirectURL string) (Identity, error)
+}
+
+// Providers builds the providers enabled in c, by name.
+func Providers(c config.Auth) map[string]Provider {
+	providers := map[string]Provider{}
+	if c.GitHub.ClientID != "" {
+		providers["github"] = NewGitHub(c.GitHub)
+	}
+	for _, oidc := range c.OIDC {
+		providers[oidc.Name] = NewOIDC(oidc)
+	}
+	return providers
+}
+
+// NewState returns a random value binding a callback to the browser that
+// started the login.
+func NewState() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+type oauthProvider struct {
+	name    string
+	config  oauth2.Config
+	userURL string
+	decode  func(data []byte) (Identity, error)
+}
+
+func newProvider(c config.OAuth, name string, scopes []string) *oauthProvider {
+	if len(c.Scopes) > 0 {
+		scopes = c.Scopes
+	}
+	return &oauthProvider{
+		name: name,
+		config: oauth2.Config{
+			ClientID:     c.ClientID,
+			ClientSecret: c.ClientSecret,
+			Endpoint:     oauth2.Endpoint{AuthURL: c.AuthURL, TokenURL: c.TokenURL},
+			Scopes:       scopes,
+		},
+		userURL: c.UserURL,
+	}
+}
+
+// NewGitHub logs in with a GitHub OAuth app. The login becomes github_id.
+func NewGitHub(c config.OAuth) Provider {
+	p := newProvider(c, "github", []string{"read:user"})
+	p.decode = func(data []byte) (Identity, error) {
+		var user struct {
+			ID        int64  `json:"id"`
+			Login     string `json:"login"`
+			Name      string `json:"name"`
+			AvatarURL string `json:"avatar_url"`
+		}
+		err := json.Unmarshal(data, &user)
+		if err != nil || user.ID == 0 {
+			return Identity{}, fmt.Errorf("github: unexpected user response")
+		}
+		return Identity{Subject: strconv.FormatInt(user.ID, 10), Login: user.Login, Name: user.Name, AvatarURL: user.AvatarURL}, nil
+	}
+	return p
+}
+
+// NewOIDC logs in with an OpenID Connect provider, reading the user from
+// its userinfo endpoint.
+func NewOIDC(c config.OAuth) Provider {
+	p := newProvider(c, c.Name, []string{"openid", "profile"})
+	p.decode = func(data []byte) (Identity, error) {
+		var user struct {
+			Subject           string `json:"sub"`
+			PreferredUsername string `json:"preferred_username"`
+			Name              string `json:"name"`
+			Picture           string `json:"picture"`
+		}
+		err := json.Unmarshal(data, &user)
+		if err != nil || user.Subject == "" {
+			return Identity{}, fmt.Errorf("%s: unexpected userinfo response", c.Name)
+		}
+		return Identity{Subject: user.Subject, Login: user.PreferredUsername, Name: user.Name, AvatarURL: user.Picture}, nil
+	}
+	return p
+}
+
+func (p *oauthProvider) Name() string {
+	return p.name
+}
+
+func (p *oauthProvider) AuthCodeURL(state, redirectURL string) string {
+	c := p.config
+	c.RedirectURL = redirectURL
+	return c.AuthCodeURL(state)
+}
+
+func (p *oauthProvider) Identify(ctx context.Context, code, redirectURL string) (Identity, error) {
+	c := p.config
+	c.RedirectURL = redirectURL
+	token, err := c.Exchange(ctx, code)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %v", p.name, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", p.userURL, nil)
+	if err != nil {
+		return Identity{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	res, err := c.Client(ctx, token).Do(req)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %v", p.name, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Identity{}, fmt.Errorf("%s: user endpoint returned %s", p.name, res.Status)
+	}
+	var data json.RawMessage
+	err = json.NewDecoder(res.Body).Decode(&data)
+	if err != nil {
+		return Identity{}, fmt.Errorf("%s: %v", p.name, err)
+	}
+	identity, err := p.decode(data)
+	if err != nil {
+		return Identity{}, err
+	}
+	identity.Provider = p.name
+	return identity, nil
+}
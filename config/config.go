@@ -0,0 +1,585 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+type Config struct {
+	Port            string        `yaml:"port"`
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	Store           string        `yaml:"store"`
+	Database        Database      `yaml:"database"`
+	Site            Site          `yaml:"site"`
+	Routes          Routes        `yaml:"routes"`
+	Purge           Purge         `yaml:"purge"`
+	Publish         Publish       `yaml:"publish"`
+	Render          Render        `yaml:"render"`
+	Search          Search        `yaml:"search"`
+	Auth            Auth          `yaml:"auth"`
+	RateLimit       RateLimit     `yaml:"rateLimit"`
+	CORS            CORS          `yaml:"cors"`
+}
+
+type Database struct {
+	Address         string        `yaml:"address"`
+	Username        string        `yaml:"username"`
+	Password        string        `yaml:"password"`
+	Name            string        `yaml:"name"`
+	MaxOpenConns    int           `yaml:"maxOpenConns"`
+	MaxIdleConns    int           `yaml:"maxIdleConns"`
+	ConnMaxLifetime time.Duration `yaml:"connMaxLifetime"`
+	// Replicas are DSNs of read-only replicas. Reads go to them unless the
+	// requester wrote something within ReadYourWrites.
+	Replicas       []string      `yaml:"replicas"`
+	ReadYourWrites time.Duration `yaml:"readYourWrites"`
+}
+
+type Site struct {
+	Hostname   string `yaml:"hostname"`
+	Title      string `yaml:"title"`
+	FaviconURL string `yaml:"faviconUrl"`
+	StaticPath string `yaml:"staticPath"`
+}
+
+// Purge controls how long soft-deleted posts and comments are kept.
+type Purge struct {
+	Retention time.Duration `yaml:"retention"`
+	// Interval between background purges; 0 leaves purging to the CLI.
+	Interval  time.Duration `yaml:"interval"`
+	BatchSize int           `yaml:"batchSize"`
+}
+
+// Publish controls the scheduler that publishes scheduled posts once their
+// time comes. An Interval of 0 leaves that to another server.
+type Publish struct {
+	Interval time.Duration `yaml:"interval"`
+}
+
+// Render controls how post content becomes the HTML served as contentHtml.
+// Format is markdown, CommonMark with the listed GFM Extensions, or text,
+// which keeps the content as written. The result is then run through the
+// Sanitize allowlist: ugc keeps the markup user content may use and strict
+// strips every tag. RawHTML passes HTML in the content on to the sanitizer
+// instead of dropping it.
+type Render struct {
+	Format     string   `yaml:"format"`
+	Extensions []string `yaml:"extensions"`
+	HardWraps  bool     `yaml:"hardWraps"`
+	RawHTML    bool     `yaml:"rawHtml"`
+	Sanitize   string   `yaml:"sanitize"`
+}
+
+// Search controls the search API. The store does the searching: MySQL with
+// its full-text indexes, the memory store with an inverted index of its
+// own. SnippetLength is how many characters of text a hit shows.
+type Search struct {
+	Enabled       bool `yaml:"enabled"`
+	SnippetLength int  `yaml:"snippetLength"`
+}
+
+// Auth controls how a request proves which user sent it.
+type Auth struct {
+	// SessionSecret signs session cookies; sessions are off without it.
+	SessionSecret string        `yaml:"sessionSecret"`
+	SessionCookie string        `yaml:"sessionCookie"`
+	SessionTTL    time.Duration `yaml:"sessionTtl"`
+	// JWTSecret verifies HS256 bearer tokens and JWTPublicKey, a PEM file,
+	// verifies RS256 ones. Issuer and audience are checked when set.
+	JWTSecret    string `yaml:"jwtSecret"`
+	JWTPublicKey string `yaml:"jwtPublicKey"`
+	JWTIssuer    string `yaml:"jwtIssuer"`
+	JWTAudience  string `yaml:"jwtAudience"`
+	// TrustedProxies are addresses or CIDRs allowed to name the user in
+	// ProxyHeader, for deployments behind an authenticating proxy.
+	TrustedProxies []string `yaml:"trustedProxies"`
+	ProxyHeader    string   `yaml:"proxyHeader"`
+	// LocalAccounts enables sign-up and password login, and creates the
+	// users table if no external service provides it. The first account
+	// becomes an admin; later ones get SignupRole.
+	LocalAccounts bool   `yaml:"localAccounts"`
+	SignupRole    string `yaml:"signupRole"`
+	// GitHub enables login with GitHub when its client ID is set. OIDC
+	// lists further OpenID Connect providers, each under its own name.
+	GitHub OAuth   `yaml:"github"`
+	OIDC   []OAuth `yaml:"oidc"`
+}
+
+// OAuth is an OAuth2 provider. The endpoints default to GitHub's and can be
+// pointed elsewhere, for example at a stub in tests. UserURL returns the
+// signed in user: GitHub's /user or an OIDC userinfo endpoint.
+type OAuth struct {
+	Name         string   `yaml:"name"`
+	ClientID     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	AuthURL      string   `yaml:"authUrl"`
+	TokenURL     string   `yaml:"tokenUrl"`
+	UserURL      string   `yaml:"userUrl"`
+	Scopes       []string `yaml:"scopes"`
+}
+
+// RateLimit throttles creating projects, posts and comments, per user and
+// per client IP. Backend is memory, counting on this server alone, or store,
+// sharing the counts with every server using the same MySQL database.
+type RateLimit struct {
+	Enabled  bool   `yaml:"enabled"`
+	Backend  string `yaml:"backend"`
+	Projects Limits `yaml:"projects"`
+	Posts    Limits `yaml:"posts"`
+	Comments Limits `yaml:"comments"`
+}
+
+type Limits struct {
+	PerUser Limit `yaml:"perUser"`
+	PerIP   Limit `yaml:"perIp"`
+}
+
+// Limit is a token bucket: up to Burst requests at once, then one more
+// every Every. A zero Burst leaves the requests unlimited.
+type Limit struct {
+	Burst int           `yaml:"burst"`
+	Every time.Duration `yaml:"every"`
+}
+
+// CORS lets pages on other origins call the API. It is off while
+// AllowOrigins is empty. "*" allows every origin, but browsers then refuse
+// to send cookies, so it cannot be combined with AllowCredentials.
+type CORS struct {
+	AllowOrigins     []string      `yaml:"allowOrigins"`
+	AllowMethods     []string      `yaml:"allowMethods"`
+	AllowHeaders     []string      `yaml:"allowHeaders"`
+	ExposeHeaders    []string      `yaml:"exposeHeaders"`
+	AllowCredentials bool          `yaml:"allowCredentials"`
+	MaxAge           time.Duration `yaml:"maxAge"`
+}
+
+type Routes struct {
+	Pages string `yaml:"pages"`
+	API   string `yaml:"api"`
+}
+
+func Default() Config {
+	return Config{
+		Port:            "8080",
+		ShutdownTimeout: 15 * time.Second,
+		Store:           "mysql",
+		Database: Database{
+			ReadYourWrites: 5 * time.Second,
+		},
+		Site: Site{
+			Title: "CLOG",
+		},
+		Routes: Routes{
+			Pages: "blog",
+			API:   "go-blog/api/v1",
+		},
+		Purge: Purge{
+			Retention: 30 * 24 * time.Hour,
+			Interval:  time.Hour,
+			BatchSize: 500,
+		},
+		Publish: Publish{
+			Interval: time.Minute,
+		},
+		Render: Render{
+			Format:     "markdown",
+			Extensions: []string{"table", "strikethrough", "linkify", "tasklist"},
+			Sanitize:   "ugc",
+		},
+		Search: Search{
+			Enabled:       true,
+			SnippetLength: 120,
+		},
+		Auth: Auth{
+			SessionCookie: "go_blog_session",
+			SessionTTL:    7 * 24 * time.Hour,
+			ProxyHeader:   "id",
+			SignupRole:    "commenter",
+			GitHub: OAuth{
+				Name:     "github",
+				AuthURL:  "https://github.com/login/oauth/authorize",
+				TokenURL: "https://github.com/login/oauth/access_token",
+				UserURL:  "https://api.github.com/user",
+			},
+		},
+		RateLimit: RateLimit{
+			Enabled: true,
+			Backend: "memory",
+			Projects: Limits{
+				PerUser: Limit{Burst: 5, Every: time.Hour},
+				PerIP:   Limit{Burst: 10, Every: 30 * time.Minute},
+			},
+			Posts: Limits{
+				PerUser: Limit{Burst: 10, Every: 6 * time.Minute},
+				PerIP:   Limit{Burst: 30, Every: 2 * time.Minute},
+			},
+			Comments: Limits{
+				PerUser: Limit{Burst: 10, Every: 30 * time.Second},
+				PerIP:   Limit{Burst: 30, Every: 10 * time.Second},
+			},
+		},
+		CORS: CORS{
+			AllowMethods:  []string{"GET", "POST", "PUT", "DELETE"},
+			AllowHeaders:  []string{"Content-Type", "Authorization", "X-Request-ID", "X-CSRF-Token", "X-Primary-Until"},
+			ExposeHeaders: []string{"X-Request-ID", "X-CSRF-Token", "X-Primary-Until", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "RateLimit-Policy"},
+			MaxAge:        12 * time.Hour,
+		},
+	}
+}
+
+// Load builds the configuration from, in increasing priority, the defaults,
+// the YAML file given by -config or GO_BLOG_CONFIG, environment variables
+// and command line flags. It returns the arguments left after the flags.
+// The result is not validated; call Validate before using it.
+func Load(name string, args []string) (Config, []string, error) {
+	c := Default()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("GO_BLOG_CONFIG"), "path to a YAML configuration file")
+	flags := map[string]string{}
+	for _, s := range c.settings() {
+		_, isBool := s.value.(boolValue)
+		fs.Var(flagValue{name: s.flag, values: flags, isBool: isBool}, s.flag, s.usage)
+	}
+	err := fs.Parse(args)
+	if err != nil {
+		return c, nil, err
+	}
+
+	if *path != "" {
+		data, err := ioutil.ReadFile(*path)
+		if err != nil {
+			return c, nil, fmt.Errorf("config: %v", err)
+		}
+		err = yaml.UnmarshalStrict(data, &c)
+		if err != nil {
+			return c, nil, fmt.Errorf("config: %s: %v", *path, err)
+		}
+	}
+
+	for _, s := range c.settings() {
+		if v := os.Getenv(s.env); v != "" {
+			err = s.value.Set(v)
+			if err != nil {
+				return c, nil, fmt.Errorf("config: %s: %v", s.env, err)
+			}
+		}
+		if v, ok := flags[s.flag]; ok {
+			err = s.value.Set(v)
+			if err != nil {
+				return c, nil, fmt.Errorf("config: -%s: %v", s.flag, err)
+			}
+		}
+	}
+
+	if c.Site.FaviconURL == "" && c.Site.Hostname != "" {
+		c.Site.FaviconURL = "https://" + c.Site.Hostname + "/static/favicon.png"
+	}
+	c.Routes.Pages = strings.Trim(c.Routes.Pages, "/")
+	c.Routes.API = strings.Trim(c.Routes.API, "/")
+
+	return c, fs.Args(), nil
+}
+
+// Validate reports every invalid setting at once, naming where it can be set.
+func (c Config) Validate() error {
+	var p problems
+	port, err := strconv.Atoi(c.Port)
+	p.require(err == nil && port > 0 && port < 65536, "port %q must be a number between 1 and 65535 (port, GO_BLOG_PORT, -port)", c.Port)
+	p.require(c.ShutdownTimeout > 0, "shutdown timeout must be positive (shutdownTimeout, GO_BLOG_SHUTDOWN_TIMEOUT, -shutdown-timeout)")
+	p.require(c.Store == "mysql" || c.Store == "memory", "store %q must be mysql or memory (store, GO_BLOG_STORE, -store)", c.Store)
+	if c.Store == "mysql" {
+		c.Database.validate(&p)
+	}
+	p.require(c.Site.Hostname != "", "site hostname is required (site.hostname, HOSTNAME, -hostname)")
+	p.require(c.Site.StaticPath != "", "static file path is required (site.staticPath, BLOG_STATIC_FILE_PATH, -static-path)")
+	p.require(c.Site.Title != "", "site title must not be empty (site.title, BLOG_SITE_TITLE, -site-title)")
+	p.require(c.Routes.Pages != "", "page route prefix must not be empty (routes.pages)")
+	p.require(c.Routes.API != "", "api route prefix must not be empty (routes.api)")
+	p.require(c.Routes.Pages != c.Routes.API, "page and api route prefixes must differ")
+	c.Purge.validate(&p)
+	p.require(c.Publish.Interval >= 0, "publish interval must not be negative (publish.interval, PUBLISH_INTERVAL, -publish-interval)")
+	c.Render.validate(&p)
+	p.require(!c.Search.Enabled || c.Search.SnippetLength > 0, "search snippet length must be positive (search.snippetLength, SEARCH_SNIPPET_LENGTH, -search-snippet-length)")
+	c.Auth.validate(&p)
+	c.RateLimit.validate(&p, c.Store)
+	c.CORS.validate(&p)
+	return p.err()
+}
+
+// Validate checks only the database settings, for commands that need nothing else.
+func (d Database) Validate() error {
+	var p problems
+	d.validate(&p)
+	return p.err()
+}
+
+func (d Database) validate(p *problems) {
+	p.require(d.Username != "", "database username is required (database.username, DATABASE_USERNAME, -database-username)")
+	p.require(d.Name != "", "database name is required (database.name, DATABASE_NAME, -database-name)")
+	p.require(d.MaxOpenConns >= 0, "database.maxOpenConns must not be negative")
+	p.require(d.MaxIdleConns >= 0, "database.maxIdleConns must not be negative")
+	p.require(d.ConnMaxLifetime >= 0, "database.connMaxLifetime must not be negative")
+	p.require(d.ReadYourWrites >= 0, "database.readYourWrites must not be negative")
+	for _, dsn := range d.Replicas {
+		p.require(strings.TrimSpace(dsn) != "", "database.replicas must not contain empty DSNs")
+	}
+}
+
+// Validate checks only the purge settings.
+func (c Purge) Validate() error {
+	var p problems
+	c.validate(&p)
+	return p.err()
+}
+
+func (c Purge) validate(p *problems) {
+	p.require(c.Retention > 0, "purge retention must be positive (purge.retention, PURGE_RETENTION, -purge-retention)")
+	p.require(c.Interval >= 0, "purge interval must not be negative (purge.interval, PURGE_INTERVAL, -purge-interval)")
+	p.require(c.BatchSize > 0, "purge batch size must be positive (purge.batchSize, PURGE_BATCH_SIZE, -purge-batch-size)")
+}
+
+// validate leaves the extension names to render.New, which knows them.
+func (c Render) validate(p *problems) {
+	p.require(c.Format == "markdown" || c.Format == "text", "render format %q must be markdown or text (render.format, RENDER_FORMAT, -render-format)", c.Format)
+	p.require(c.Sanitize == "ugc" || c.Sanitize == "strict", "render sanitize policy %q must be ugc or strict (render.sanitize, RENDER_SANITIZE, -render-sanitize)", c.Sanitize)
+}
+
+func (c Auth) validate(p *problems) {
+	p.require(c.SessionSecret == "" || len(c.SessionSecret) >= 32, "session secret must be at least 32 bytes (auth.sessionSecret, AUTH_SESSION_SECRET)")
+	p.require(c.SessionCookie != "", "session cookie name must not be empty (auth.sessionCookie, AUTH_SESSION_COOKIE, -session-cookie)")
+	p.require(c.SessionTTL > 0, "session ttl must be positive (auth.sessionTtl, AUTH_SESSION_TTL, -session-ttl)")
+	p.require(c.JWTSecret == "" || len(c.JWTSecret) >= 32, "jwt secret must be at least 32 bytes (auth.jwtSecret, AUTH_JWT_SECRET)")
+	for _, proxy := range c.TrustedProxies {
+		_, _, err := net.ParseCIDR(proxy)
+		p.require(err == nil || net.ParseIP(proxy) != nil, "trusted proxy %q must be an IP address or CIDR (auth.trustedProxies, AUTH_TRUSTED_PROXIES, -trusted-proxies)", proxy)
+	}
+	p.require(!c.LocalAccounts || c.SessionSecret != "", "local accounts need a session secret (auth.sessionSecret, AUTH_SESSION_SECRET)")
+	p.require(c.SignupRole == "author" || c.SignupRole == "commenter", "signup role %q must be author or commenter (auth.signupRole, AUTH_SIGNUP_ROLE, -signup-role)", c.SignupRole)
+	p.require(c.GitHub.ClientID == "" || c.GitHub.ClientSecret != "", "github client secret is required with a client id (auth.github.clientSecret, AUTH_GITHUB_CLIENT_SECRET)")
+	p.require(c.GitHub.ClientID == "" || (c.GitHub.AuthURL != "" && c.GitHub.TokenURL != "" && c.GitHub.UserURL != ""), "github endpoints must not be empty (auth.github.authUrl, tokenUrl, userUrl)")
+	names := map[string]bool{"github": true}
+	for i, provider := range c.OIDC {
+		p.require(providerName.MatchString(provider.Name), "auth.oidc[%d].name %q must match ^[a-zA-Z0-9_-]+$", i, provider.Name)
+		p.require(!names[provider.Name], "auth.oidc[%d].name %q is already used", i, provider.Name)
+		names[provider.Name] = true
+		p.require(provider.ClientID != "" && provider.ClientSecret != "", "auth.oidc[%d] needs clientId and clientSecret", i)
+		p.require(provider.AuthURL != "" && provider.TokenURL != "" && provider.UserURL != "", "auth.oidc[%d] needs authUrl, tokenUrl and userUrl", i)
+	}
+	p.require(c.GitHub.ClientID == "" && len(c.OIDC) == 0 || c.SessionSecret != "", "oauth login needs a session secret (auth.sessionSecret, AUTH_SESSION_SECRET)")
+	p.require(len(c.TrustedProxies) == 0 || c.ProxyHeader != "", "proxy header must not be empty when trusted proxies are set (auth.proxyHeader, AUTH_PROXY_HEADER, -proxy-header)")
+}
+
+func (c RateLimit) validate(p *problems, store string) {
+	if !c.Enabled {
+		return
+	}
+	p.require(c.Backend == "memory" || c.Backend == "store", "rate limit backend %q must be memory or store (rateLimit.backend, RATE_LIMIT_BACKEND, -rate-limit-backend)", c.Backend)
+	p.require(c.Backend != "store" || store == "mysql", "the store rate limit backend needs the mysql store (rateLimit.backend, RATE_LIMIT_BACKEND, -rate-limit-backend)")
+	for _, group := range []struct {
+		name   string
+		limits Limits
+	}{
+		{"projects", c.Projects},
+		{"posts", c.Posts},
+		{"comments", c.Comments},
+	} {
+		for _, limit := range []struct {
+			name  string
+			limit Limit
+		}{
+			{"perUser", group.limits.PerUser},
+			{"perIp", group.limits.PerIP},
+		} {
+			p.require(limit.limit.Burst >= 0, "rateLimit.%s.%s.burst must not be negative", group.name, limit.name)
+			p.require(limit.limit.Burst == 0 || limit.limit.Every > 0, "rateLimit.%s.%s.every must be positive", group.name, limit.name)
+		}
+	}
+}
+
+func (c CORS) validate(p *problems) {
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			p.require(!c.AllowCredentials, "cors origin * cannot be combined with credentials (cors.allowOrigins, CORS_ALLOW_ORIGINS, -cors-allow-origins)")
+			continue
+		}
+		u, err := url.Parse(origin)
+		p.require(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" && u.Path == "" && u.RawQuery == "", "cors origin %q must be * or a scheme and host such as https://example.com (cors.allowOrigins, CORS_ALLOW_ORIGINS, -cors-allow-origins)", origin)
+	}
+	p.require(len(c.AllowOrigins) == 0 || len(c.AllowMethods) > 0, "cors.allowMethods must not be empty")
+	p.require(c.MaxAge >= 0, "cors.maxAge must not be negative")
+}
+
+var providerName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+type problems []string
+
+func (p *problems) require(ok bool, format string, args ...interface{}) {
+	if !ok {
+		*p = append(*p, fmt.Sprintf(format, args...))
+	}
+}
+
+func (p problems) err() error {
+	if len(p) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration:\n  " + strings.Join(p, "\n  "))
+}
+
+type setting struct {
+	flag  string
+	env   string
+	usage string
+	value flag.Value
+}
+
+func (c *Config) settings() []setting {
+	return []setting{
+		{"port", "GO_BLOG_PORT", "port to listen on", stringValue{&c.Port}},
+		{"shutdown-timeout", "GO_BLOG_SHUTDOWN_TIMEOUT", "how long to wait for in-flight requests on shutdown", durationValue{&c.ShutdownTimeout}},
+		{"store", "GO_BLOG_STORE", "storage backend: mysql or memory", stringValue{&c.Store}},
+		{"database-address", "DATABASE_ADDRESS", "MySQL host:port", stringValue{&c.Database.Address}},
+		{"database-username", "DATABASE_USERNAME", "MySQL user", stringValue{&c.Database.Username}},
+		{"database-password", "DATABASE_PASSWORD", "MySQL password", stringValue{&c.Database.Password}},
+		{"database-name", "DATABASE_NAME", "MySQL database name", stringValue{&c.Database.Name}},
+		{"database-max-open-conns", "DATABASE_MAX_OPEN_CONNS", "maximum open connections, 0 for unlimited", intValue{&c.Database.MaxOpenConns}},
+		{"database-max-idle-conns", "DATABASE_MAX_IDLE_CONNS", "maximum idle connections", intValue{&c.Database.MaxIdleConns}},
+		{"database-conn-max-lifetime", "DATABASE_CONN_MAX_LIFETIME", "maximum connection lifetime, 0 for unlimited", durationValue{&c.Database.ConnMaxLifetime}},
+		{"database-replicas", "DATABASE_REPLICAS", "comma separated DSNs of read replicas", stringsValue{&c.Database.Replicas}},
+		{"database-read-your-writes", "DATABASE_READ_YOUR_WRITES", "how long a writer keeps reading from the primary", durationValue{&c.Database.ReadYourWrites}},
+		{"hostname", "HOSTNAME", "public host name used in page URLs", stringValue{&c.Site.Hostname}},
+		{"site-title", "BLOG_SITE_TITLE", "site title", stringValue{&c.Site.Title}},
+		{"favicon-url", "BLOG_FAVICON_URL", "default page image", stringValue{&c.Site.FaviconURL}},
+		{"static-path", "BLOG_STATIC_FILE_PATH", "directory holding templates and static files", stringValue{&c.Site.StaticPath}},
+		{"purge-retention", "PURGE_RETENTION", "how long soft-deleted posts and comments are kept", durationValue{&c.Purge.Retention}},
+		{"purge-interval", "PURGE_INTERVAL", "time between background purges, 0 to disable", durationValue{&c.Purge.Interval}},
+		{"purge-batch-size", "PURGE_BATCH_SIZE", "rows deleted per purge statement", intValue{&c.Purge.BatchSize}},
+		{"publish-interval", "PUBLISH_INTERVAL", "time between checks for scheduled posts, 0 to disable", durationValue{&c.Publish.Interval}},
+		{"render-format", "RENDER_FORMAT", "how post content is written: markdown or text", stringValue{&c.Render.Format}},
+		{"render-extensions", "RENDER_EXTENSIONS", "comma separated GFM extensions of markdown content", stringsValue{&c.Render.Extensions}},
+		{"render-sanitize", "RENDER_SANITIZE", "allowlist rendered HTML goes through: ugc or strict", stringValue{&c.Render.Sanitize}},
+		{"search", "SEARCH", "enable the search API", boolValue{&c.Search.Enabled}},
+		{"search-snippet-length", "SEARCH_SNIPPET_LENGTH", "characters of text shown around a search hit", intValue{&c.Search.SnippetLength}},
+		{"session-secret", "AUTH_SESSION_SECRET", "key signing session cookies, at least 32 bytes", stringValue{&c.Auth.SessionSecret}},
+		{"session-cookie", "AUTH_SESSION_COOKIE", "session cookie name", stringValue{&c.Auth.SessionCookie}},
+		{"session-ttl", "AUTH_SESSION_TTL", "how long a session stays valid", durationValue{&c.Auth.SessionTTL}},
+		{"jwt-secret", "AUTH_JWT_SECRET", "HMAC key verifying HS256 bearer tokens", stringValue{&c.Auth.JWTSecret}},
+		{"jwt-public-key", "AUTH_JWT_PUBLIC_KEY", "PEM file with the RSA key verifying RS256 bearer tokens", stringValue{&c.Auth.JWTPublicKey}},
+		{"jwt-issuer", "AUTH_JWT_ISSUER", "required iss claim of bearer tokens", stringValue{&c.Auth.JWTIssuer}},
+		{"jwt-audience", "AUTH_JWT_AUDIENCE", "required aud claim of bearer tokens", stringValue{&c.Auth.JWTAudience}},
+		{"trusted-proxies", "AUTH_TRUSTED_PROXIES", "comma separated addresses or CIDRs allowed to set the proxy header", stringsValue{&c.Auth.TrustedProxies}},
+		{"proxy-header", "AUTH_PROXY_HEADER", "header naming the user, trusted only from trusted proxies", stringValue{&c.Auth.ProxyHeader}},
+		{"local-accounts", "AUTH_LOCAL_ACCOUNTS", "enable sign-up and password login", boolValue{&c.Auth.LocalAccounts}},
+		{"signup-role", "AUTH_SIGNUP_ROLE", "role of new local accounts: author or commenter", stringValue{&c.Auth.SignupRole}},
+		{"github-client-id", "AUTH_GITHUB_CLIENT_ID", "GitHub OAuth app client ID, enables GitHub login", stringValue{&c.Auth.GitHub.ClientID}},
+		{"github-client-secret", "AUTH_GITHUB_CLIENT_SECRET", "GitHub OAuth app client secret", stringValue{&c.Auth.GitHub.ClientSecret}},
+		{"github-auth-url", "AUTH_GITHUB_AUTH_URL", "GitHub authorization endpoint", stringValue{&c.Auth.GitHub.AuthURL}},
+		{"github-token-url", "AUTH_GITHUB_TOKEN_URL", "GitHub token endpoint", stringValue{&c.Auth.GitHub.TokenURL}},
+		{"github-user-url", "AUTH_GITHUB_USER_URL", "GitHub API endpoint returning the signed in user", stringValue{&c.Auth.GitHub.UserURL}},
+		{"cors-allow-origins", "CORS_ALLOW_ORIGINS", "comma separated origins whose pages may call the API, or *", stringsValue{&c.CORS.AllowOrigins}},
+		{"cors-allow-credentials", "CORS_ALLOW_CREDENTIALS", "let other origins send cookies to the API", boolValue{&c.CORS.AllowCredentials}},
+		{"rate-limit", "RATE_LIMIT", "throttle creating projects, posts and comments", boolValue{&c.RateLimit.Enabled}},
+		{"rate-limit-backend", "RATE_LIMIT_BACKEND", "where rate limits are counted: memory or store", stringValue{&c.RateLimit.Backend}},
+	}
+}
+
+// flagValue records flags as strings so they can be applied after the file
+// and the environment have been read.
+type flagValue struct {
+	name   string
+	values map[string]string
+	isBool bool
+}
+
+func (v flagValue) String() string {
+	return ""
+}
+
+func (v flagValue) Set(s string) error {
+	v.values[v.name] = s
+	return nil
+}
+
+// IsBoolFlag lets boolean flags be given without a value.
+func (v flagValue) IsBoolFlag() bool {
+	return v.isBool
+}
+
+type stringValue struct{ p *string }
+
+func (v stringValue) String() string {
+	return *v.p
+}
+
+func (v stringValue) Set(s string) error {
+	*v.p = s
+	return nil
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) String() string {
+	return strconv.Itoa(*v.p)
+}
+
+func (v intValue) Set(s string) error {
+	i, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a number", s)
+	}
+	*v.p = i
+	return nil
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) String() string {
+	return strconv.FormatBool(*v.p)
+}
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a boolean", s)
+	}
+	*v.p = b
+	return nil
+}
+
+type stringsValue struct{ p *[]string }
+
+func (v stringsValue) String() string {
+	return strings.Join(*v.p, ",")
+}
+
+func (v stringsValue) Set(s string) error {
+	*v.p = strings.Split(s, ",")
+	return nil
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) String() string {
+	return v.p.String()
+}
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("%q is not a duration", s)
+	}
+	*v.p = d
+	return nil
+}
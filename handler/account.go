@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/utils"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// dummyHash is compared against when the user does not exist, so that
+// unknown IDs take as long to reject as wrong passwords.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("go-blog dummy password"), bcrypt.DefaultCost)
+
+type signupForm struct {
+	ID       string `json:"id" form:"id" binding:"required,max=32"`
+	Name     string `json:"name" form:"name" binding:"required,max=64"`
+	Password string `json:"password" form:"password" binding:"required,min=8,max=72"`
+}
+
+func (s *Server) Signup(c *gin.Context) {
+	var body signupForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	if !utils.RegexProjectName.MatchString(body.ID) {
+		renderError(c, model.Validation("invalid_user_id", "user ID must match ^[a-zA-Z0-9_-]+$", map[string]string{"id": "must match ^[a-zA-Z0-9_-]+$"}))
+		return
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(body.Password), bcrypt.DefaultCost)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	user := model.User{ID: body.ID, Name: body.Name, ProjectIDs: make([]string, 0)}
+	err = s.store.CreateAccount(&user, model.Role(s.config.Auth.SignupRole), string(hash))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.audit(c, "user.create", "user", user.ID, nil, user)
+	s.startSession(c, user.ID)
+	c.JSON(http.StatusOK, user)
+}
+
+type loginForm struct {
+	ID       string `json:"id" form:"id" binding:"required"`
+	Password string `json:"password" form:"password" binding:"required"`
+}
+
+func (s *Server) Login(c *gin.Context) {
+	var body loginForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	hash, err := s.store.GetPasswordHash(body.ID)
+	if errors.Is(err, model.ErrNotFound) {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(body.Password))
+		renderError(c, model.Unauthorized("invalid_credentials", "wrong user ID or password"))
+		return
+	}
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hash), []byte(body.Password)) != nil {
+		renderError(c, model.Unauthorized("invalid_credentials", "wrong user ID or password"))
+		return
+	}
+	s.startSession(c, body.ID)
+	c.JSON(http.StatusOK, gin.H{"id": body.ID})
+}
+
+func (s *Server) Logout(c *gin.Context) {
+	s.endSession(c)
+	c.JSON(http.StatusOK, gin.H{})
+}
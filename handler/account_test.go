@@ -0,0 +1,98 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+)
+
+// newLocalServer serves an empty memory store with local accounts enabled
+// and no trusted proxies.
+func newLocalServer(t *testing.T) *testServer {
+	return newTestServer(t, func(c *config.Config) {
+		c.Auth.TrustedProxies = nil
+		c.Auth.SessionSecret = testSessionSecret
+		c.Auth.LocalAccounts = true
+	}, model.NewMemoryStore())
+}
+
+// session returns the session cookie set by rec.
+func session(t *testing.T, rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "go_blog_session" {
+			return cookie
+		}
+	}
+	t.Fatalf("no session cookie in %v", rec.Header())
+	return nil
+}
+
+func TestLocalAccounts(t *testing.T) {
+	ts := newLocalServer(t)
+
+	ts.expectError(ts.do("POST", api+"signup", "", gin.H{"id": "alice", "name": "Alice", "password": "short"}), http.StatusBadRequest, "invalid_body")
+	ts.expectError(ts.do("POST", api+"signup", "", gin.H{"id": "not valid", "name": "Alice", "password": "correct horse"}), http.StatusBadRequest, "invalid_user_id")
+
+	var user model.User
+	rec := ts.do("POST", api+"signup", "", gin.H{"id": "alice", "name": "Alice", "password": "correct horse"})
+	ts.expect(rec, http.StatusOK, &user)
+	if user.Auth != "admin" {
+		t.Fatalf("first account auth = %q, want admin", user.Auth)
+	}
+	aliceSession, aliceCSRF := session(t, rec), rec.Header().Get("X-CSRF-Token")
+	if !aliceSession.HttpOnly || !aliceSession.Secure {
+		t.Fatalf("session cookie is not HttpOnly and Secure: %+v", aliceSession)
+	}
+
+	rec = ts.do("POST", api+"signup", "", gin.H{"id": "bob", "name": "Bob", "password": "battery staple"})
+	ts.expect(rec, http.StatusOK, &user)
+	if role, _ := ts.store.GetRole("bob"); role != model.RoleCommenter {
+		t.Fatalf("later accounts are %q, want commenter", role)
+	}
+	ts.expectError(ts.do("POST", api+"signup", "", gin.H{"id": "bob", "name": "Bobby", "password": "battery staple"}), http.StatusConflict, "user_exists")
+
+	// the session from sign-up authenticates writes that repeat its CSRF token
+	req := httptest.NewRequest("POST", api+"projects", strings.NewReader(`{"name":"diary"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(aliceSession)
+	rec = httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	ts.expectError(rec, http.StatusForbidden, "csrf_failed")
+	req.Header.Set("X-CSRF-Token", aliceCSRF)
+	rec = httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	ts.expect(rec, http.StatusOK, nil)
+
+	ts.expectError(ts.do("POST", api+"login", "", gin.H{"id": "bob", "password": "wrong password"}), http.StatusUnauthorized, "invalid_credentials")
+	ts.expectError(ts.do("POST", api+"login", "", gin.H{"id": "nobody", "password": "battery staple"}), http.StatusUnauthorized, "invalid_credentials")
+	rec = ts.do("POST", api+"login", "", gin.H{"id": "bob", "password": "battery staple"})
+	ts.expect(rec, http.StatusOK, nil)
+	bobSession := session(t, rec)
+
+	req = httptest.NewRequest("POST", api+"projects", strings.NewReader(`{"name":"notes"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.AddCookie(bobSession)
+	req.Header.Set("X-CSRF-Token", rec.Header().Get("X-CSRF-Token"))
+	rec = httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	ts.expectError(rec, http.StatusForbidden, "forbidden")
+
+	rec = ts.do("POST", api+"logout", "", nil)
+	ts.expect(rec, http.StatusOK, nil)
+	if cleared := session(t, rec); cleared.MaxAge >= 0 || cleared.Value != "" {
+		t.Fatalf("logout cookie = %+v", cleared)
+	}
+}
+
+func TestLocalAccountsDisabled(t *testing.T) {
+	ts := newTestServer(t)
+	rec := ts.do("POST", api+"signup", "", gin.H{"id": "eve", "name": "Eve", "password": "correct horse"})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("signup without local accounts = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"encoding/json"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+	"github.com/rs/xid"
+)
+
+const (
+	requestIDHeader = "X-Request-ID"
+	requestIDKey    = "requestID"
+)
+
+var validRequestID = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,64}$`)
+
+// requestID tags the request with the ID given by the client or a proxy,
+// or a new one, and echoes it in the response.
+func (s *Server) requestID(c *gin.Context) {
+	ID := c.GetHeader(requestIDHeader)
+	if !validRequestID.MatchString(ID) {
+		ID = xid.New().String()
+	}
+	c.Set(requestIDKey, ID)
+	c.Header(requestIDHeader, ID)
+	c.Next()
+}
+
+// audit records a change made by the request. before and after are
+// snapshots of the resource, nil when it did not exist. A failure is
+// logged rather than failing a change that has already happened.
+func (s *Server) audit(c *gin.Context, action, resourceType, resourceID string, before, after interface{}) {
+	event := model.AuditEvent{
+		ID:           xid.New().String(),
+		ActorID:      currentUserID(c),
+		Action:       action,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Before:       snapshot(before),
+		After:        snapshot(after),
+		RequestID:    c.GetString(requestIDKey),
+		IP:           c.ClientIP(),
+		CreatedAt:    time.Now().Format("2006-01-02 15:04:05"),
+	}
+	err := s.store.InsertAuditEvent(&event)
+	if err != nil {
+		log.Println("audit:", action, resourceType, resourceID, err)
+	}
+}
+
+func snapshot(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage("null")
+	}
+	return data
+}
+
+// queryTime reads a time filter given as a date or as date and time.
+// Dates cover the whole day: from its start for since, to its end for
+// until.
+func queryTime(c *gin.Context, name string, endOfDay bool) (string, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return "", true
+	}
+	if _, err := time.Parse("2006-01-02 15:04:05", value); err == nil {
+		return value, true
+	}
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			return value + " 23:59:59", true
+		}
+		return value + " 00:00:00", true
+	}
+	renderError(c, model.Validation("invalid_query", name+" should be a date such as 2006-01-02 or 2006-01-02 15:04:05", map[string]string{name: "must be a date"}))
+	return "", false
+}
+
+func (s *Server) GetAuditEvents(c *gin.Context) {
+	filter := model.AuditFilter{
+		ActorID:      c.Query("actorId"),
+		Action:       c.Query("action"),
+		ResourceType: c.Query("resourceType"),
+		ResourceID:   c.Query("resourceId"),
+	}
+	var ok bool
+	if filter.Since, ok = queryTime(c, "since", false); !ok {
+		return
+	}
+	if filter.Until, ok = queryTime(c, "until", true); !ok {
+		return
+	}
+	page, limit, ok := parsePage(c, 50)
+	if !ok {
+		return
+	}
+	events, err := s.store.GetAuditEvents(filter, page)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	writePage(c, limit, events, func(event model.AuditEvent) (string, string) {
+		return event.CreatedAt, event.ID
+	})
+}
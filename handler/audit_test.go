@@ -0,0 +1,67 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestAudit(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.SetRole("carol", model.RoleAdmin)
+
+	req := httptest.NewRequest("POST", api+"projects", strings.NewReader(`{"name":"diary"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("id", "alice")
+	req.Header.Set("X-Request-ID", "req-42")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	var project model.Project
+	ts.expect(rec, http.StatusOK, &project)
+	if rec.Header().Get("X-Request-ID") != "req-42" {
+		t.Fatalf("request ID = %q, want it echoed", rec.Header().Get("X-Request-ID"))
+	}
+	if ts.do("GET", api+"users", "", nil).Header().Get("X-Request-ID") == "" {
+		t.Fatal("requests without an ID get none")
+	}
+
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID, "alice", gin.H{"newDescription": "dear diary"}), http.StatusOK, nil)
+	post := ts.createPost("alice", project.ID, "first")
+	ts.expect(ts.do("DELETE", api+"posts/"+post.ID, "alice", nil), http.StatusOK, nil)
+	// failed changes are not recorded
+	ts.expectError(ts.do("DELETE", api+"projects/"+project.ID, "bob", nil), http.StatusForbidden, "forbidden")
+
+	ts.expectError(ts.do("GET", api+"admin/audit", "alice", nil), http.StatusForbidden, "forbidden")
+	ts.expectError(ts.do("GET", api+"admin/audit?since=yesterday", "carol", nil), http.StatusBadRequest, "invalid_query")
+
+	var events []model.AuditEvent
+	ts.expect(ts.do("GET", api+"admin/audit?resourceType=project&resourceId="+project.ID, "carol", nil), http.StatusOK, &events)
+	if len(events) != 2 || events[0].Action != "project.update" || events[1].Action != "project.create" {
+		t.Fatalf("project events = %+v", events)
+	}
+	created := events[1]
+	if created.ActorID != "alice" || created.RequestID != "req-42" || created.IP != "203.0.113.7" || string(created.Before) != "null" {
+		t.Fatalf("create event = %+v", created)
+	}
+	var before, after model.Project
+	json.Unmarshal(events[0].Before, &before)
+	json.Unmarshal(events[0].After, &after)
+	if before.Description != "" || after.Description != "dear diary" {
+		t.Fatalf("update snapshots = %+v -> %+v", before, after)
+	}
+
+	ts.expect(ts.do("GET", api+"admin/audit?actorId=alice&action=post.delete&since=2000-01-01&until=2999-12-31", "carol", nil), http.StatusOK, &events)
+	if len(events) != 1 || events[0].ResourceID != post.ID || string(events[0].After) != "null" {
+		t.Fatalf("post.delete events = %+v", events)
+	}
+	ts.expect(ts.do("GET", api+"admin/audit?until=2000-01-01", "carol", nil), http.StatusOK, &events)
+	if len(events) != 0 {
+		t.Fatalf("events before 2000 = %+v", events)
+	}
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/policy"
+)
+
+const principalKey = "principal"
+
+// authenticate puts the verified principal of the request into the context.
+func (s *Server) authenticate(c *gin.Context) {
+	principal, err := s.auth.Authenticate(c.Request)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.Set(principalKey, principal)
+	c.Next()
+}
+
+// principal returns who sent the request; anonymous if nobody verified it.
+func principal(c *gin.Context) auth.Principal {
+	p, _ := c.Get(principalKey)
+	principal, _ := p.(auth.Principal)
+	return principal
+}
+
+// currentUserID returns the ID of the acting user, or "" for anonymous requests.
+func currentUserID(c *gin.Context) string {
+	return principal(c).UserID
+}
+
+// subject describes the acting user to the policy.
+func (s *Server) subject(c *gin.Context) (policy.Subject, error) {
+	p := principal(c)
+	subject := policy.Subject{UserID: p.UserID, Scopes: p.Scopes}
+	if subject.UserID != "" {
+		role, err := s.storeFor(c).GetRole(subject.UserID)
+		if errors.Is(err, model.ErrNotFound) {
+			subject.UserID = ""
+		} else if err != nil {
+			return subject, err
+		}
+		subject.Role = role
+	}
+	return subject, nil
+}
+
+// authorize asks the policy whether the acting user may perform action on
+// resource. On refusal it renders the error and returns false.
+func (s *Server) authorize(c *gin.Context, action policy.Action, resource policy.Resource) (policy.Subject, bool) {
+	subject, err := s.subject(c)
+	if err != nil {
+		renderError(c, err)
+		return subject, false
+	}
+	err = policy.Authorize(subject, action, resource)
+	if err != nil {
+		renderError(c, err)
+		return subject, false
+	}
+	return subject, true
+}
+
+// readable reports whether the acting user may see post. Posts that are
+// not published are answered as not found to everyone else, so that their
+// existence does not leak.
+func (s *Server) readable(c *gin.Context, post model.Post) bool {
+	if post.Published() {
+		return true
+	}
+	subject, err := s.subject(c)
+	if err != nil {
+		renderError(c, err)
+		return false
+	}
+	project, err := s.storeFor(c).GetProject(post.ProjectID)
+	if err != nil {
+		renderError(c, err)
+		return false
+	}
+	if policy.Authorize(subject, policy.ReadPost, policy.Resource{Project: &project, Post: &post}) != nil {
+		renderError(c, model.NotFound("post_not_found", "post not found"))
+		return false
+	}
+	return true
+}
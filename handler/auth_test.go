@@ -0,0 +1,202 @@
+package handler_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/config"
+)
+
+const (
+	testJWTSecret     = "0123456789abcdef0123456789abcdef"
+	testSessionSecret = "fedcba9876543210fedcba9876543210"
+)
+
+// profileAs updates the profile with the request modified by prepare and
+// returns the response.
+func (ts *testServer) profileAs(prepare func(*http.Request)) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("PUT", api+"profile", strings.NewReader(`{"newDescription":"hi"}`))
+	req.Header.Set("Content-Type", "application/json")
+	prepare(req)
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	return rec
+}
+
+func signHMAC(t *testing.T, claims jwt.RegisteredClaims, secret string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestProxyHeader(t *testing.T) {
+	ts := newTestServer(t)
+	ts.expect(ts.profileAs(func(req *http.Request) {
+		req.Header.Set("id", "alice")
+	}), http.StatusOK, nil)
+
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.RemoteAddr = "203.0.113.9:4000"
+		req.Header.Set("id", "alice")
+	}), http.StatusUnauthorized, "unauthorized")
+
+	ts = newTestServer(t, func(c *config.Config) {
+		c.Auth.TrustedProxies = []string{"198.51.100.0/24"}
+		c.Auth.ProxyHeader = "X-Forwarded-User"
+	})
+	ts.expect(ts.profileAs(func(req *http.Request) {
+		req.RemoteAddr = "198.51.100.20:4000"
+		req.Header.Set("X-Forwarded-User", "alice")
+	}), http.StatusOK, nil)
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.RemoteAddr = "198.51.100.20:4000"
+		req.Header.Set("id", "alice")
+	}), http.StatusUnauthorized, "unauthorized")
+}
+
+func TestJWT(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.Auth.TrustedProxies = nil
+		c.Auth.JWTSecret = testJWTSecret
+		c.Auth.JWTIssuer = "https://login.example"
+	})
+	valid := jwt.RegisteredClaims{
+		Subject:   "alice",
+		Issuer:    "https://login.example",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	bearer := func(token string) func(*http.Request) {
+		return func(req *http.Request) {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+
+	ts.expect(ts.profileAs(bearer(signHMAC(t, valid, testJWTSecret))), http.StatusOK, nil)
+
+	ts.expectError(ts.profileAs(bearer(signHMAC(t, valid, "another secret of thirty-two bytes"))), http.StatusUnauthorized, "invalid_token")
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+	ts.expectError(ts.profileAs(bearer(signHMAC(t, expired, testJWTSecret))), http.StatusUnauthorized, "invalid_token")
+	foreign := valid
+	foreign.Issuer = "https://elsewhere.example"
+	ts.expectError(ts.profileAs(bearer(signHMAC(t, foreign, testJWTSecret))), http.StatusUnauthorized, "invalid_token")
+	anonymous := valid
+	anonymous.Subject = ""
+	ts.expectError(ts.profileAs(bearer(signHMAC(t, anonymous, testJWTSecret))), http.StatusUnauthorized, "invalid_token")
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.Header.Set("Authorization", "Basic YWxpY2U6c2VjcmV0")
+	}), http.StatusUnauthorized, "invalid_token")
+
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expectError(ts.profileAs(bearer(unsigned)), http.StatusUnauthorized, "invalid_token")
+
+	// the id header no longer works without a trusted proxy
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.Header.Set("id", "alice")
+	}), http.StatusUnauthorized, "unauthorized")
+}
+
+func TestJWTWithRSA(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "jwt.pem")
+	err = ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := newTestServer(t, func(c *config.Config) {
+		c.Auth.JWTPublicKey = path
+		c.Auth.JWTAudience = "go-blog"
+	})
+
+	claims := jwt.RegisteredClaims{Subject: "bob", Audience: jwt.ClaimStrings{"go-blog"}}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expect(ts.profileAs(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}), http.StatusOK, nil)
+
+	// HS256 must not be accepted when only an RSA key is configured
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+signHMAC(t, claims, string(der)))
+	}), http.StatusUnauthorized, "invalid_token")
+
+	claims.Audience = jwt.ClaimStrings{"other"}
+	token, err = jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}), http.StatusUnauthorized, "invalid_token")
+}
+
+func TestSessionCookie(t *testing.T) {
+	setup := func(c *config.Config) {
+		c.Auth.TrustedProxies = nil
+		c.Auth.SessionSecret = testSessionSecret
+	}
+	ts := newTestServer(t, setup)
+	a, err := auth.New(config.Auth{SessionSecret: testSessionSecret, SessionCookie: "go_blog_session", SessionTTL: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := a.NewSession("alice")
+
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.AddCookie(cookie)
+	}), http.StatusForbidden, "csrf_failed")
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.AddCookie(cookie)
+		req.Header.Set(auth.CSRFHeader, a.CSRFToken(a.NewSession("bob").Value))
+	}), http.StatusForbidden, "csrf_failed")
+	ts.expect(ts.profileAs(func(req *http.Request) {
+		req.AddCookie(cookie)
+		req.Header.Set(auth.CSRFHeader, a.CSRFToken(cookie.Value))
+	}), http.StatusOK, nil)
+
+	tampered := *cookie
+	tampered.Value = strings.Replace(cookie.Value, cookie.Value[:4], "Ym9i", 1)
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.AddCookie(&tampered)
+	}), http.StatusUnauthorized, "unauthorized")
+
+	expired, err := auth.New(config.Auth{SessionSecret: testSessionSecret, SessionCookie: "go_blog_session", SessionTTL: -time.Minute}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expectError(ts.profileAs(func(req *http.Request) {
+		req.AddCookie(expired.NewSession("alice"))
+	}), http.StatusUnauthorized, "unauthorized")
+
+	// a bad cookie does not stop anonymous reads
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", api+"users", nil)
+	req.AddCookie(&tampered)
+	ts.server.ServeHTTP(rec, req)
+	ts.expect(rec, http.StatusOK, nil)
+}
@@ -2,43 +2,52 @@ package handler
 
 import (
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/n-inja/go-blog/model"
-	"github.com/n-inja/go-blog/utils"
+	"github.com/n-inja/go-blog/policy"
 	"github.com/rs/xid"
 )
 
-func GetPostComments(c *gin.Context) {
+func (s *Server) GetPostComments(c *gin.Context) {
 	postID := c.Param("postID")
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil {
-		limit = 10
+	page, limit, ok := parsePage(c, 10)
+	if !ok {
+		return
 	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+
+	// comments of a post nobody else may see yet stay hidden with it
+	post, err := s.storeFor(c).GetPost(postID)
 	if err != nil {
-		offset = 0
+		renderError(c, err)
+		return
 	}
-	if limit < 0 || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{})
+	if !s.readable(c, post) {
 		return
 	}
-
-	comments, err := model.GetPostComments(postID, offset, limit)
+	comments, err := s.storeFor(c).GetPostComments(postID, page)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, comments)
+	writePage(c, limit, comments, commentKey)
 }
 
-func GetComment(c *gin.Context) {
+func (s *Server) GetComment(c *gin.Context) {
 	commentID := c.Param("commentID")
-	comment, err := model.GetComment(commentID)
+	comment, err := s.storeFor(c).GetComment(commentID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	// a comment is no more visible than its post
+	post, err := s.storeFor(c).GetPost(comment.PostID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
+		return
+	}
+	if !s.readable(c, post) {
 		return
 	}
 	c.JSON(http.StatusOK, comment)
@@ -48,45 +57,54 @@ type commentForm struct {
 	Content string `json:"content" form:"content" binding:"required"`
 }
 
-func PostComment(c *gin.Context) {
+func (s *Server) PostComment(c *gin.Context) {
 	postID := c.Param("postID")
-	ID := c.GetHeader("id")
-	if !utils.HasCommentAuth(ID) {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	subject, ok := s.authorize(c, policy.CreateComment, policy.Resource{})
+	if !ok {
 		return
 	}
+	ID := subject.UserID
 	var commentForm commentForm
-	err := c.BindJSON(&commentForm)
+	err := c.ShouldBindJSON(&commentForm)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+
+	post, err := s.storeFor(c).GetPost(postID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{})
+		renderError(c, err)
+		return
+	}
+	if !s.readable(c, post) {
 		return
 	}
 
 	comment := model.Comment{ID: xid.New().String(), Content: commentForm.Content, UserID: ID, PostID: postID, CreatedAt: time.Now().Format("2006-01-02 15:04:05")}
-	err = comment.Insert()
+	err = s.store.InsertComment(&comment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "comment.create", "comment", comment.ID, nil, comment)
 	c.JSON(http.StatusOK, comment)
 }
 
-func DeleteComment(c *gin.Context) {
+func (s *Server) DeleteComment(c *gin.Context) {
 	commentID := c.Param("commentID")
-	ID := c.GetHeader("id")
-	comment, err := model.GetComment(commentID)
+	comment, err := s.storeFor(c).GetComment(commentID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-	if ID != comment.ID {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	if _, ok := s.authorize(c, policy.DeleteComment, policy.Resource{Comment: &comment}); !ok {
 		return
 	}
-	err = comment.Delete()
+	err = s.store.DeleteComment(&comment)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "comment.delete", "comment", comment.ID, comment, nil)
 	c.JSON(http.StatusOK, gin.H{})
 }
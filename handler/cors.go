@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"strings"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// cors answers CORS requests to the API, including preflights, which match
+// no route. It returns nil when no origin is allowed. Pages are left alone.
+func (s *Server) cors() gin.HandlerFunc {
+	c := s.config.CORS
+	if len(c.AllowOrigins) == 0 {
+		return nil
+	}
+	config := cors.Config{
+		AllowMethods:     c.AllowMethods,
+		AllowHeaders:     c.AllowHeaders,
+		ExposeHeaders:    c.ExposeHeaders,
+		AllowCredentials: c.AllowCredentials,
+		MaxAge:           c.MaxAge,
+	}
+	for _, origin := range c.AllowOrigins {
+		if origin == "*" {
+			config.AllowAllOrigins = true
+		}
+	}
+	if !config.AllowAllOrigins {
+		config.AllowOrigins = c.AllowOrigins
+	}
+	handle := cors.New(config)
+	prefix := "/" + s.config.Routes.API + "/"
+	return func(c *gin.Context) {
+		if strings.HasPrefix(c.Request.URL.Path, prefix) {
+			handle(c)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/config"
+)
+
+func TestCORS(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.CORS.AllowOrigins = []string{"https://app.example"}
+		c.CORS.AllowCredentials = true
+	})
+	send := func(method, path, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Origin", origin)
+		if method == "OPTIONS" {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+			req.Header.Set("Access-Control-Request-Headers", "Content-Type, X-CSRF-Token")
+		}
+		rec := httptest.NewRecorder()
+		ts.server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := send("OPTIONS", api+"profile", "https://app.example")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example" || rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Allow-Methods") == "" {
+		t.Fatalf("preflight = %d %v", rec.Code, rec.Header())
+	}
+	rec = send("GET", api+"users", "https://app.example")
+	if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.example" || rec.Header().Get("Access-Control-Expose-Headers") == "" {
+		t.Fatalf("GET = %d %v", rec.Code, rec.Header())
+	}
+	rec = send("OPTIONS", api+"profile", "https://evil.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("preflight from another origin = %d %v", rec.Code, rec.Header())
+	}
+	rec = send("GET", "/blog/users", "https://app.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("pages answer CORS: %v", rec.Header())
+	}
+
+	ts = newTestServer(t)
+	rec = send("GET", api+"users", "https://app.example")
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("CORS without allowed origins: %v", rec.Header())
+	}
+}
+
+func TestCSRFToken(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.Auth.SessionSecret = testSessionSecret
+	})
+	a, err := auth.New(config.Auth{SessionSecret: testSessionSecret, SessionCookie: "go_blog_session", SessionTTL: time.Hour}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := a.NewSession("alice")
+
+	ts.expectError(ts.do("GET", api+"csrf", "alice", nil), http.StatusUnauthorized, "no_session")
+	req := httptest.NewRequest("GET", api+"csrf", nil)
+	req.AddCookie(cookie)
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	var body struct {
+		Token string `json:"token"`
+	}
+	ts.expect(rec, http.StatusOK, &body)
+	if body.Token == "" || body.Token != a.CSRFToken(cookie.Value) {
+		t.Fatalf("token = %q", body.Token)
+	}
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/model"
+)
+
+// checkCSRF refuses changes made with a session cookie unless the request
+// repeats the session's CSRF token. Bearer tokens and the proxy header are
+// never sent by a browser on its own, so they need no token.
+func (s *Server) checkCSRF(c *gin.Context) {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return
+	}
+	if principal(c).Method == auth.MethodSession && !s.auth.VerifyCSRF(c.Request) {
+		renderError(c, model.Forbidden("csrf_failed", "send the session's CSRF token in the "+auth.CSRFHeader+" header"))
+	}
+}
+
+// startSession signs userID in with a session cookie, and hands out its
+// CSRF token both as a cookie and, for pages on other origins that cannot
+// read the cookie, as a header.
+func (s *Server) startSession(c *gin.Context, userID string) {
+	session := s.auth.NewSession(userID)
+	http.SetCookie(c.Writer, session)
+	csrf := s.auth.CSRFCookie(session)
+	http.SetCookie(c.Writer, csrf)
+	c.Header(auth.CSRFHeader, csrf.Value)
+}
+
+func (s *Server) endSession(c *gin.Context) {
+	http.SetCookie(c.Writer, s.auth.ClearSession())
+	http.SetCookie(c.Writer, s.auth.ClearCSRFCookie())
+}
+
+// GetCSRFToken returns the CSRF token of the session cookie sent with the
+// request.
+func (s *Server) GetCSRFToken(c *gin.Context) {
+	if principal(c).Method != auth.MethodSession {
+		renderError(c, model.Unauthorized("no_session", "sign in to get a CSRF token"))
+		return
+	}
+	cookie, _ := c.Request.Cookie(s.config.Auth.SessionCookie)
+	c.JSON(http.StatusOK, gin.H{"token": s.auth.CSRFToken(cookie.Value)})
+}
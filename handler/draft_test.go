@@ -0,0 +1,100 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestDrafts(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary", "bob")
+	ts.createPost("alice", project.ID, "public")
+
+	var draft model.Post
+	ts.expect(ts.do("POST", api+"projects/"+project.ID+"/posts", "alice", gin.H{"title": "secret", "content": "not yet", "status": "draft"}), http.StatusOK, &draft)
+	if draft.Status != model.PostDraft || draft.PublishAt != "" {
+		t.Fatalf("draft = %+v", draft)
+	}
+
+	var posts []model.Post
+	for _, path := range []string{"posts", "users/alice/posts", "projects/" + project.ID + "/posts"} {
+		ts.expect(ts.do("GET", api+path, "alice", nil), http.StatusOK, &posts)
+		if len(posts) != 1 || posts[0].Title != "public" {
+			t.Fatalf("%s lists %+v", path, posts)
+		}
+	}
+	ts.expect(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusOK, &project)
+	if project.PostCount != 1 {
+		t.Fatalf("post count = %d, want drafts left out", project.PostCount)
+	}
+
+	// only the author and the project's editors see a draft
+	ts.expect(ts.do("GET", api+"posts/"+draft.ID, "alice", nil), http.StatusOK, nil)
+	ts.expectError(ts.do("GET", api+"posts/"+draft.ID, "", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("GET", api+"posts/"+draft.ID, "bob", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("GET", api+"posts/"+draft.ID+"/comments", "bob", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("POST", api+"posts/"+draft.ID+"/comments", "bob", gin.H{"content": "first"}), http.StatusNotFound, "post_not_found")
+	note := ts.createComment("alice", draft.ID, "remember the photos")
+	ts.expectError(ts.do("GET", api+"comments/"+note.ID, "bob", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("PUT", api+"posts/"+draft.ID, "bob", gin.H{"newTitle": "mine"}), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("GET", api+"projects/"+project.ID+"/post?id=1", "bob", nil), http.StatusNotFound, "post_not_found")
+	if rec := ts.do("GET", "/blog/projects/diary/posts/1", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("draft page = %d, want 404", rec.Code)
+	}
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID+"/members/bob", "alice", gin.H{"role": "editor"}), http.StatusOK, nil)
+	ts.expect(ts.do("GET", api+"posts/"+draft.ID, "bob", nil), http.StatusOK, nil)
+
+	ts.expectError(ts.do("GET", api+"drafts", "", nil), http.StatusUnauthorized, "unauthorized")
+	ts.expect(ts.do("GET", api+"drafts", "bob", nil), http.StatusOK, &posts)
+	if len(posts) != 0 {
+		t.Fatalf("bob's drafts = %+v", posts)
+	}
+	ts.expect(ts.do("GET", api+"drafts", "alice", nil), http.StatusOK, &posts)
+	if len(posts) != 1 || posts[0].ID != draft.ID {
+		t.Fatalf("alice's drafts = %+v", posts)
+	}
+
+	var published model.Post
+	ts.expect(ts.do("PUT", api+"posts/"+draft.ID, "alice", gin.H{"newStatus": "published"}), http.StatusOK, &published)
+	if published.Status != model.PostPublished || published.PublishAt == "" || published.CreatedAt != published.PublishAt {
+		t.Fatalf("published = %+v", published)
+	}
+	ts.expect(ts.do("GET", api+"posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 2 || posts[0].ID != draft.ID {
+		t.Fatalf("posts = %+v, want the published draft first", posts)
+	}
+}
+
+func TestScheduledPosts(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+	posts := api + "projects/" + project.ID + "/posts"
+
+	ts.expectError(ts.do("POST", posts, "alice", gin.H{"title": "late", "content": "c", "publishAt": "2001-01-01 00:00:00"}), http.StatusBadRequest, "invalid_publish_at")
+	ts.expectError(ts.do("POST", posts, "alice", gin.H{"title": "vague", "content": "c", "publishAt": "tomorrow"}), http.StatusBadRequest, "invalid_publish_at")
+	ts.expectError(ts.do("POST", posts, "alice", gin.H{"title": "odd", "content": "c", "status": "pending"}), http.StatusBadRequest, "invalid_body")
+
+	at := time.Now().Add(time.Hour).Format("2006-01-02 15:04:05")
+	var scheduled model.Post
+	ts.expect(ts.do("POST", posts, "alice", gin.H{"title": "soon", "content": "c", "publishAt": at}), http.StatusOK, &scheduled)
+	if scheduled.Status != model.PostScheduled || scheduled.PublishAt != at {
+		t.Fatalf("scheduled = %+v", scheduled)
+	}
+	ts.expectError(ts.do("GET", api+"posts/"+scheduled.ID, "", nil), http.StatusNotFound, "post_not_found")
+
+	if n, _ := ts.store.PublishDue(time.Now()); n != 0 {
+		t.Fatalf("published %d posts before their time", n)
+	}
+	if n, _ := ts.store.PublishDue(time.Now().Add(2 * time.Hour)); n != 1 {
+		t.Fatalf("published %d posts, want 1", n)
+	}
+	var post model.Post
+	ts.expect(ts.do("GET", api+"posts/"+scheduled.ID, "", nil), http.StatusOK, &post)
+	if post.Status != model.PostPublished || post.CreatedAt != at {
+		t.Fatalf("post = %+v, want it published and dated %s", post, at)
+	}
+}
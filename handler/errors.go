@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/n-inja/go-blog/model"
+)
+
+type errorResponse struct {
+	Code    string            `json:"code"`
+	Message string            `json:"message"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// renderError answers with the status and JSON body matching err. Errors
+// that are not model errors are logged and hidden behind a generic 500.
+func renderError(c *gin.Context, err error) {
+	var e *model.Error
+	if !errors.As(err, &e) {
+		log.Println(c.Request.Method, c.Request.URL.Path, err)
+		e = &model.Error{Code: "internal", Message: "internal server error"}
+	}
+	c.AbortWithStatusJSON(statusOf(e.Kind), errorResponse{Code: e.Code, Message: e.Message, Fields: e.Fields})
+}
+
+func statusOf(kind error) int {
+	switch kind {
+	case model.ErrNotFound:
+		return http.StatusNotFound
+	case model.ErrConflict:
+		return http.StatusConflict
+	case model.ErrValidation:
+		return http.StatusBadRequest
+	case model.ErrUnauthorized:
+		return http.StatusUnauthorized
+	case model.ErrForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// bindError describes why a request body could not be bound.
+func bindError(err error) error {
+	var invalid validator.ValidationErrors
+	if !errors.As(err, &invalid) {
+		return model.Validation("invalid_body", "request body is not valid JSON", nil)
+	}
+	fields := map[string]string{}
+	for _, field := range invalid {
+		name := field.Field()
+		fields[strings.ToLower(name[:1])+name[1:]] = field.Tag()
+	}
+	return model.Validation("invalid_body", "request body has invalid fields", fields)
+}
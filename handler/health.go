@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Healthz reports that the process is up and serving requests.
+func (s *Server) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz reports whether the server can handle traffic: the store answers
+// and the templates and static assets were loaded.
+func (s *Server) Readyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+
+	checks["store"] = "ok"
+	if err := s.store.Ping(); err != nil {
+		checks["store"] = err.Error()
+		ready = false
+	}
+	checks["templates"] = "ok"
+	if s.templates == nil || s.templates.Lookup("index.tmpl") == nil {
+		checks["templates"] = "index.tmpl not loaded"
+		ready = false
+	}
+	checks["static"] = "ok"
+	if len(s.files) == 0 {
+		checks["static"] = "no static assets loaded"
+		ready = false
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+}
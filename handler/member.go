@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/policy"
+)
+
+func (s *Server) GetMembers(c *gin.Context) {
+	projectID := c.Param("projectID")
+	project, err := s.storeFor(c).GetProject(projectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, project.Members())
+}
+
+type memberForm struct {
+	Role string `json:"role" form:"role" binding:"required"`
+}
+
+func (s *Server) PutMember(c *gin.Context) {
+	projectID := c.Param("projectID")
+	userID := c.Param("userID")
+	project, err := s.storeFor(c).GetProject(projectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if _, ok := s.authorize(c, policy.ManageMembers, policy.Resource{Project: &project}); !ok {
+		return
+	}
+	var body memberForm
+	err = c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	err = s.store.SetMember(projectID, userID, model.MemberRole(body.Role))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	var before interface{}
+	if role, ok := project.Roles[userID]; ok {
+		before = model.Member{UserID: userID, Role: role}
+	}
+	s.audit(c, "member.set", "project", projectID, before, model.Member{UserID: userID, Role: model.MemberRole(body.Role)})
+	project, err = s.store.Primary().GetProject(projectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, project.Members())
+}
+
+func (s *Server) DeleteMember(c *gin.Context) {
+	projectID := c.Param("projectID")
+	userID := c.Param("userID")
+	project, err := s.storeFor(c).GetProject(projectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	if _, ok := s.authorize(c, policy.ManageMembers, policy.Resource{Project: &project}); !ok {
+		return
+	}
+	err = s.store.RemoveMember(projectID, userID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.audit(c, "member.remove", "project", projectID, model.Member{UserID: userID, Role: project.Roles[userID]}, nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
@@ -0,0 +1,269 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/policy"
+	"github.com/rs/xid"
+)
+
+// moderator lets only admins through to the moderation API.
+func (s *Server) moderator(c *gin.Context) {
+	if _, ok := s.authorize(c, policy.Moderate, policy.Resource{}); !ok {
+		return
+	}
+	c.Next()
+}
+
+// moderationFilter reads the filters of a moderation listing. It renders
+// the error itself and returns false when the state is unknown.
+func moderationFilter(c *gin.Context) (model.ModerationFilter, bool) {
+	filter := model.ModerationFilter{
+		State:     c.Query("state"),
+		ProjectID: c.Query("projectId"),
+		PostID:    c.Query("postId"),
+		UserID:    c.Query("userId"),
+		Query:     c.Query("q"),
+	}
+	switch filter.State {
+	case "", model.StateLive, model.StateHidden, model.StateDeleted:
+		return filter, true
+	}
+	renderError(c, model.Validation("invalid_query", "state should be live, hidden or deleted", map[string]string{"state": "must be live, hidden or deleted"}))
+	return filter, false
+}
+
+func (s *Server) GetModerationPosts(c *gin.Context) {
+	filter, ok := moderationFilter(c)
+	if !ok {
+		return
+	}
+	page, limit, ok := parsePage(c, 20)
+	if !ok {
+		return
+	}
+	posts, err := s.store.GetModerationPosts(filter, page)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	writePage(c, limit, posts, func(post model.ModeratedPost) (string, string) {
+		return post.CreatedAt, post.ID
+	})
+}
+
+func (s *Server) GetModerationComments(c *gin.Context) {
+	filter, ok := moderationFilter(c)
+	if !ok {
+		return
+	}
+	page, limit, ok := parsePage(c, 20)
+	if !ok {
+		return
+	}
+	comments, err := s.store.GetModerationComments(filter, page)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	writePage(c, limit, comments, func(comment model.ModeratedComment) (string, string) {
+		return comment.CreatedAt, comment.ID
+	})
+}
+
+func (s *Server) GetModerationActions(c *gin.Context) {
+	page, limit, ok := parsePage(c, 20)
+	if !ok {
+		return
+	}
+	targetType := c.Query("targetType")
+	if targetType != "" && c.Query("targetId") == "" {
+		renderError(c, model.Validation("invalid_query", "targetId is required with targetType", map[string]string{"targetId": "is required"}))
+		return
+	}
+	actions, err := s.store.GetModerationActions(targetType, c.Query("targetId"), page)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	writePage(c, limit, actions, func(action model.ModerationAction) (string, string) {
+		return action.CreatedAt, action.ID
+	})
+}
+
+type moderationForm struct {
+	Reason string `json:"reason" form:"reason" binding:"required,max=255"`
+}
+
+// moderate handles an action on the target named by the route parameter:
+// it reads the reason and runs act, which stores the record of who did it
+// along with the change.
+func (s *Server) moderate(action, targetType, param string, act func(c *gin.Context, targetID string, record *model.ModerationAction) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body moderationForm
+		err := c.ShouldBindJSON(&body)
+		if err != nil {
+			renderError(c, bindError(err))
+			return
+		}
+		targetID := c.Param(param)
+		before, err := s.moderationTarget(targetType, targetID)
+		if err != nil {
+			renderError(c, err)
+			return
+		}
+		record := moderationRecord(c, action, targetType, targetID, body.Reason, "")
+		err = act(c, targetID, &record)
+		if err != nil {
+			renderError(c, err)
+			return
+		}
+		s.moderated(c, record, before)
+	}
+}
+
+// moderationRecord describes an action by the current user, to be stored
+// with it.
+func moderationRecord(c *gin.Context, action, targetType, targetID, reason, detail string) model.ModerationAction {
+	return model.ModerationAction{
+		ID:         xid.New().String(),
+		ActorID:    currentUserID(c),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Detail:     detail,
+		CreatedAt:  time.Now().Format("2006-01-02 15:04:05"),
+	}
+}
+
+// userRole is what moderation changes about a user.
+type userRole struct {
+	ID   string     `json:"id"`
+	Role model.Role `json:"role"`
+}
+
+// moderationTarget loads the target of an action from the primary, hidden
+// and deleted items included, as the audit log snapshots it.
+func (s *Server) moderationTarget(targetType, targetID string) (interface{}, error) {
+	store := s.store.Primary()
+	switch targetType {
+	case "post":
+		return store.GetModerationPost(targetID)
+	case "comment":
+		return store.GetModerationComment(targetID)
+	case "user":
+		role, err := store.GetRole(targetID)
+		return userRole{ID: targetID, Role: role}, err
+	case "project":
+		return store.GetProject(targetID)
+	}
+	return nil, errors.New("unknown moderation target: " + targetType)
+}
+
+// moderated audits a stored action with the target as it was before and
+// is now, and renders the action as the response.
+func (s *Server) moderated(c *gin.Context, record model.ModerationAction, before interface{}) {
+	after, err := s.moderationTarget(record.TargetType, record.TargetID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.audit(c, record.Action, record.TargetType, record.TargetID, before, after)
+	c.JSON(http.StatusOK, record)
+}
+
+func (s *Server) hidePost(c *gin.Context, postID string, record *model.ModerationAction) error {
+	return s.store.SetPostHidden(postID, true, record)
+}
+
+func (s *Server) unhidePost(c *gin.Context, postID string, record *model.ModerationAction) error {
+	return s.store.SetPostHidden(postID, false, record)
+}
+
+func (s *Server) restorePost(c *gin.Context, postID string, record *model.ModerationAction) error {
+	return s.store.RestorePost(postID, record)
+}
+
+func (s *Server) hideComment(c *gin.Context, commentID string, record *model.ModerationAction) error {
+	return s.store.SetCommentHidden(commentID, true, record)
+}
+
+func (s *Server) unhideComment(c *gin.Context, commentID string, record *model.ModerationAction) error {
+	return s.store.SetCommentHidden(commentID, false, record)
+}
+
+func (s *Server) restoreComment(c *gin.Context, commentID string, record *model.ModerationAction) error {
+	return s.store.RestoreComment(commentID, record)
+}
+
+func (s *Server) suspendUser(c *gin.Context, userID string, record *model.ModerationAction) error {
+	if userID == currentUserID(c) {
+		return model.Validation("self_suspend", "admins cannot suspend themselves", map[string]string{"userId": "is you"})
+	}
+	return s.store.SuspendUser(userID, record)
+}
+
+type reinstateForm struct {
+	Reason string `json:"reason" form:"reason" binding:"required,max=255"`
+	// Role replaces the suspension; empty returns the user to the role of
+	// their account.
+	Role model.Role `json:"role" form:"role"`
+}
+
+func (s *Server) ReinstateUser(c *gin.Context) {
+	var body reinstateForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	userID := c.Param("userID")
+	if body.Role == model.RoleSuspended {
+		renderError(c, model.Validation("invalid_role", "role must not be suspended", map[string]string{"role": "must not be suspended"}))
+		return
+	}
+	before, err := s.moderationTarget("user", userID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	record := moderationRecord(c, "user.reinstate", "user", userID, body.Reason, string(body.Role))
+	err = s.store.ReinstateUser(userID, body.Role, &record)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.moderated(c, record, before)
+}
+
+type transferForm struct {
+	UserID string `json:"userId" form:"userId" binding:"required"`
+	Reason string `json:"reason" form:"reason" binding:"required,max=255"`
+}
+
+func (s *Server) TransferProject(c *gin.Context) {
+	var body transferForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	projectID := c.Param("projectID")
+	before, err := s.moderationTarget("project", projectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	record := moderationRecord(c, "project.transfer", "project", projectID, body.Reason, body.UserID)
+	err = s.store.TransferProject(projectID, body.UserID, &record)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.moderated(c, record, before)
+}
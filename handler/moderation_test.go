@@ -0,0 +1,118 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestModerateContent(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.SetRole("alice", model.RoleAdmin)
+	project := ts.createProject("bob", "diary")
+	post := ts.createPost("bob", project.ID, "first")
+	comment := ts.createComment("carol", post.ID, "spam spam spam")
+	reason := gin.H{"reason": "spam"}
+
+	ts.expectError(ts.do("GET", api+"admin/comments", "bob", nil), http.StatusForbidden, "forbidden")
+	ts.expectError(ts.do("POST", api+"admin/comments/"+comment.ID+"/hide", "bob", reason), http.StatusForbidden, "forbidden")
+	ts.expectError(ts.do("POST", api+"admin/comments/"+comment.ID+"/hide", "alice", nil), http.StatusBadRequest, "invalid_body")
+	ts.expectError(ts.do("POST", api+"admin/comments/missing/hide", "alice", reason), http.StatusNotFound, "comment_not_found")
+
+	var action model.ModerationAction
+	ts.expect(ts.do("POST", api+"admin/comments/"+comment.ID+"/hide", "alice", reason), http.StatusOK, &action)
+	if action.ActorID != "alice" || action.Action != "comment.hide" || action.TargetID != comment.ID || action.Reason != "spam" {
+		t.Fatalf("action = %+v", action)
+	}
+	events, err := ts.store.GetAuditEvents(model.AuditFilter{Action: "comment.hide"}, model.Page{Limit: 1})
+	if err != nil || len(events) != 1 || !strings.Contains(string(events[0].Before), `"state":"live"`) || !strings.Contains(string(events[0].After), `"state":"hidden"`) {
+		t.Fatalf("hide events = %+v, %v", events, err)
+	}
+	ts.expectError(ts.do("GET", api+"comments/"+comment.ID, "", nil), http.StatusNotFound, "comment_not_found")
+	var counted model.Post
+	ts.expect(ts.do("GET", api+"posts/"+post.ID, "", nil), http.StatusOK, &counted)
+	if counted.CommentNum != 0 {
+		t.Fatalf("hidden comments are counted: %d", counted.CommentNum)
+	}
+
+	var comments []model.ModeratedComment
+	ts.expect(ts.do("GET", api+"admin/comments?state=hidden&q=spam", "alice", nil), http.StatusOK, &comments)
+	if len(comments) != 1 || comments[0].ID != comment.ID || comments[0].State != model.StateHidden {
+		t.Fatalf("hidden comments = %+v", comments)
+	}
+	ts.expect(ts.do("GET", api+"admin/comments?state=live", "alice", nil), http.StatusOK, &comments)
+	if len(comments) != 0 {
+		t.Fatalf("live comments = %+v", comments)
+	}
+	ts.expectError(ts.do("GET", api+"admin/comments?state=gone", "alice", nil), http.StatusBadRequest, "invalid_query")
+
+	ts.expect(ts.do("POST", api+"admin/comments/"+comment.ID+"/unhide", "alice", gin.H{"reason": "not spam after all"}), http.StatusOK, nil)
+	ts.expect(ts.do("GET", api+"comments/"+comment.ID, "", nil), http.StatusOK, nil)
+
+	// a deleted post comes back with its comments
+	ts.expect(ts.do("DELETE", api+"posts/"+post.ID, "bob", nil), http.StatusOK, nil)
+	var posts []model.ModeratedPost
+	ts.expect(ts.do("GET", api+"admin/posts?state=deleted&userId=bob", "alice", nil), http.StatusOK, &posts)
+	if len(posts) != 1 || posts[0].ID != post.ID {
+		t.Fatalf("deleted posts = %+v", posts)
+	}
+	ts.expectError(ts.do("POST", api+"admin/comments/"+comment.ID+"/restore", "alice", reason), http.StatusConflict, "post_deleted")
+	ts.expect(ts.do("POST", api+"admin/posts/"+post.ID+"/restore", "alice", gin.H{"reason": "deleted by mistake"}), http.StatusOK, nil)
+	ts.expectError(ts.do("POST", api+"admin/posts/"+post.ID+"/restore", "alice", reason), http.StatusConflict, "not_deleted")
+	ts.expect(ts.do("GET", api+"posts/"+post.ID, "", nil), http.StatusOK, &counted)
+	if counted.CommentNum != 1 {
+		t.Fatalf("restored post has %d comments, want 1", counted.CommentNum)
+	}
+
+	ts.expect(ts.do("POST", api+"admin/posts/"+post.ID+"/hide", "alice", reason), http.StatusOK, nil)
+	var listed []model.Post
+	ts.expect(ts.do("GET", api+"projects/"+project.ID+"/posts", "", nil), http.StatusOK, &listed)
+	if len(listed) != 0 {
+		t.Fatalf("hidden posts are listed: %+v", listed)
+	}
+	ts.expectError(ts.do("GET", api+"posts/"+post.ID+"/comments", "", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("GET", api+"comments/"+comment.ID, "", nil), http.StatusNotFound, "post_not_found")
+
+	var actions []model.ModerationAction
+	ts.expect(ts.do("GET", api+"admin/actions?targetType=comment&targetId="+comment.ID, "alice", nil), http.StatusOK, &actions)
+	if len(actions) != 2 || actions[0].Action != "comment.unhide" {
+		t.Fatalf("comment actions = %+v", actions)
+	}
+}
+
+func TestModerateUsers(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.SetRole("alice", model.RoleAdmin)
+	project := ts.createProject("bob", "diary")
+	reason := gin.H{"reason": "abuse"}
+
+	ts.expectError(ts.do("POST", api+"admin/users/alice/suspend", "alice", reason), http.StatusBadRequest, "self_suspend")
+	ts.expect(ts.do("POST", api+"admin/users/bob/suspend", "alice", reason), http.StatusOK, nil)
+	ts.expectError(ts.do("POST", api+"projects/"+project.ID+"/posts", "bob", gin.H{"title": "t", "content": "c"}), http.StatusForbidden, "suspended")
+
+	ts.expect(ts.do("POST", api+"admin/users/bob/reinstate", "alice", gin.H{"reason": "appeal"}), http.StatusOK, nil)
+	if role, _ := ts.store.GetRole("bob"); role != model.RoleAuthor {
+		t.Fatalf("reinstated role = %q, want author", role)
+	}
+	ts.expect(ts.do("POST", api+"admin/users/bob/suspend", "alice", reason), http.StatusOK, nil)
+	ts.expect(ts.do("POST", api+"admin/users/bob/reinstate", "alice", gin.H{"reason": "probation", "role": "commenter"}), http.StatusOK, nil)
+	if role, _ := ts.store.GetRole("bob"); role != model.RoleCommenter {
+		t.Fatalf("reinstated role = %q, want commenter", role)
+	}
+
+	// bob's project goes to carol; bob stays on as an editor
+	ts.expectError(ts.do("POST", api+"admin/projects/"+project.ID+"/transfer", "alice", gin.H{"userId": "nobody", "reason": "x"}), http.StatusNotFound, "user_not_found")
+	var action model.ModerationAction
+	ts.expect(ts.do("POST", api+"admin/projects/"+project.ID+"/transfer", "alice", gin.H{"userId": "carol", "reason": "abandoned"}), http.StatusOK, &action)
+	if action.Detail != "carol" {
+		t.Fatalf("transfer action = %+v", action)
+	}
+	var transferred model.Project
+	ts.expect(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusOK, &transferred)
+	if transferred.UserID != "carol" || transferred.Roles["carol"] != model.MemberOwner || transferred.Roles["bob"] != model.MemberEditor {
+		t.Fatalf("transferred project = %+v", transferred)
+	}
+}
@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/model"
+	"github.com/rs/xid"
+)
+
+const oauthStateCookie = "go_blog_oauth_state"
+
+var invalidUserIDChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+func (s *Server) oauthRedirectURL(provider auth.Provider) string {
+	return "https://" + s.config.Site.Hostname + "/" + s.config.Routes.API + "/oauth/" + provider.Name() + "/callback"
+}
+
+func (s *Server) provider(c *gin.Context) (auth.Provider, bool) {
+	provider, ok := s.providers[c.Param("provider")]
+	if !ok {
+		renderError(c, model.NotFound("provider_not_found", "login provider not found"))
+	}
+	return provider, ok
+}
+
+// OAuthLogin sends the browser to the provider, remembering the state in a
+// short lived cookie.
+func (s *Server) OAuthLogin(c *gin.Context) {
+	provider, ok := s.provider(c)
+	if !ok {
+		return
+	}
+	state, err := auth.NewState()
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/" + s.config.Routes.API + "/oauth",
+		MaxAge:   600,
+		Secure:   true,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(state, s.oauthRedirectURL(provider)))
+}
+
+// OAuthCallback signs in the user linked to the provider account. An
+// unlinked account is linked to the signed in user, or gets a new user when
+// local accounts are enabled.
+func (s *Server) OAuthCallback(c *gin.Context) {
+	provider, ok := s.provider(c)
+	if !ok {
+		return
+	}
+	state, err := c.Cookie(oauthStateCookie)
+	http.SetCookie(c.Writer, &http.Cookie{Name: oauthStateCookie, Value: "", Path: "/" + s.config.Routes.API + "/oauth", MaxAge: -1, Secure: true, HttpOnly: true})
+	if err != nil || state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(c.Query("state"))) != 1 {
+		renderError(c, model.Validation("invalid_state", "the login expired or was started elsewhere; try again", nil))
+		return
+	}
+	if c.Query("error") != "" {
+		renderError(c, model.Unauthorized("oauth_denied", provider.Name()+" login was cancelled: "+c.Query("error")))
+		return
+	}
+	identity, err := provider.Identify(c.Request.Context(), c.Query("code"), s.oauthRedirectURL(provider))
+	if err != nil {
+		renderError(c, model.Unauthorized("oauth_failed", err.Error()))
+		return
+	}
+
+	store := s.store.Primary()
+	userID, err := store.GetIdentityUser(identity.Provider, identity.Subject)
+	if errors.Is(err, model.ErrNotFound) {
+		userID, err = s.linkIdentity(c, identity)
+	}
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	githubID := ""
+	if identity.Provider == "github" {
+		githubID = identity.Login
+	}
+	err = store.SyncProfile(userID, githubID, identity.AvatarURL)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.startSession(c, userID)
+	c.Redirect(http.StatusFound, s.pageURL("/mypage"))
+}
+
+// linkIdentity links identity to the signed in user, or to a new account.
+func (s *Server) linkIdentity(c *gin.Context, identity auth.Identity) (string, error) {
+	store := s.store.Primary()
+	if userID := currentUserID(c); userID != "" {
+		err := store.LinkIdentity(identity.Provider, identity.Subject, userID)
+		if err == nil {
+			s.audit(c, "identity.link", "user", userID, nil, gin.H{"provider": identity.Provider, "subject": identity.Subject})
+		}
+		return userID, err
+	}
+	if !s.config.Auth.LocalAccounts {
+		return "", model.Forbidden("no_account", "sign in before linking a "+identity.Provider+" account")
+	}
+	name := identity.Name
+	if name == "" {
+		name = identity.Login
+	}
+	if name == "" {
+		name = identity.Subject
+	}
+	if utf8.RuneCountInString(name) > 64 {
+		name = string([]rune(name)[:64])
+	}
+	user := model.User{Name: name}
+	for _, userID := range candidateUserIDs(identity) {
+		user.ID = userID
+		err := store.CreateIdentityAccount(&user, model.Role(s.config.Auth.SignupRole), identity.Provider, identity.Subject)
+		var taken *model.Error
+		if errors.As(err, &taken) && taken.Code == "user_exists" {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		s.audit(c, "user.create", "user", user.ID, nil, gin.H{"user": user, "provider": identity.Provider, "subject": identity.Subject})
+		return user.ID, nil
+	}
+	return "", model.Conflict("user_exists", "no free user ID for "+identity.Login)
+}
+
+// candidateUserIDs suggests IDs for a new user, starting with the login
+// and ending with a random ID.
+func candidateUserIDs(identity auth.Identity) []string {
+	base := invalidUserIDChars.ReplaceAllString(identity.Login, "-")
+	if len(base) > 28 {
+		base = base[:28]
+	}
+	var IDs []string
+	if base != "" && base != "-" {
+		IDs = append(IDs, base)
+		for i := 2; i < 10; i++ {
+			IDs = append(IDs, base+"-"+strconv.Itoa(i))
+		}
+	}
+	return append(IDs, xid.New().String())
+}
@@ -0,0 +1,178 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/n-inja/go-blog/config"
+)
+
+// stubGitHub serves the token and user endpoints of GitHub. The code is
+// handed back as the access token, and the token picks the user.
+func stubGitHub(t *testing.T, users map[string]interface{}) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login/oauth/access_token", func(w http.ResponseWriter, req *http.Request) {
+		code := req.FormValue("code")
+		if users[code] == nil || req.FormValue("redirect_uri") != "https://blog.example/go-blog/api/v1/oauth/github/callback" {
+			http.Error(w, `{"error":"bad_verification_code"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"access_token": code, "token_type": "bearer"})
+	})
+	mux.HandleFunc("/user", func(w http.ResponseWriter, req *http.Request) {
+		user := users[strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")]
+		if user == nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(user)
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newOAuthServer enables GitHub login against a stub and local accounts;
+// options adjust the configuration further.
+func newOAuthServer(t *testing.T, options ...any) *testServer {
+	github := stubGitHub(t, map[string]interface{}{
+		"octocat": map[string]interface{}{"id": 583231, "login": "octocat", "name": "The Octocat", "avatar_url": "https://avatars.example/u/583231"},
+		"alice":   map[string]interface{}{"id": 1001, "login": "alice-gh", "avatar_url": "https://avatars.example/u/1001"},
+	})
+	return newTestServer(t, append([]any{func(c *config.Config) {
+		c.Auth.SessionSecret = testSessionSecret
+		c.Auth.LocalAccounts = true
+		c.Auth.GitHub = config.OAuth{
+			Name:         "github",
+			ClientID:     "client",
+			ClientSecret: "secret",
+			AuthURL:      github.URL + "/login/oauth/authorize",
+			TokenURL:     github.URL + "/login/oauth/access_token",
+			UserURL:      github.URL + "/user",
+		}
+	}}, options...)...)
+}
+
+// login starts a GitHub login and returns the state and its cookie.
+func (ts *testServer) login() (string, *http.Cookie) {
+	ts.t.Helper()
+	rec := ts.do("GET", api+"oauth/github/login", "", nil)
+	if rec.Code != http.StatusFound {
+		ts.t.Fatalf("login = %d, want 302", rec.Code)
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		ts.t.Fatal(err)
+	}
+	if location.Query().Get("client_id") != "client" || location.Query().Get("redirect_uri") != "https://blog.example/go-blog/api/v1/oauth/github/callback" {
+		ts.t.Fatalf("login redirects to %s", location)
+	}
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == "go_blog_oauth_state" {
+			return location.Query().Get("state"), cookie
+		}
+	}
+	ts.t.Fatal("login sets no state cookie")
+	return "", nil
+}
+
+// callback completes a login started by login, as userID.
+func (ts *testServer) callback(userID, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", api+"oauth/github/callback?code="+code+"&state="+state, nil)
+	if userID != "" {
+		req.Header.Set("id", userID)
+	}
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestOAuthSignup(t *testing.T) {
+	ts := newOAuthServer(t, func(c *config.Config) {
+		c.Auth.SignupRole = "author"
+	})
+
+	state, cookie := ts.login()
+	rec := ts.callback("", "octocat", state, cookie)
+	if rec.Code != http.StatusFound || rec.Header().Get("Location") != "https://blog.example/blog/mypage" {
+		t.Fatalf("callback = %d %s, want a redirect to mypage", rec.Code, rec.Body)
+	}
+	session(t, rec)
+	if role, _ := ts.store.GetRole("octocat"); role != "author" {
+		t.Fatalf("octocat role = %q, want author", role)
+	}
+
+	// signing in again finds the same user
+	state, cookie = ts.login()
+	ts.callback("", "octocat", state, cookie)
+	user, err := ts.store.GetUser("octocat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "The Octocat" || user.GithubId != "octocat" || user.IconSrc != "https://avatars.example/u/583231" {
+		t.Fatalf("octocat = %+v", user)
+	}
+	if _, err := ts.store.GetUser("octocat-2"); err == nil {
+		t.Fatal("a second login created another user")
+	}
+}
+
+func TestOAuthLink(t *testing.T) {
+	ts := newOAuthServer(t, func(c *config.Config) {
+		c.Auth.LocalAccounts = false
+	})
+
+	state, cookie := ts.login()
+	ts.expectError(ts.callback("", "octocat", state, cookie), http.StatusForbidden, "no_account")
+
+	ts.expect(ts.do("PUT", api+"profile", "alice", map[string]string{"newIconSrc": "https://blog.example/alice.png"}), http.StatusOK, nil)
+	state, cookie = ts.login()
+	rec := ts.callback("alice", "alice", state, cookie)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback = %d %s", rec.Code, rec.Body)
+	}
+	user, err := ts.store.GetUser("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.GithubId != "alice-gh" || user.IconSrc != "https://blog.example/alice.png" {
+		t.Fatalf("alice = %+v, want github_id filled and the icon kept", user)
+	}
+
+	// the GitHub account now signs in as alice, even without the proxy
+	state, cookie = ts.login()
+	rec = ts.callback("", "alice", state, cookie)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("callback = %d %s", rec.Code, rec.Body)
+	}
+	ts.expect(ts.profileAs(func(req *http.Request) {
+		req.AddCookie(session(t, rec))
+		req.Header.Set("X-CSRF-Token", rec.Header().Get("X-CSRF-Token"))
+	}), http.StatusOK, nil)
+
+	// a user links one account per provider
+	state, cookie = ts.login()
+	ts.expectError(ts.callback("alice", "octocat", state, cookie), http.StatusConflict, "identity_linked")
+}
+
+func TestOAuthState(t *testing.T) {
+	ts := newOAuthServer(t)
+	state, cookie := ts.login()
+	ts.expectError(ts.callback("", "octocat", state, nil), http.StatusBadRequest, "invalid_state")
+	ts.expectError(ts.callback("", "octocat", "forged", cookie), http.StatusBadRequest, "invalid_state")
+	ts.expectError(ts.callback("", "unknown", state, cookie), http.StatusUnauthorized, "oauth_failed")
+	ts.expectError(ts.do("GET", api+"oauth/gitlab/login", "", nil), http.StatusNotFound, "provider_not_found")
+
+	ts = newTestServer(t)
+	if rec := ts.do("GET", api+"oauth/github/login", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("login without providers = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+// pageResponse is the envelope returned to clients paging by cursor.
+type pageResponse struct {
+	Items      interface{} `json:"items"`
+	NextCursor *string     `json:"nextCursor"`
+}
+
+// parsePage reads limit and offset, or limit and cursor when the client
+// pages by cursor. In cursor mode one extra item is fetched so that the
+// handler knows whether there is a next page. It renders the error itself
+// and returns false when the query is invalid.
+func parsePage(c *gin.Context, defaultLimit int) (model.Page, int, bool) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultLimit)))
+	if err != nil {
+		limit = 10
+	}
+	rawCursor, byCursor := c.GetQuery("cursor")
+	if !byCursor {
+		offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+		if err != nil {
+			offset = 0
+		}
+		if limit < 0 || offset < 0 {
+			renderError(c, model.Validation("invalid_query", "limit and offset should not be negative", nil))
+			return model.Page{}, 0, false
+		}
+		return model.Page{Offset: offset, Limit: limit}, limit, true
+	}
+
+	if limit < 1 {
+		renderError(c, model.Validation("invalid_query", "limit should be positive", map[string]string{"limit": "must be at least 1"}))
+		return model.Page{}, 0, false
+	}
+	page := model.Page{Limit: limit + 1}
+	if rawCursor != "" {
+		cursor, err := model.DecodeCursor(rawCursor)
+		if err != nil {
+			renderError(c, err)
+			return model.Page{}, 0, false
+		}
+		page.Cursor = &cursor
+	}
+	return page, limit, true
+}
+
+func byCursor(c *gin.Context) bool {
+	_, ok := c.GetQuery("cursor")
+	return ok
+}
+
+// writePage renders items fetched with parsePage: as they are when paging
+// by offset, or cut to limit in a pageResponse whose cursor points past the
+// last item when paging by cursor. key returns what an item is ordered by.
+func writePage[T any](c *gin.Context, limit int, items []T, key func(T) (createdAt, ID string)) {
+	if !byCursor(c) {
+		c.JSON(http.StatusOK, items)
+		return
+	}
+	var next *string
+	if len(items) > limit {
+		items = items[:limit]
+		createdAt, ID := key(items[limit-1])
+		cursor := model.Cursor{CreatedAt: createdAt, ID: ID}.Encode()
+		next = &cursor
+	}
+	c.JSON(http.StatusOK, pageResponse{Items: items, NextCursor: next})
+}
+
+func postKey(post model.Post) (string, string) {
+	return post.CreatedAt, post.ID
+}
+
+func commentKey(comment model.Comment) (string, string) {
+	return comment.CreatedAt, comment.ID
+}
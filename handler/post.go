@@ -1,189 +1,271 @@
 package handler
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/n-inja/go-blog/model"
-	"github.com/n-inja/go-blog/utils"
+	"github.com/n-inja/go-blog/policy"
 	"github.com/rs/xid"
 )
 
-func GetUserPosts(c *gin.Context) {
+func (s *Server) GetUserPosts(c *gin.Context) {
 	userID := c.Param("userID")
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil {
-		limit = 10
-	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	if err != nil {
-		offset = 0
+	tags, ok := s.tagFilter(c)
+	if !ok {
+		return
 	}
-	if limit < 0 || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{})
+	page, limit, ok := parsePage(c, 10)
+	if !ok {
 		return
 	}
-	posts, err := model.GetUserPosts(userID, offset, limit)
+	posts, err := s.storeFor(c).GetUserPosts(userID, tags, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	s.freshen(posts)
+	writePage(c, limit, posts, postKey)
 }
 
-func GetProjectPosts(c *gin.Context) {
+func (s *Server) GetProjectPosts(c *gin.Context) {
 	projectID := c.Param("projectID")
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if err != nil {
-		limit = 10
-	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	if err != nil {
-		offset = 0
+	tags, ok := s.tagFilter(c)
+	if !ok {
+		return
 	}
-	if limit < 0 || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{})
+	page, limit, ok := parsePage(c, 10)
+	if !ok {
 		return
 	}
-	posts, err := model.GetProjectPosts(projectID, offset, limit)
+	posts, err := s.storeFor(c).GetProjectPosts(projectID, tags, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	s.freshen(posts)
+	writePage(c, limit, posts, postKey)
 }
 
-func GetPosts(c *gin.Context) {
-	limit, err := strconv.Atoi(c.DefaultQuery("limit", "3"))
-	if err != nil {
-		limit = 10
-	}
-	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
-	if err != nil {
-		offset = 0
+func (s *Server) GetPosts(c *gin.Context) {
+	tags, ok := s.tagFilter(c)
+	if !ok {
+		return
 	}
-	if limit < 0 || offset < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{})
+	page, limit, ok := parsePage(c, 3)
+	if !ok {
 		return
 	}
-	posts, err := model.GetPosts(offset, limit)
+	posts, err := s.storeFor(c).GetPosts(tags, page)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, posts)
+	s.freshen(posts)
+	writePage(c, limit, posts, postKey)
 }
 
-func GetPost(c *gin.Context) {
+func (s *Server) GetPost(c *gin.Context) {
 	postID := c.Param("postID")
-	post, err := model.GetPost(postID)
+	post, err := s.storeFor(c).GetPost(postID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, post)
+	if !s.readable(c, post) {
+		return
+	}
+	posts := []model.Post{post}
+	s.freshen(posts)
+	c.JSON(http.StatusOK, posts[0])
 }
 
-func GetProjectPostById(c *gin.Context) {
+func (s *Server) GetProjectPostById(c *gin.Context) {
 	projectID := c.Param("projectID")
 	postNumber, err := strconv.Atoi(c.DefaultQuery("id", "0"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "id should be number"})
+		renderError(c, model.Validation("invalid_query", "id should be number", map[string]string{"id": "must be a number"}))
 		return
 	}
 
-	post, err := model.GetProjectPostById(projectID, postNumber)
+	post, err := s.storeFor(c).GetProjectPostById(projectID, postNumber)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, post)
+	if !s.readable(c, post) {
+		return
+	}
+	posts := []model.Post{post}
+	s.freshen(posts)
+	c.JSON(http.StatusOK, posts[0])
+}
+
+// GetDrafts lists the acting user's drafts and scheduled posts.
+func (s *Server) GetDrafts(c *gin.Context) {
+	userID := currentUserID(c)
+	if userID == "" {
+		renderError(c, model.Unauthorized("unauthorized", "sign in to do this"))
+		return
+	}
+	page, limit, ok := parsePage(c, 10)
+	if !ok {
+		return
+	}
+	posts, err := s.storeFor(c).GetDrafts(userID, page)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.freshen(posts)
+	writePage(c, limit, posts, postKey)
 }
 
 type postForm struct {
 	Title    string `json:"title" form:"title" binding:"required"`
 	Content  string `json:"content" form:"content" binding:"required"`
 	ThumbSrc string `json:"thumbSrc" form:"thumbSrc"`
+	// Status defaults to scheduled when PublishAt is given and to
+	// published otherwise.
+	Status    string `json:"status" form:"status" binding:"omitempty,oneof=draft scheduled published"`
+	PublishAt string `json:"publishAt" form:"publishAt"`
+}
+
+// schedule gives post its status. Publishing a post that was not yet
+// published dates it now; scheduling needs a publishAt in the future.
+func schedule(post *model.Post, status, publishAt string, now time.Time) error {
+	switch status {
+	case model.PostDraft:
+		post.PublishAt = ""
+	case model.PostScheduled:
+		at, err := time.ParseInLocation("2006-01-02 15:04:05", publishAt, time.Local)
+		if err != nil {
+			return model.Validation("invalid_publish_at", "publishAt should be a time such as 2006-01-02 15:04:05", map[string]string{"publishAt": "must be a time"})
+		}
+		if !at.After(now) {
+			return model.Validation("invalid_publish_at", "publishAt should be in the future", map[string]string{"publishAt": "must be in the future"})
+		}
+		post.PublishAt = publishAt
+	case model.PostPublished:
+		if !post.Published() {
+			post.PublishAt = now.Format("2006-01-02 15:04:05")
+			post.CreatedAt = post.PublishAt
+		}
+	}
+	post.Status = status
+	return nil
 }
 
-func PostPost(c *gin.Context) {
+func (s *Server) PostPost(c *gin.Context) {
 	projectID := c.Param("projectID")
-	ID := c.GetHeader("id")
-	if !utils.HasAuth(ID) {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	project, err := s.storeFor(c).GetProject(projectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	subject, ok := s.authorize(c, policy.CreatePost, policy.Resource{Project: &project})
+	if !ok {
 		return
 	}
+	ID := subject.UserID
 
 	var postForm postForm
-	err := c.BindJSON(&postForm)
+	err = c.ShouldBindJSON(&postForm)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{})
+		renderError(c, bindError(err))
 		return
 	}
 
 	date := time.Now()
 	post := model.Post{ID: xid.New().String(), Title: postForm.Title, Content: postForm.Content, ThumbSrc: postForm.ThumbSrc, UserID: ID, CreatedAt: date.Format("2006-01-02 15:04:05"), UpdatedAt: date.Format("2006-01-02 15:04:05"), ProjectID: projectID, Views: 0}
-	err = post.Insert()
+	status := postForm.Status
+	if status == "" && postForm.PublishAt != "" {
+		status = model.PostScheduled
+	} else if status == "" {
+		status = model.PostPublished
+	}
+	err = schedule(&post, status, postForm.PublishAt, date)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	err = s.render(&post)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	err = s.store.InsertPost(&post)
 	if err != nil {
-		fmt.Println(err)
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "post.create", "post", post.ID, nil, post)
 	c.JSON(http.StatusOK, post)
 }
 
-func DeletePost(c *gin.Context) {
+func (s *Server) DeletePost(c *gin.Context) {
 	postID := c.Param("postID")
-	ID := c.GetHeader("id")
-	post, err := model.GetPost(postID)
+	post, err := s.storeFor(c).GetPost(postID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
+		return
+	}
+	if !s.readable(c, post) {
 		return
 	}
-	project, err := model.GetProject(post.ProjectID)
+	project, err := s.storeFor(c).GetProject(post.ProjectID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
-	if ID != project.UserID && ID != post.UserID {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	if _, ok := s.authorize(c, policy.DeletePost, policy.Resource{Project: &project, Post: &post}); !ok {
 		return
 	}
-	err = post.Delete()
+	err = s.store.DeletePost(&post)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "post.delete", "post", post.ID, post, nil)
 	c.JSON(http.StatusOK, gin.H{})
 }
 
 type updatePostForm struct {
-	NewTitle    string `json:"newTitle" form:"newTitle"`
-	NewContent  string `json:"newContent" form:"newContent"`
-	NewThumbSrc string `json:"newThumbSrc" form:"newThumbSrd"`
+	NewTitle     string `json:"newTitle" form:"newTitle"`
+	NewContent   string `json:"newContent" form:"newContent"`
+	NewThumbSrc  string `json:"newThumbSrc" form:"newThumbSrd"`
+	NewStatus    string `json:"newStatus" form:"newStatus" binding:"omitempty,oneof=draft scheduled published"`
+	NewPublishAt string `json:"newPublishAt" form:"newPublishAt"`
 }
 
-func UpdatePost(c *gin.Context) {
+func (s *Server) UpdatePost(c *gin.Context) {
 	postID := c.Param("postID")
-	ID := c.GetHeader("id")
-	post, err := model.GetPost(postID)
+	post, err := s.storeFor(c).GetPost(postID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-	if ID != post.UserID {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	if !s.readable(c, post) {
+		return
+	}
+	project, err := s.storeFor(c).GetProject(post.ProjectID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	subject, ok := s.authorize(c, policy.UpdatePost, policy.Resource{Project: &project, Post: &post})
+	if !ok {
 		return
 	}
 	var body updatePostForm
-	err = c.BindJSON(&body)
+	err = c.ShouldBindJSON(&body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{})
+		renderError(c, bindError(err))
 		return
 	}
+	before := post
 	if body.NewContent != "" {
 		post.Content = body.NewContent
 	}
@@ -193,11 +275,27 @@ func UpdatePost(c *gin.Context) {
 	if body.NewThumbSrc != "" {
 		post.ThumbSrc = body.NewThumbSrc
 	}
-	err = post.Update()
+	if body.NewStatus == "" && body.NewPublishAt != "" {
+		body.NewStatus = model.PostScheduled
+	}
+	if body.NewStatus != "" {
+		err = schedule(&post, body.NewStatus, body.NewPublishAt, time.Now())
+		if err != nil {
+			renderError(c, err)
+			return
+		}
+	}
+	err = s.render(&post)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	err = s.store.UpdatePost(&post, subject.UserID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
 	post.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+	s.audit(c, "post.update", "post", post.ID, before, post)
 	c.JSON(http.StatusOK, post)
 }
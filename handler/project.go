@@ -5,24 +5,24 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/n-inja/go-blog/model"
-	"github.com/n-inja/go-blog/utils"
+	"github.com/n-inja/go-blog/policy"
 	"github.com/rs/xid"
 )
 
-func GetProjects(c *gin.Context) {
-	projects, err := model.GetProjects()
+func (s *Server) GetProjects(c *gin.Context) {
+	projects, err := s.storeFor(c).GetProjects()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, projects)
 }
 
-func GetProject(c *gin.Context) {
+func (s *Server) GetProject(c *gin.Context) {
 	projectID := c.Param("projectID")
-	project, err := model.GetProject(projectID)
+	project, err := s.storeFor(c).GetProject(projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, project)
@@ -33,46 +33,46 @@ type projectForm struct {
 	Description string `json:"description" form:"description"`
 }
 
-func PostProject(c *gin.Context) {
-	ID := c.GetHeader("id")
-	if !utils.HasAuth(ID) {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+func (s *Server) PostProject(c *gin.Context) {
+	subject, ok := s.authorize(c, policy.CreateProject, policy.Resource{})
+	if !ok {
 		return
 	}
+	ID := subject.UserID
 
 	var projectForm projectForm
-	err := c.BindJSON(&projectForm)
+	err := c.ShouldBindJSON(&projectForm)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{})
+		renderError(c, bindError(err))
 		return
 	}
 
 	project := model.Project{ID: xid.New().String(), Name: projectForm.Name, UserID: ID, Member: []string{ID}, Description: projectForm.Description}
-	err = project.Insert()
+	err = s.store.InsertProject(&project)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "project.create", "project", project.ID, nil, project)
 	c.JSON(http.StatusOK, project)
 }
 
-func DeleteProject(c *gin.Context) {
+func (s *Server) DeleteProject(c *gin.Context) {
 	projectID := c.Param("projectID")
-	ID := c.GetHeader("id")
-	project, err := model.GetProject(projectID)
+	project, err := s.storeFor(c).GetProject(projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-	if project.UserID != ID {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	if _, ok := s.authorize(c, policy.DeleteProject, policy.Resource{Project: &project}); !ok {
 		return
 	}
-	err = project.Delete()
+	err = s.store.DeleteProject(&project)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "project.delete", "project", project.ID, project, nil)
 	c.JSON(http.StatusOK, gin.H{})
 }
 
@@ -85,27 +85,25 @@ type updateProjectForm struct {
 	Removes        []string `json:"removes" form:"removes"`
 }
 
-func UpdateProject(c *gin.Context) {
+func (s *Server) UpdateProject(c *gin.Context) {
 	projectID := c.Param("projectID")
-	ID := c.GetHeader("id")
-	project, err := model.GetProject(projectID)
+	project, err := s.storeFor(c).GetProject(projectID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
-
-	if project.UserID != ID {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+	if _, ok := s.authorize(c, policy.UpdateProject, policy.Resource{Project: &project}); !ok {
 		return
 	}
 
 	var body updateProjectForm
-	err = c.BindJSON(&body)
+	err = c.ShouldBindJSON(&body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{})
+		renderError(c, bindError(err))
 		return
 	}
 
+	before := project
 	memberMap := make(map[string]bool, 0)
 	for _, userID := range project.Member {
 		memberMap[userID] = true
@@ -117,7 +115,7 @@ func UpdateProject(c *gin.Context) {
 		}
 	}
 	removes := make([]string, 0)
-	for _, userID := range body.Invites {
+	for _, userID := range body.Removes {
 		if memberMap[userID] && userID != project.UserID && userID != body.NewUserID {
 			removes = append(removes, userID)
 		}
@@ -131,9 +129,7 @@ func UpdateProject(c *gin.Context) {
 			}
 		}
 		if !belong {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"message": "project owner should belong project",
-			})
+			renderError(c, model.Validation("invalid_owner", "project owner should belong project", map[string]string{"newUserId": "must be a member of the project"}))
 			return
 		}
 		project.UserID = body.NewUserID
@@ -147,10 +143,11 @@ func UpdateProject(c *gin.Context) {
 	if body.NewDisplayName != "" {
 		project.DisplayName = body.NewDisplayName
 	}
-	err = project.Update(invites, removes)
+	err = s.store.UpdateProject(&project, invites, removes)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "project.update", "project", project.ID, before, project)
 	c.JSON(http.StatusOK, project)
 }
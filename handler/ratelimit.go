@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/ratelimit"
+)
+
+type bucket struct {
+	key   string
+	limit config.Limit
+}
+
+// limit throttles the routes of group per signed in user and per client
+// IP. A request spends a token from every bucket or, when one refuses it,
+// from none. The response carries the RateLimit headers of whichever
+// bucket is closest to empty. When the buckets cannot be read the request goes
+// through; a broken limiter should not take the site down with it.
+func (s *Server) limit(group string, limits config.Limits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.limiter == nil {
+			return
+		}
+		var buckets []bucket
+		if userID := currentUserID(c); userID != "" && limits.PerUser.Burst > 0 {
+			buckets = append(buckets, bucket{group + ":user:" + userID, limits.PerUser})
+		}
+		if limits.PerIP.Burst > 0 {
+			buckets = append(buckets, bucket{group + ":ip:" + c.ClientIP(), limits.PerIP})
+		}
+
+		var tightest *ratelimit.Result
+		var policy config.Limit
+		var taken []bucket
+		for _, bucket := range buckets {
+			result, err := s.limiter.Take(bucket.key, bucket.limit)
+			if err != nil {
+				log.Println("ratelimit:", bucket.key, err)
+				continue
+			}
+			if result.Allowed {
+				taken = append(taken, bucket)
+			}
+			if tightest == nil || tighter(result, *tightest) {
+				tightest, policy = &result, bucket.limit
+			}
+		}
+		if tightest == nil {
+			return
+		}
+		if !tightest.Allowed {
+			for _, bucket := range taken {
+				err := s.limiter.Give(bucket.key, bucket.limit)
+				if err != nil {
+					log.Println("ratelimit:", bucket.key, err)
+				}
+			}
+		}
+		c.Header("RateLimit-Limit", strconv.Itoa(tightest.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(tightest.Remaining))
+		c.Header("RateLimit-Reset", seconds(tightest.Reset))
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%s", policy.Burst, seconds(time.Duration(policy.Burst)*policy.Every)))
+		if !tightest.Allowed {
+			c.Header("Retry-After", seconds(tightest.RetryAfter))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, errorResponse{Code: "rate_limited", Message: "too many requests; retry in " + seconds(tightest.RetryAfter) + " seconds"})
+		}
+	}
+}
+
+// tighter reports whether a is closer to refusing requests than b.
+func tighter(a, b ratelimit.Result) bool {
+	if a.Allowed != b.Allowed {
+		return !a.Allowed
+	}
+	if !a.Allowed {
+		return a.RetryAfter > b.RetryAfter
+	}
+	return a.Remaining < b.Remaining
+}
+
+// seconds rounds d up to whole seconds, as the rate limit headers want.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/config"
+)
+
+func TestRateLimit(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.RateLimit.Comments = config.Limits{
+			PerUser: config.Limit{Burst: 2, Every: time.Hour},
+			PerIP:   config.Limit{Burst: 3, Every: 30 * time.Minute},
+		}
+	})
+	project := ts.createProject("alice", "diary", "bob")
+	post := ts.createPost("alice", project.ID, "first")
+	comments := api + "posts/" + post.ID + "/comments"
+
+	rec := ts.do("POST", comments, "alice", gin.H{"content": "one"})
+	ts.expect(rec, http.StatusOK, nil)
+	if rec.Header().Get("RateLimit-Limit") != "2" || rec.Header().Get("RateLimit-Remaining") != "1" || rec.Header().Get("RateLimit-Reset") != "3600" || rec.Header().Get("RateLimit-Policy") != "2;w=7200" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+	ts.createComment("alice", post.ID, "two")
+	rec = ts.do("POST", comments, "alice", gin.H{"content": "three"})
+	ts.expectError(rec, http.StatusTooManyRequests, "rate_limited")
+	if rec.Header().Get("Retry-After") != "3600" || rec.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+
+	// alice's refused comment left the address its last token
+	ts.createComment("bob", post.ID, "hello")
+	ts.expectError(ts.do("POST", comments, "bob", gin.H{"content": "again"}), http.StatusTooManyRequests, "rate_limited")
+	req := httptest.NewRequest("POST", comments, strings.NewReader(`{"content":"hello"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("id", "bob")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	rec = httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	ts.expect(rec, http.StatusOK, nil)
+
+	// other routes are limited separately
+	ts.createPost("alice", project.ID, "second")
+	var list []interface{}
+	ts.expect(ts.do("GET", comments, "alice", nil), http.StatusOK, &list)
+	if len(list) != 4 {
+		t.Fatalf("comments = %v", list)
+	}
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.RateLimit.Enabled = false
+		c.RateLimit.Projects.PerUser = config.Limit{Burst: 1, Every: time.Hour}
+	})
+	ts.createProject("alice", "diary")
+	rec := ts.do("POST", api+"projects", "alice", gin.H{"name": "notes"})
+	ts.expect(rec, http.StatusOK, nil)
+	if rec.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+}
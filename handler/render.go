@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"log"
+
+	"github.com/n-inja/go-blog/model"
+)
+
+// render fills in the HTML of post's content, which is saved along with it.
+func (s *Server) render(post *model.Post) error {
+	contentHTML, err := s.renderer.Render(post.Content)
+	if err != nil {
+		return err
+	}
+	post.ContentHTML = contentHTML
+	post.RenderKey = s.renderer.Key()
+	return nil
+}
+
+// freshen renders the posts whose HTML was cached under another pipeline,
+// or never, and saves the result. Failing to save only means rendering the
+// post again on the next read, so it is logged rather than returned.
+func (s *Server) freshen(posts []model.Post) {
+	for i := range posts {
+		if posts[i].RenderKey == s.renderer.Key() {
+			continue
+		}
+		err := s.render(&posts[i])
+		if err == nil {
+			err = s.store.SetContentHTML(posts[i].ID, posts[i].ContentHTML, posts[i].RenderKey)
+		}
+		if err != nil {
+			log.Println("render:", posts[i].ID, err)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestContentHTML(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+
+	var post model.Post
+	ts.expect(ts.do("POST", api+"projects/"+project.ID+"/posts", "alice", gin.H{"title": "md", "content": "**hi** <script>alert(1)</script>"}), http.StatusOK, &post)
+	if !strings.Contains(post.ContentHTML, "<strong>hi</strong>") || strings.Contains(post.ContentHTML, "script") {
+		t.Fatalf("contentHtml = %q", post.ContentHTML)
+	}
+	ts.expect(ts.do("PUT", api+"posts/"+post.ID, "alice", gin.H{"newContent": "_new_"}), http.StatusOK, &post)
+	if post.ContentHTML != "<p><em>new</em></p>\n" {
+		t.Fatalf("updated contentHtml = %q", post.ContentHTML)
+	}
+	ts.expect(ts.do("POST", api+"posts/"+post.ID+"/revisions/1/restore", "alice", nil), http.StatusOK, &post)
+	if !strings.Contains(post.ContentHTML, "<strong>hi</strong>") {
+		t.Fatalf("restored contentHtml = %q", post.ContentHTML)
+	}
+
+	// HTML cached by another pipeline is rendered again when read
+	err := ts.store.SetContentHTML(post.ID, "<p>stale</p>", "old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var posts []model.Post
+	ts.expect(ts.do("GET", api+"posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 1 || !strings.Contains(posts[0].ContentHTML, "<strong>hi</strong>") {
+		t.Fatalf("posts = %+v", posts)
+	}
+	cached, _ := ts.store.GetPost(post.ID)
+	if cached.ContentHTML != posts[0].ContentHTML || cached.UpdatedAt != post.UpdatedAt {
+		t.Fatalf("cached = %+v, want the fresh HTML saved without an update", cached)
+	}
+}
+
+func TestTextContent(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.Render.Format = "text"
+	})
+	project := ts.createProject("alice", "diary")
+	var post model.Post
+	ts.expect(ts.do("POST", api+"projects/"+project.ID+"/posts", "alice", gin.H{"title": "plain", "content": "**as is**"}), http.StatusOK, &post)
+	if post.ContentHTML != "<p>**as is**</p>\n" {
+		t.Fatalf("contentHtml = %q", post.ContentHTML)
+	}
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+const (
+	primaryCookie = "go_blog_primary_until"
+	// primaryHeader carries the same deadline for clients without cookies,
+	// such as scripts using tokens, which send it back on their reads.
+	primaryHeader = "X-Primary-Until"
+)
+
+func isRead(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// readYourWrites pins a client to the primary database for a short while
+// after it sends a mutating request, so it never reads its own change back
+// from a replica that has not caught up yet. The deadline goes out both as
+// a cookie and as a header.
+func (s *Server) readYourWrites(c *gin.Context) {
+	window := s.config.Database.ReadYourWrites
+	if !isRead(c.Request.Method) && window > 0 {
+		until := strconv.FormatInt(time.Now().Add(window).Unix(), 10)
+		c.SetCookie(primaryCookie, until, int(window/time.Second)+1, "/", "", false, true)
+		c.Header(primaryHeader, until)
+	}
+	c.Next()
+}
+
+// storeFor returns the store reads in this request should go to.
+func (s *Server) storeFor(c *gin.Context) model.Store {
+	if !isRead(c.Request.Method) {
+		return s.store.Primary()
+	}
+	value := c.GetHeader(primaryHeader)
+	if value == "" {
+		value, _ = c.Cookie(primaryCookie)
+	}
+	until, err := strconv.ParseInt(value, 10, 64)
+	if err == nil && time.Now().Unix() <= until {
+		return s.store.Primary()
+	}
+	return s.store
+}
@@ -0,0 +1,57 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+)
+
+// laggingStore is a replica that has not caught up with any comment yet.
+type laggingStore struct {
+	*model.MemoryStore
+}
+
+func (s laggingStore) Primary() model.Store {
+	return s.MemoryStore
+}
+
+func (s laggingStore) GetComment(commentID string) (model.Comment, error) {
+	return model.Comment{}, model.NotFound("comment_not_found", "comment not found")
+}
+
+func TestReadYourWrites(t *testing.T) {
+	ts := newTestServer(t, func(c *config.Config) {
+		c.Database.ReadYourWrites = time.Minute
+	}, func(store *model.MemoryStore) model.Store {
+		return laggingStore{store}
+	})
+	project := ts.createProject("alice", "replica")
+	post := ts.createPost("alice", project.ID, "lagging")
+
+	rec := ts.do("POST", api+"posts/"+post.ID+"/comments", "bob", gin.H{"content": "first"})
+	var comment model.Comment
+	ts.expect(rec, http.StatusOK, &comment)
+	until := rec.Header().Get("X-Primary-Until")
+	if until == "" {
+		t.Fatalf("no X-Primary-Until in %v", rec.Header())
+	}
+
+	get := func(header, value string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", api+"comments/"+comment.ID, nil)
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		rec := httptest.NewRecorder()
+		ts.server.ServeHTTP(rec, req)
+		return rec
+	}
+	ts.expectError(get("", ""), http.StatusNotFound, "comment_not_found")
+	ts.expect(get("X-Primary-Until", until), http.StatusOK, nil)
+	ts.expect(get("Cookie", "go_blog_primary_until="+until), http.StatusOK, nil)
+	ts.expectError(get("X-Primary-Until", "1"), http.StatusNotFound, "comment_not_found")
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/policy"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// editablePost loads the post of the route for a user who may change it.
+// Its history can hold text that was removed on purpose, so nobody else
+// gets to read it.
+func (s *Server) editablePost(c *gin.Context) (model.Post, policy.Subject, bool) {
+	post, err := s.storeFor(c).GetPost(c.Param("postID"))
+	if err != nil {
+		renderError(c, err)
+		return post, policy.Subject{}, false
+	}
+	if !s.readable(c, post) {
+		return post, policy.Subject{}, false
+	}
+	project, err := s.storeFor(c).GetProject(post.ProjectID)
+	if err != nil {
+		renderError(c, err)
+		return post, policy.Subject{}, false
+	}
+	subject, ok := s.authorize(c, policy.UpdatePost, policy.Resource{Project: &project, Post: &post})
+	return post, subject, ok
+}
+
+// revision loads the revision of post numbered by the query or route
+// parameter name.
+func (s *Server) revision(c *gin.Context, post model.Post, number string) (model.Revision, bool) {
+	n, err := strconv.Atoi(number)
+	if err != nil {
+		renderError(c, model.NotFound("revision_not_found", "revision not found"))
+		return model.Revision{}, false
+	}
+	revision, err := s.storeFor(c).GetRevision(post.ID, n)
+	if err != nil {
+		renderError(c, err)
+		return model.Revision{}, false
+	}
+	return revision, true
+}
+
+func (s *Server) GetRevisions(c *gin.Context) {
+	post, _, ok := s.editablePost(c)
+	if !ok {
+		return
+	}
+	page, limit, ok := parsePage(c, 10)
+	if !ok {
+		return
+	}
+	revisions, err := s.storeFor(c).GetRevisions(post.ID, page)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	writePage(c, limit, revisions, func(revision model.Revision) (string, string) {
+		return revision.CreatedAt, strconv.Itoa(revision.Number)
+	})
+}
+
+func (s *Server) GetRevision(c *gin.Context) {
+	post, _, ok := s.editablePost(c)
+	if !ok {
+		return
+	}
+	revision, ok := s.revision(c, post, c.Param("number"))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, revision)
+}
+
+// GetRevisionDiff returns a unified diff from the revision numbered by the
+// from query, by default the one before, to the revision of the route.
+func (s *Server) GetRevisionDiff(c *gin.Context) {
+	post, _, ok := s.editablePost(c)
+	if !ok {
+		return
+	}
+	to, ok := s.revision(c, post, c.Param("number"))
+	if !ok {
+		return
+	}
+	from := model.Revision{PostID: post.ID}
+	if number := c.Query("from"); number != "" {
+		if from, ok = s.revision(c, post, number); !ok {
+			return
+		}
+	} else if to.Number > 1 {
+		if from, ok = s.revision(c, post, strconv.Itoa(to.Number-1)); !ok {
+			return
+		}
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(revisionText(from)),
+		B:        difflib.SplitLines(revisionText(to)),
+		FromFile: "revision " + strconv.Itoa(from.Number),
+		ToFile:   "revision " + strconv.Itoa(to.Number),
+		Context:  3,
+	})
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"from": from.Number, "to": to.Number, "diff": diff})
+}
+
+// revisionText lays a revision out as lines to diff. The empty revision 0
+// stands in for the state before the post was written.
+func revisionText(revision model.Revision) string {
+	if revision.Number == 0 {
+		return ""
+	}
+	return "title: " + revision.Title + "\nthumbSrc: " + revision.ThumbSrc + "\n\n" + revision.Content + "\n"
+}
+
+// RestoreRevision saves an older revision again, as the newest one.
+func (s *Server) RestoreRevision(c *gin.Context) {
+	post, subject, ok := s.editablePost(c)
+	if !ok {
+		return
+	}
+	revision, ok := s.revision(c, post, c.Param("number"))
+	if !ok {
+		return
+	}
+	before := post
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.ThumbSrc = revision.ThumbSrc
+	err := s.render(&post)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	err = s.store.UpdatePost(&post, subject.UserID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.audit(c, "post.revision.restore", "post", post.ID, before, post)
+	c.JSON(http.StatusOK, post)
+}
@@ -0,0 +1,78 @@
+package handler_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestRevisions(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary", "bob")
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID+"/members/bob", "alice", gin.H{"role": "editor"}), http.StatusOK, nil)
+	post := ts.createPost("alice", project.ID, "first")
+	revisions := api + "posts/" + post.ID + "/revisions"
+	ts.expect(ts.do("PUT", api+"posts/"+post.ID, "alice", gin.H{"newContent": "line one\nline two"}), http.StatusOK, nil)
+	ts.expect(ts.do("PUT", api+"posts/"+post.ID, "bob", gin.H{"newContent": "line one\nline 2"}), http.StatusOK, nil)
+
+	var list []model.Revision
+	ts.expect(ts.do("GET", revisions, "alice", nil), http.StatusOK, &list)
+	if len(list) != 3 || list[0].Number != 3 || list[0].EditorID != "bob" || list[2].Number != 1 || list[2].Content != "content of first" {
+		t.Fatalf("revisions = %+v", list)
+	}
+	var page struct {
+		Items      []model.Revision `json:"items"`
+		NextCursor *string          `json:"nextCursor"`
+	}
+	ts.expect(ts.do("GET", revisions+"?cursor=&limit=2", "alice", nil), http.StatusOK, &page)
+	if len(page.Items) != 2 || page.NextCursor == nil {
+		t.Fatalf("first page = %+v", page)
+	}
+	ts.expect(ts.do("GET", revisions+"?limit=2&cursor="+*page.NextCursor, "alice", nil), http.StatusOK, &page)
+	if len(page.Items) != 1 || page.Items[0].Number != 1 || page.NextCursor != nil {
+		t.Fatalf("second page = %+v", page)
+	}
+
+	var diff struct {
+		From int    `json:"from"`
+		To   int    `json:"to"`
+		Diff string `json:"diff"`
+	}
+	ts.expect(ts.do("GET", revisions+"/3/diff", "alice", nil), http.StatusOK, &diff)
+	if diff.From != 2 || !strings.Contains(diff.Diff, "--- revision 2\n+++ revision 3\n") || !strings.Contains(diff.Diff, "\n-line two\n+line 2\n") {
+		t.Fatalf("diff = %+v", diff)
+	}
+	ts.expect(ts.do("GET", revisions+"/1/diff", "alice", nil), http.StatusOK, &diff)
+	if diff.From != 0 || !strings.Contains(diff.Diff, "+title: first\n") {
+		t.Fatalf("diff of the first revision = %+v", diff)
+	}
+	ts.expect(ts.do("GET", revisions+"/3/diff?from=1", "alice", nil), http.StatusOK, &diff)
+	if !strings.Contains(diff.Diff, "-content of first\n+line one\n+line 2\n") {
+		t.Fatalf("diff from 1 = %+v", diff)
+	}
+	ts.expectError(ts.do("GET", revisions+"/3/diff?from=9", "alice", nil), http.StatusNotFound, "revision_not_found")
+	ts.expectError(ts.do("GET", revisions+"/x", "alice", nil), http.StatusNotFound, "revision_not_found")
+
+	// only those who may edit the post read its history
+	ts.expectError(ts.do("GET", revisions, "", nil), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("GET", revisions+"/1", "carol", nil), http.StatusForbidden, "forbidden")
+
+	var restored model.Post
+	ts.expect(ts.do("POST", revisions+"/1/restore", "bob", nil), http.StatusOK, &restored)
+	if restored.Content != "content of first" {
+		t.Fatalf("restored = %+v", restored)
+	}
+	var latest model.Revision
+	ts.expect(ts.do("GET", revisions+"/4", "alice", nil), http.StatusOK, &latest)
+	if latest.Content != "content of first" || latest.EditorID != "bob" {
+		t.Fatalf("revision 4 = %+v", latest)
+	}
+	// told apart from an admin undeleting the post
+	events, err := ts.store.GetAuditEvents(model.AuditFilter{ResourceType: "post", ResourceID: post.ID}, model.Page{Limit: 1})
+	if err != nil || len(events) != 1 || events[0].Action != "post.revision.restore" || events[0].ActorID != "bob" {
+		t.Fatalf("audit events = %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/search"
+)
+
+// Search finds published posts and their comments by the words of q, most
+// relevant first. Hits are ranked rather than ordered by time, so they are
+// paged by offset only.
+func (s *Server) Search(c *gin.Context) {
+	query := model.SearchQuery{
+		Text:      strings.TrimSpace(c.Query("q")),
+		Type:      c.Query("type"),
+		ProjectID: c.Query("projectId"),
+		UserID:    c.Query("userId"),
+	}
+	if query.Text == "" {
+		renderError(c, model.Validation("invalid_query", "q is required", map[string]string{"q": "is required"}))
+		return
+	}
+	if query.Type != "" && query.Type != model.HitPost && query.Type != model.HitComment {
+		renderError(c, model.Validation("invalid_query", "type should be post or comment", map[string]string{"type": "must be post or comment"}))
+		return
+	}
+	var ok bool
+	if query.Since, ok = queryTime(c, "since", false); !ok {
+		return
+	}
+	if query.Until, ok = queryTime(c, "until", true); !ok {
+		return
+	}
+	if byCursor(c) {
+		renderError(c, model.Validation("invalid_query", "search results are paged by offset", map[string]string{"cursor": "is not supported"}))
+		return
+	}
+	page, _, ok := parsePage(c, 10)
+	if !ok {
+		return
+	}
+	query.Offset, query.Limit = page.Offset, page.Limit
+	hits, err := s.storeFor(c).Search(query)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	for i := range hits {
+		hits[i].Snippet = search.Highlight(hits[i].Content, query.Text, s.config.Search.SnippetLength)
+	}
+	c.JSON(http.StatusOK, hits)
+}
@@ -0,0 +1,64 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestSearch(t *testing.T) {
+	ts := newTestServer(t)
+	diary := ts.createProject("alice", "diary")
+	notes := ts.createProject("bob", "notes")
+	posts := func(project model.Project) string { return api + "projects/" + project.ID + "/posts" }
+
+	var ramen, udon model.Post
+	ts.expect(ts.do("POST", posts(diary), "alice", gin.H{"title": "東京のラーメン", "content": "昨日は新宿で<b>ラーメン</b>を食べた。"}), http.StatusOK, &ramen)
+	ts.expect(ts.do("POST", posts(notes), "bob", gin.H{"title": "Noodles", "content": "Udon and ramen in Tokyo"}), http.StatusOK, &udon)
+	ts.expect(ts.do("POST", posts(diary), "alice", gin.H{"title": "下書き", "content": "ラーメンの下書き", "status": "draft"}), http.StatusOK, nil)
+	comment := ts.createComment("bob", ramen.ID, "そのラーメン屋は東京駅にもある")
+
+	search := func(query string) []model.SearchHit {
+		t.Helper()
+		var hits []model.SearchHit
+		ts.expect(ts.do("GET", api+"search?"+query, "", nil), http.StatusOK, &hits)
+		return hits
+	}
+	hits := search("q=ラーメン")
+	if len(hits) != 2 || hits[0].ID != ramen.ID || hits[1].ID != comment.ID || hits[1].Type != model.HitComment || hits[1].Title != ramen.Title {
+		t.Fatalf("ラーメン finds %+v, want the post with it in the title first and no draft", hits)
+	}
+	if hits[0].Snippet != "昨日は新宿で&lt;b&gt;<mark>ラーメン</mark>&lt;/b&gt;を食べた。" {
+		t.Fatalf("snippet = %q", hits[0].Snippet)
+	}
+	if hits := search("q=ラーメン+東京駅"); len(hits) != 1 || hits[0].ID != comment.ID {
+		t.Fatalf("ラーメン 東京駅 finds %+v", hits)
+	}
+	if hits := search("q=RAMEN"); len(hits) != 1 || hits[0].ID != udon.ID || hits[0].Snippet != "Udon and <mark>ramen</mark> in Tokyo" {
+		t.Fatalf("RAMEN finds %+v", hits)
+	}
+
+	for query, want := range map[string]int{
+		"q=ラーメン&type=post":                                1,
+		"q=ラーメン&userId=bob":                               1,
+		"q=ラーメン&projectId=" + notes.ID:                    0,
+		"q=ラーメン&limit=1&offset=1":                         1,
+		"q=ラーメン&since=2001-01-01&until=2001-01-02":        0,
+		"q=ラーメン&until=" + time.Now().Format("2006-01-02"): 2,
+	} {
+		if hits := search(query); len(hits) != want {
+			t.Errorf("%s finds %d hits, want %d", query, len(hits), want)
+		}
+	}
+	ts.expectError(ts.do("GET", api+"search", "", nil), http.StatusBadRequest, "invalid_query")
+	ts.expectError(ts.do("GET", api+"search?q=x&type=user", "", nil), http.StatusBadRequest, "invalid_query")
+	ts.expectError(ts.do("GET", api+"search?q=x&cursor=", "", nil), http.StatusBadRequest, "invalid_query")
+
+	ts.expect(ts.do("DELETE", api+"comments/"+comment.ID, "bob", nil), http.StatusOK, nil)
+	if hits := search("q=東京駅"); len(hits) != 0 {
+		t.Fatalf("deleted comment still found: %+v", hits)
+	}
+}
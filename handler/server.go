@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"errors"
+	"html/template"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/ratelimit"
+	"github.com/n-inja/go-blog/render"
+)
+
+// Server holds everything the handlers need. Build it with NewServer.
+type Server struct {
+	config    config.Config
+	store     model.Store
+	auth      *auth.Authenticator
+	providers map[string]auth.Provider
+	// limiter is nil when rate limiting is off
+	limiter   *ratelimit.Limiter
+	renderer  *render.Renderer
+	templates *template.Template
+	files     map[string][]byte
+	engine    *gin.Engine
+}
+
+// NewServer wires the route table. templates must define index.tmpl and
+// static must contain the static/js and static/css directories.
+func NewServer(c config.Config, store model.Store, templates *template.Template, static fs.FS) (*Server, error) {
+	files, err := loadStatic(static)
+	if err != nil {
+		return nil, err
+	}
+	authenticator, err := auth.New(c.Auth, store)
+	if err != nil {
+		return nil, err
+	}
+	renderer, err := render.New(c.Render)
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{
+		config:    c,
+		store:     store,
+		auth:      authenticator,
+		providers: auth.Providers(c.Auth),
+		renderer:  renderer,
+		templates: templates,
+		files:     files,
+		engine:    gin.Default(),
+	}
+	if c.RateLimit.Enabled {
+		var buckets model.RateLimitStore = ratelimit.NewMemory()
+		if c.RateLimit.Backend == "store" {
+			shared, ok := store.(model.RateLimitStore)
+			if !ok {
+				return nil, errors.New("the store cannot share rate limits")
+			}
+			buckets = shared
+		}
+		s.limiter = ratelimit.New(buckets)
+	}
+	// ClientIP trusts X-Forwarded-For only from the proxies trusted to name users
+	err = s.engine.SetTrustedProxies(c.Auth.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	s.engine.Use(s.requestID)
+	if cors := s.cors(); cors != nil {
+		s.engine.Use(cors)
+	}
+	s.engine.SetHTMLTemplate(templates)
+	s.routes()
+	return s, nil
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.engine.ServeHTTP(w, req)
+}
+
+func (s *Server) routes() {
+	s.engine.GET("healthz", s.Healthz)
+	s.engine.GET("readyz", s.Readyz)
+
+	pages := s.engine.Group(s.config.Routes.Pages)
+	pages.GET("/", s.SetTop)
+
+	pages.GET("users", s.SetUsers)
+	pages.GET("projects", s.SetProjects)
+	pages.GET("mypage", s.SetMyPage)
+
+	pages.GET("users/:userID", s.SetUser)
+	pages.GET("projects/:projectName", s.SetProject)
+
+	pages.GET("tags/:tag", s.SetTag)
+
+	pages.GET("projects/:projectName/:postID/:number", s.SetPost)
+	pages.GET("projects/:projectName/:postID", s.SetPost)
+
+	pages.GET("static/:directory/:filename", s.ServeStatic)
+
+	api := s.engine.Group(s.config.Routes.API, s.authenticate, s.checkCSRF, s.readYourWrites)
+	api.GET("users", s.GetAllUsers)
+	api.GET("users/:userID", s.GetUser)
+	api.PUT("profile", s.UpdateProfile)
+	api.GET("tokens", s.GetTokens)
+	api.POST("tokens", s.PostToken)
+	api.DELETE("tokens/:tokenID", s.DeleteToken)
+	if s.config.Auth.LocalAccounts {
+		api.POST("signup", s.Signup)
+		api.POST("login", s.Login)
+	}
+	if len(s.providers) > 0 {
+		api.GET("oauth/:provider/login", s.OAuthLogin)
+		api.GET("oauth/:provider/callback", s.OAuthCallback)
+	}
+	if s.config.Auth.LocalAccounts || len(s.providers) > 0 {
+		api.POST("logout", s.Logout)
+	}
+	if s.config.Auth.SessionSecret != "" {
+		api.GET("csrf", s.GetCSRFToken)
+	}
+
+	api.GET("projects", s.GetProjects)
+	api.GET("projects/:projectID", s.GetProject)
+	api.POST("projects", s.limit("projects", s.config.RateLimit.Projects), s.PostProject)
+	api.DELETE("projects/:projectID", s.DeleteProject)
+	api.PUT("projects/:projectID", s.UpdateProject)
+	api.GET("projects/:projectID/members", s.GetMembers)
+	api.PUT("projects/:projectID/members/:userID", s.PutMember)
+	api.DELETE("projects/:projectID/members/:userID", s.DeleteMember)
+
+	api.GET("users/:userID/posts", s.GetUserPosts)
+	api.GET("projects/:projectID/posts", s.GetProjectPosts)
+	api.GET("posts", s.GetPosts)
+	api.GET("posts/:postID", s.GetPost)
+	api.GET("drafts", s.GetDrafts)
+	api.GET("projects/:projectID/post", s.GetProjectPostById)
+	api.POST("projects/:projectID/posts", s.limit("posts", s.config.RateLimit.Posts), s.PostPost)
+	api.DELETE("posts/:postID", s.DeletePost)
+	api.PUT("posts/:postID", s.UpdatePost)
+	api.GET("posts/:postID/revisions", s.GetRevisions)
+	api.GET("posts/:postID/revisions/:number", s.GetRevision)
+	api.GET("posts/:postID/revisions/:number/diff", s.GetRevisionDiff)
+	api.POST("posts/:postID/revisions/:number/restore", s.RestoreRevision)
+	api.PUT("posts/:postID/tags", s.PutPostTags)
+	api.GET("tags", s.GetTags)
+	if s.config.Search.Enabled {
+		api.GET("search", s.Search)
+	}
+
+	api.GET("posts/:postID/comments", s.GetPostComments)
+	api.GET("comments/:commentID", s.GetComment)
+	api.POST("posts/:postID/comments", s.limit("comments", s.config.RateLimit.Comments), s.PostComment)
+	api.DELETE("comments/:commentID", s.DeleteComment)
+
+	admin := api.Group("admin", s.moderator)
+	admin.GET("posts", s.GetModerationPosts)
+	admin.GET("comments", s.GetModerationComments)
+	admin.GET("actions", s.GetModerationActions)
+	admin.GET("audit", s.GetAuditEvents)
+	admin.POST("posts/:postID/hide", s.moderate("post.hide", "post", "postID", s.hidePost))
+	admin.POST("posts/:postID/unhide", s.moderate("post.unhide", "post", "postID", s.unhidePost))
+	admin.POST("posts/:postID/restore", s.moderate("post.restore", "post", "postID", s.restorePost))
+	admin.POST("comments/:commentID/hide", s.moderate("comment.hide", "comment", "commentID", s.hideComment))
+	admin.POST("comments/:commentID/unhide", s.moderate("comment.unhide", "comment", "commentID", s.unhideComment))
+	admin.POST("comments/:commentID/restore", s.moderate("comment.restore", "comment", "commentID", s.restoreComment))
+	admin.POST("users/:userID/suspend", s.moderate("user.suspend", "user", "userID", s.suspendUser))
+	admin.POST("users/:userID/reinstate", s.ReinstateUser)
+	admin.POST("projects/:projectID/transfer", s.TransferProject)
+	admin.GET("tags/aliases", s.GetTagAliases)
+	admin.PUT("tags/aliases/:alias", s.PutTagAlias)
+	admin.DELETE("tags/aliases/:alias", s.DeleteTagAlias)
+}
@@ -0,0 +1,684 @@
+package handler_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/handler"
+	"github.com/n-inja/go-blog/model"
+)
+
+const api = "/go-blog/api/v1/"
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+type testServer struct {
+	t      *testing.T
+	server *handler.Server
+	store  *model.MemoryStore
+}
+
+// newTestServer trusts the id header from httptest's client address, so
+// tests can act as any user of a store seeded with alice, bob and carol.
+// Options are a func(*config.Config) adjusting the configuration, a
+// *model.MemoryStore served instead of the seeded one, or a
+// func(*model.MemoryStore) model.Store wrapping the store that is served.
+func newTestServer(t *testing.T, options ...any) *testServer {
+	c := config.Default()
+	c.Store = "memory"
+	c.Site.Hostname = "blog.example"
+	c.Site.StaticPath = "."
+	c.Site.FaviconURL = "https://blog.example/static/favicon.png"
+	c.Auth.TrustedProxies = []string{"192.0.2.1"}
+
+	store := model.NewMemoryStore()
+	store.AddUser(model.User{ID: "alice", Name: "alice", Auth: "default"})
+	store.AddUser(model.User{ID: "bob", Name: "bob", Auth: "default"})
+	store.AddUser(model.User{ID: "carol", Name: "carol", Auth: "guest"})
+	var wrap func(*model.MemoryStore) model.Store
+	for _, option := range options {
+		switch option := option.(type) {
+		case func(*config.Config):
+			option(&c)
+		case *model.MemoryStore:
+			store = option
+		case func(*model.MemoryStore) model.Store:
+			wrap = option
+		default:
+			t.Fatalf("unknown test server option %T", option)
+		}
+	}
+
+	var served model.Store = store
+	if wrap != nil {
+		served = wrap(store)
+	}
+	server, err := handler.NewServer(c, served, testTemplates(), testStatic())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testServer{t: t, server: server, store: store}
+}
+
+func testTemplates() *template.Template {
+	return template.Must(template.New("index.tmpl").Parse(`<title>{{.title}}</title><meta property="og:url" content="{{.url}}"><meta property="og:description" content="{{.description}}">`))
+}
+
+func testStatic() fstest.MapFS {
+	return fstest.MapFS{
+		"static/js/app.js":    {Data: []byte("console.log('app')")},
+		"static/js/app.js.gz": {Data: []byte("gzipped")},
+		"static/css/app.css":  {Data: []byte("body {}")},
+	}
+}
+
+// do sends a request as userID; an empty userID sends no identity.
+func (ts *testServer) do(method, path, userID string, body interface{}) *httptest.ResponseRecorder {
+	ts.t.Helper()
+	var reader *bytes.Reader
+	if body == nil {
+		reader = bytes.NewReader(nil)
+	} else if raw, ok := body.(string); ok {
+		reader = bytes.NewReader([]byte(raw))
+	} else {
+		data, err := json.Marshal(body)
+		if err != nil {
+			ts.t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if userID != "" {
+		req.Header.Set("id", userID)
+	}
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect checks the status and decodes the body into v when v is not nil.
+func (ts *testServer) expect(rec *httptest.ResponseRecorder, status int, v interface{}) {
+	ts.t.Helper()
+	if rec.Code != status {
+		ts.t.Fatalf("status = %d, want %d; body %s", rec.Code, status, rec.Body.String())
+	}
+	if v != nil {
+		err := json.Unmarshal(rec.Body.Bytes(), v)
+		if err != nil {
+			ts.t.Fatalf("decode %s: %v", rec.Body.String(), err)
+		}
+	}
+}
+
+func (ts *testServer) expectError(rec *httptest.ResponseRecorder, status int, code string) {
+	ts.t.Helper()
+	var body struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+	ts.expect(rec, status, &body)
+	if body.Code != code {
+		ts.t.Fatalf("code = %q, want %q", body.Code, code)
+	}
+}
+
+func (ts *testServer) createProject(userID, name string, member ...string) model.Project {
+	ts.t.Helper()
+	var project model.Project
+	ts.expect(ts.do("POST", api+"projects", userID, gin.H{"name": name, "description": "about " + name}), http.StatusOK, &project)
+	if len(member) > 0 {
+		ts.expect(ts.do("PUT", api+"projects/"+project.ID, userID, gin.H{"invites": member}), http.StatusOK, &project)
+	}
+	return project
+}
+
+func (ts *testServer) createPost(userID, projectID, title string) model.Post {
+	ts.t.Helper()
+	var post model.Post
+	ts.expect(ts.do("POST", api+"projects/"+projectID+"/posts", userID, gin.H{"title": title, "content": "content of " + title}), http.StatusOK, &post)
+	return post
+}
+
+func (ts *testServer) createComment(userID, postID, content string) model.Comment {
+	ts.t.Helper()
+	var comment model.Comment
+	ts.expect(ts.do("POST", api+"posts/"+postID+"/comments", userID, gin.H{"content": content}), http.StatusOK, &comment)
+	return comment
+}
+
+func TestHealth(t *testing.T) {
+	ts := newTestServer(t)
+	ts.expect(ts.do("GET", "/healthz", "", nil), http.StatusOK, nil)
+	var ready struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}
+	ts.expect(ts.do("GET", "/readyz", "", nil), http.StatusOK, &ready)
+	if ready.Status != "ok" || ready.Checks["store"] != "ok" || ready.Checks["templates"] != "ok" || ready.Checks["static"] != "ok" {
+		t.Fatalf("readyz = %+v", ready)
+	}
+}
+
+func TestUsers(t *testing.T) {
+	ts := newTestServer(t)
+
+	var users []model.User
+	ts.expect(ts.do("GET", api+"users", "", nil), http.StatusOK, &users)
+	if len(users) != 2 || users[0].ID != "bob" || users[1].ID != "alice" {
+		t.Fatalf("users = %+v", users)
+	}
+
+	var user model.User
+	ts.expect(ts.do("GET", api+"users/alice", "", nil), http.StatusOK, &user)
+	if user.Name != "alice" || len(user.ProjectIDs) != 0 {
+		t.Fatalf("user = %+v", user)
+	}
+	ts.expectError(ts.do("GET", api+"users/carol", "", nil), http.StatusNotFound, "user_not_found")
+	ts.expectError(ts.do("GET", api+"users/nobody", "", nil), http.StatusNotFound, "user_not_found")
+
+	// the listing follows roles set since, not the auth column
+	ts.store.SetRole("carol", model.RoleAuthor)
+	ts.store.SetRole("bob", model.RoleSuspended)
+	ts.expect(ts.do("GET", api+"users", "", nil), http.StatusOK, &users)
+	if len(users) != 2 || users[0].ID != "carol" || users[1].ID != "alice" {
+		t.Fatalf("users after role changes = %+v", users)
+	}
+	ts.expectError(ts.do("GET", api+"users/bob", "", nil), http.StatusNotFound, "user_not_found")
+	ts.expect(ts.do("PUT", api+"profile", "carol", gin.H{"newDescription": "promoted"}), http.StatusOK, nil)
+}
+
+func TestUpdateProfile(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.expectError(ts.do("PUT", api+"profile", "", gin.H{"newDescription": "hi"}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("PUT", api+"profile", "alice", "{"), http.StatusBadRequest, "invalid_body")
+
+	var user model.User
+	ts.expect(ts.do("PUT", api+"profile", "alice", gin.H{"newDescription": "hello", "newGithubId": "alice-gh"}), http.StatusOK, &user)
+	if user.Description != "hello" || user.GithubId != "alice-gh" {
+		t.Fatalf("user = %+v", user)
+	}
+	ts.expect(ts.do("GET", api+"users/alice", "", nil), http.StatusOK, &user)
+	if user.Description != "hello" {
+		t.Fatalf("profile was not saved: %+v", user)
+	}
+}
+
+func TestProjects(t *testing.T) {
+	ts := newTestServer(t)
+
+	ts.expectError(ts.do("POST", api+"projects", "", gin.H{"name": "diary"}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("POST", api+"projects", "carol", gin.H{"name": "diary"}), http.StatusForbidden, "forbidden")
+	ts.expectError(ts.do("POST", api+"projects", "alice", gin.H{"description": "no name"}), http.StatusBadRequest, "invalid_body")
+	ts.expectError(ts.do("POST", api+"projects", "alice", gin.H{"name": "bad name!"}), http.StatusBadRequest, "invalid_project_name")
+
+	project := ts.createProject("alice", "diary")
+	if project.UserID != "alice" || len(project.Member) != 1 {
+		t.Fatalf("project = %+v", project)
+	}
+	ts.expectError(ts.do("POST", api+"projects", "bob", gin.H{"name": "diary"}), http.StatusConflict, "project_name_taken")
+
+	var got model.Project
+	ts.expect(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusOK, &got)
+	if got.Name != "diary" || got.Description != "about diary" {
+		t.Fatalf("project = %+v", got)
+	}
+	ts.expectError(ts.do("GET", api+"projects/missing", "", nil), http.StatusNotFound, "project_not_found")
+
+	var projects []model.Project
+	ts.expect(ts.do("GET", api+"projects", "", nil), http.StatusOK, &projects)
+	if len(projects) != 1 || projects[0].PostCount != 0 {
+		t.Fatalf("projects = %+v", projects)
+	}
+}
+
+func TestUpdateProject(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+
+	ts.expectError(ts.do("PUT", api+"projects/missing", "alice", gin.H{}), http.StatusNotFound, "project_not_found")
+	ts.expectError(ts.do("PUT", api+"projects/"+project.ID, "", gin.H{"newDisplayName": "x"}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("PUT", api+"projects/"+project.ID, "bob", gin.H{"newDisplayName": "x"}), http.StatusForbidden, "forbidden")
+	ts.expectError(ts.do("PUT", api+"projects/"+project.ID, "alice", gin.H{"newUserId": "bob"}), http.StatusBadRequest, "invalid_owner")
+
+	var updated model.Project
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID, "alice", gin.H{"newDisplayName": "Diary", "invites": []string{"bob"}}), http.StatusOK, &updated)
+	if updated.DisplayName != "Diary" {
+		t.Fatalf("project = %+v", updated)
+	}
+	ts.expect(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusOK, &updated)
+	if len(updated.Member) != 2 {
+		t.Fatalf("member = %v, want alice and bob", updated.Member)
+	}
+
+	// ownership moves to bob, after which alice can no longer update it
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID, "alice", gin.H{"newUserId": "bob"}), http.StatusOK, &updated)
+	ts.expectError(ts.do("PUT", api+"projects/"+project.ID, "alice", gin.H{"newDisplayName": "mine"}), http.StatusForbidden, "forbidden")
+
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID, "bob", gin.H{"removes": []string{"alice"}}), http.StatusOK, nil)
+	ts.expect(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusOK, &updated)
+	if len(updated.Member) != 1 || updated.Member[0] != "bob" {
+		t.Fatalf("member = %v, want bob", updated.Member)
+	}
+}
+
+func TestDeleteProject(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+	post := ts.createPost("alice", project.ID, "first")
+
+	ts.expectError(ts.do("DELETE", api+"projects/"+project.ID, "bob", nil), http.StatusForbidden, "forbidden")
+	ts.expect(ts.do("DELETE", api+"projects/"+project.ID, "alice", nil), http.StatusOK, nil)
+	ts.expectError(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusNotFound, "project_not_found")
+	ts.expectError(ts.do("GET", api+"posts/"+post.ID, "", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("DELETE", api+"projects/"+project.ID, "alice", nil), http.StatusNotFound, "project_not_found")
+}
+
+func TestPosts(t *testing.T) {
+	ts := newTestServer(t)
+	diary := ts.createProject("alice", "diary")
+	notes := ts.createProject("bob", "notes")
+
+	ts.expectError(ts.do("POST", api+"projects/"+diary.ID+"/posts", "", gin.H{"title": "t", "content": "c"}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("POST", api+"projects/"+diary.ID+"/posts", "carol", gin.H{"title": "t", "content": "c"}), http.StatusForbidden, "forbidden")
+	var invalid struct {
+		Fields map[string]string `json:"fields"`
+	}
+	ts.expect(ts.do("POST", api+"projects/"+diary.ID+"/posts", "alice", gin.H{"title": "t"}), http.StatusBadRequest, &invalid)
+	if invalid.Fields["content"] != "required" {
+		t.Fatalf("fields = %v", invalid.Fields)
+	}
+
+	first := ts.createPost("alice", diary.ID, "first")
+	second := ts.createPost("alice", diary.ID, "second")
+	other := ts.createPost("bob", notes.ID, "other")
+	if first.Number != 0 || second.Number != 1 || other.Number != 0 {
+		t.Fatalf("numbers = %d %d %d", first.Number, second.Number, other.Number)
+	}
+
+	var post model.Post
+	ts.expect(ts.do("GET", api+"posts/"+first.ID, "", nil), http.StatusOK, &post)
+	if post.Title != "first" || post.ProjectID != diary.ID {
+		t.Fatalf("post = %+v", post)
+	}
+	ts.expectError(ts.do("GET", api+"posts/missing", "", nil), http.StatusNotFound, "post_not_found")
+
+	ts.expect(ts.do("GET", api+"projects/"+diary.ID+"/post?id=1", "", nil), http.StatusOK, &post)
+	if post.ID != second.ID {
+		t.Fatalf("post = %+v, want %s", post, second.ID)
+	}
+	ts.expectError(ts.do("GET", api+"projects/"+diary.ID+"/post?id=x", "", nil), http.StatusBadRequest, "invalid_query")
+	ts.expectError(ts.do("GET", api+"projects/"+diary.ID+"/post?id=9", "", nil), http.StatusNotFound, "post_not_found")
+
+	var posts []model.Post
+	ts.expect(ts.do("GET", api+"posts?limit=10", "", nil), http.StatusOK, &posts)
+	if len(posts) != 3 {
+		t.Fatalf("posts = %d, want 3", len(posts))
+	}
+	ts.expect(ts.do("GET", api+"projects/"+diary.ID+"/posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 2 || posts[0].ID != second.ID || posts[1].ID != first.ID {
+		t.Fatalf("project posts = %+v", posts)
+	}
+	ts.expect(ts.do("GET", api+"users/bob/posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 1 || posts[0].ID != other.ID {
+		t.Fatalf("user posts = %+v", posts)
+	}
+
+	var project model.Project
+	ts.expect(ts.do("GET", api+"projects/"+diary.ID, "", nil), http.StatusOK, &project)
+	if project.PostCount != 2 {
+		t.Fatalf("post count = %d, want 2", project.PostCount)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+	ids := make([]string, 0)
+	for i := 0; i < 5; i++ {
+		ids = append([]string{ts.createPost("alice", project.ID, "post "+strconv.Itoa(i)).ID}, ids...)
+	}
+
+	var posts []model.Post
+	ts.expect(ts.do("GET", api+"posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 3 {
+		t.Fatalf("default limit returned %d posts, want 3", len(posts))
+	}
+	ts.expect(ts.do("GET", api+"posts?offset=3&limit=10", "", nil), http.StatusOK, &posts)
+	if len(posts) != 2 || posts[0].ID != ids[3] {
+		t.Fatalf("offset page = %+v", posts)
+	}
+	ts.expect(ts.do("GET", api+"posts?offset=10", "", nil), http.StatusOK, &posts)
+	if len(posts) != 0 {
+		t.Fatalf("page past the end = %+v", posts)
+	}
+	ts.expect(ts.do("GET", api+"posts?limit=0", "", nil), http.StatusOK, &posts)
+	if len(posts) != 0 {
+		t.Fatalf("limit 0 = %+v", posts)
+	}
+	ts.expectError(ts.do("GET", api+"posts?limit=-1", "", nil), http.StatusBadRequest, "invalid_query")
+	ts.expectError(ts.do("GET", api+"posts?offset=-1", "", nil), http.StatusBadRequest, "invalid_query")
+	ts.expectError(ts.do("GET", api+"posts?cursor=&limit=0", "", nil), http.StatusBadRequest, "invalid_query")
+	ts.expectError(ts.do("GET", api+"posts?cursor=garbage", "", nil), http.StatusBadRequest, "invalid_cursor")
+
+	seen := make([]string, 0)
+	cursor := ""
+	for i := 0; ; i++ {
+		if i > 5 {
+			t.Fatal("cursor pagination does not end")
+		}
+		var page struct {
+			Items      []model.Post `json:"items"`
+			NextCursor *string      `json:"nextCursor"`
+		}
+		ts.expect(ts.do("GET", api+"projects/"+project.ID+"/posts?limit=2&cursor="+cursor, "", nil), http.StatusOK, &page)
+		for _, post := range page.Items {
+			seen = append(seen, post.ID)
+		}
+		if page.NextCursor == nil {
+			break
+		}
+		cursor = *page.NextCursor
+	}
+	if strings.Join(seen, ",") != strings.Join(ids, ",") {
+		t.Fatalf("cursor pages = %v, want %v", seen, ids)
+	}
+}
+
+func TestUpdatePost(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary", "bob")
+	post := ts.createPost("alice", project.ID, "first")
+
+	ts.expectError(ts.do("PUT", api+"posts/missing", "alice", gin.H{}), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("PUT", api+"posts/"+post.ID, "", gin.H{"newTitle": "x"}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("PUT", api+"posts/"+post.ID, "bob", gin.H{"newTitle": "x"}), http.StatusForbidden, "forbidden")
+
+	var updated model.Post
+	ts.expect(ts.do("PUT", api+"posts/"+post.ID, "alice", gin.H{"newTitle": "renamed"}), http.StatusOK, &updated)
+	if updated.Title != "renamed" || updated.Content != post.Content {
+		t.Fatalf("post = %+v", updated)
+	}
+	ts.expect(ts.do("GET", api+"posts/"+post.ID, "", nil), http.StatusOK, &updated)
+	if updated.Title != "renamed" {
+		t.Fatalf("update was not saved: %+v", updated)
+	}
+}
+
+func TestDeletePost(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.AddUser(model.User{ID: "dave", Name: "dave", Auth: "default"})
+	project := ts.createProject("alice", "diary", "bob", "dave")
+	byBob := ts.createPost("bob", project.ID, "bob's")
+	byDave := ts.createPost("dave", project.ID, "dave's")
+	comment := ts.createComment("carol", byBob.ID, "nice")
+
+	ts.expectError(ts.do("DELETE", api+"posts/missing", "alice", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("DELETE", api+"posts/"+byBob.ID, "", nil), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("DELETE", api+"posts/"+byBob.ID, "dave", nil), http.StatusForbidden, "forbidden")
+
+	// the author and the project owner may both delete
+	ts.expect(ts.do("DELETE", api+"posts/"+byBob.ID, "bob", nil), http.StatusOK, nil)
+	ts.expect(ts.do("DELETE", api+"posts/"+byDave.ID, "alice", nil), http.StatusOK, nil)
+
+	ts.expectError(ts.do("GET", api+"posts/"+byBob.ID, "", nil), http.StatusNotFound, "post_not_found")
+	ts.expectError(ts.do("GET", api+"comments/"+comment.ID, "", nil), http.StatusNotFound, "comment_not_found")
+	var posts []model.Post
+	ts.expect(ts.do("GET", api+"projects/"+project.ID+"/posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 0 {
+		t.Fatalf("deleted posts are listed: %+v", posts)
+	}
+	ts.expect(ts.do("GET", api+"users/bob/posts", "", nil), http.StatusOK, &posts)
+	if len(posts) != 0 {
+		t.Fatalf("deleted posts are listed: %+v", posts)
+	}
+	var project2 model.Project
+	ts.expect(ts.do("GET", api+"projects/"+project.ID, "", nil), http.StatusOK, &project2)
+	if project2.PostCount != 0 {
+		t.Fatalf("deleted posts are counted: %d", project2.PostCount)
+	}
+
+	// numbers keep increasing after a delete
+	next := ts.createPost("alice", project.ID, "after")
+	if next.Number != 2 {
+		t.Fatalf("number = %d, want 2", next.Number)
+	}
+}
+
+func TestComments(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+	post := ts.createPost("alice", project.ID, "first")
+
+	ts.expectError(ts.do("POST", api+"posts/"+post.ID+"/comments", "", gin.H{"content": "hi"}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("POST", api+"posts/"+post.ID+"/comments", "carol", gin.H{}), http.StatusBadRequest, "invalid_body")
+	ts.expectError(ts.do("POST", api+"posts/missing/comments", "carol", gin.H{"content": "hi"}), http.StatusNotFound, "post_not_found")
+
+	first := ts.createComment("carol", post.ID, "first!")
+	second := ts.createComment("bob", post.ID, "second")
+
+	var comment model.Comment
+	ts.expect(ts.do("GET", api+"comments/"+first.ID, "", nil), http.StatusOK, &comment)
+	if comment.Content != "first!" || comment.UserID != "carol" {
+		t.Fatalf("comment = %+v", comment)
+	}
+	ts.expectError(ts.do("GET", api+"comments/missing", "", nil), http.StatusNotFound, "comment_not_found")
+
+	var comments []model.Comment
+	ts.expect(ts.do("GET", api+"posts/"+post.ID+"/comments", "", nil), http.StatusOK, &comments)
+	if len(comments) != 2 || comments[0].ID != second.ID {
+		t.Fatalf("comments = %+v", comments)
+	}
+	ts.expect(ts.do("GET", api+"posts/"+post.ID+"/comments?limit=1&offset=1", "", nil), http.StatusOK, &comments)
+	if len(comments) != 1 || comments[0].ID != first.ID {
+		t.Fatalf("comments = %+v", comments)
+	}
+	ts.expectError(ts.do("GET", api+"posts/"+post.ID+"/comments?limit=-5", "", nil), http.StatusBadRequest, "invalid_query")
+
+	var counted model.Post
+	ts.expect(ts.do("GET", api+"posts/"+post.ID, "", nil), http.StatusOK, &counted)
+	if counted.CommentNum != 2 {
+		t.Fatalf("comment count = %d, want 2", counted.CommentNum)
+	}
+}
+
+func TestDeleteComment(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+	post := ts.createPost("alice", project.ID, "first")
+	comment := ts.createComment("carol", post.ID, "hello")
+
+	ts.expectError(ts.do("DELETE", api+"comments/missing", "carol", nil), http.StatusNotFound, "comment_not_found")
+	ts.expectError(ts.do("DELETE", api+"comments/"+comment.ID, "", nil), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("DELETE", api+"comments/"+comment.ID, "bob", nil), http.StatusForbidden, "forbidden")
+	ts.expect(ts.do("DELETE", api+"comments/"+comment.ID, "carol", nil), http.StatusOK, nil)
+
+	ts.expectError(ts.do("GET", api+"comments/"+comment.ID, "", nil), http.StatusNotFound, "comment_not_found")
+	var comments []model.Comment
+	ts.expect(ts.do("GET", api+"posts/"+post.ID+"/comments", "", nil), http.StatusOK, &comments)
+	if len(comments) != 0 {
+		t.Fatalf("deleted comments are listed: %+v", comments)
+	}
+	var counted model.Post
+	ts.expect(ts.do("GET", api+"posts/"+post.ID, "", nil), http.StatusOK, &counted)
+	if counted.CommentNum != 0 {
+		t.Fatalf("deleted comments are counted: %d", counted.CommentNum)
+	}
+}
+
+func TestPages(t *testing.T) {
+	ts := newTestServer(t)
+	ts.do("PUT", api+"profile", "alice", gin.H{"newDescription": "writes a diary"})
+	project := ts.createProject("alice", "diary")
+	post := ts.createPost("alice", project.ID, "first")
+
+	pages := []struct {
+		path   string
+		status int
+		title  string
+		url    string
+	}{
+		{"/blog/", http.StatusOK, "CLOG", "https://blog.example/blog/"},
+		{"/blog/users", http.StatusOK, "CLOG", "https://blog.example/blog/"},
+		{"/blog/projects", http.StatusOK, "CLOG", "https://blog.example/blog/projects"},
+		{"/blog/mypage", http.StatusOK, "CLOG", "https://blog.example/blog/mypage"},
+		{"/blog/users/alice", http.StatusOK, "alice", "https://blog.example/blog/users/alice"},
+		{"/blog/users/nobody", http.StatusNotFound, "CLOG", "https://blog.example/blog/"},
+		{"/blog/projects/diary", http.StatusOK, "diary", "https://blog.example/blog/projects/diary"},
+		{"/blog/projects/missing", http.StatusNotFound, "CLOG", "https://blog.example/blog/"},
+		{"/blog/projects/diary/posts/0", http.StatusOK, "diary - first", "https://blog.example/blog/projects/diary/post/0"},
+		{"/blog/projects/diary/posts/7", http.StatusNotFound, "CLOG", "https://blog.example/blog/"},
+		{"/blog/projects/diary/posts/x", http.StatusNotFound, "CLOG", "https://blog.example/blog/"},
+	}
+	for _, page := range pages {
+		rec := ts.do("GET", page.path, "", nil)
+		if rec.Code != page.status {
+			t.Errorf("%s: status = %d, want %d", page.path, rec.Code, page.status)
+			continue
+		}
+		body := rec.Body.String()
+		if !strings.Contains(body, "<title>"+template.HTMLEscapeString(page.title)+"</title>") || !strings.Contains(body, `content="`+page.url+`"`) {
+			t.Errorf("%s: body = %s", page.path, body)
+		}
+	}
+
+	rec := ts.do("GET", "/blog/projects/diary/"+post.ID, "", nil)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://blog.example/blog/projects/diary/posts/0" {
+		t.Fatalf("redirect = %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+
+	ts.expect(ts.do("DELETE", api+"posts/"+post.ID, "alice", nil), http.StatusOK, nil)
+	rec = ts.do("GET", "/blog/projects/diary/posts/0", "", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("deleted post page = %d, want 404", rec.Code)
+	}
+}
+
+func TestStatic(t *testing.T) {
+	ts := newTestServer(t)
+
+	rec := ts.do("GET", "/blog/static/js/app.js", "", nil)
+	if rec.Code != http.StatusOK || rec.Body.String() != "console.log('app')" || rec.Header().Get("Content-Type") != "application/javascript" {
+		t.Fatalf("js = %d %q %q", rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+	rec = ts.do("GET", "/blog/static/css/app.css", "", nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/css" {
+		t.Fatalf("css = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	req := httptest.NewRequest("GET", "/blog/static/js/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	gz := httptest.NewRecorder()
+	ts.server.ServeHTTP(gz, req)
+	if gz.Code != http.StatusOK || gz.Body.String() != "gzipped" || gz.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip = %d %q", gz.Code, gz.Body.String())
+	}
+
+	if rec := ts.do("GET", "/blog/static/js/missing.js", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("missing asset = %d, want 404", rec.Code)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.AddUser(model.User{ID: "root", Name: "root", Auth: "admin"})
+	project := ts.createProject("alice", "diary")
+	post := ts.createPost("alice", project.ID, "first")
+	comment := ts.createComment("bob", post.ID, "hello")
+
+	// admins may change anything
+	ts.expect(ts.do("PUT", api+"posts/"+post.ID, "root", gin.H{"newTitle": "edited"}), http.StatusOK, nil)
+	ts.expect(ts.do("DELETE", api+"comments/"+comment.ID, "root", nil), http.StatusOK, nil)
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID, "root", gin.H{"newDisplayName": "Diary"}), http.StatusOK, nil)
+
+	// a role stored for the user wins over the users table
+	err := ts.store.SetRole("carol", model.RoleAuthor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.createProject("carol", "notes")
+
+	err = ts.store.SetRole("alice", model.RoleSuspended)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expectError(ts.do("POST", api+"posts/"+post.ID+"/comments", "alice", gin.H{"content": "hi"}), http.StatusForbidden, "suspended")
+	ts.expectError(ts.do("DELETE", api+"posts/"+post.ID, "alice", nil), http.StatusForbidden, "suspended")
+	ts.expectError(ts.do("PUT", api+"profile", "alice", gin.H{"newDescription": "hi"}), http.StatusForbidden, "suspended")
+
+	err = ts.store.SetRole("alice", model.RoleAuthor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expect(ts.do("DELETE", api+"posts/"+post.ID, "alice", nil), http.StatusOK, nil)
+
+	if err := ts.store.SetRole("alice", "owner"); !errors.Is(err, model.ErrValidation) {
+		t.Fatalf("SetRole(owner) = %v, want a validation error", err)
+	}
+	if err := ts.store.SetRole("nobody", model.RoleAuthor); !errors.Is(err, model.ErrNotFound) {
+		t.Fatalf("SetRole(nobody) = %v, want not found", err)
+	}
+}
+
+func TestMembers(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.AddUser(model.User{ID: "dave", Name: "dave", Auth: "default"})
+	project := ts.createProject("alice", "diary")
+	members := api + "projects/" + project.ID + "/members"
+
+	// authors outside the project cannot post to it
+	ts.expectError(ts.do("POST", api+"projects/"+project.ID+"/posts", "bob", gin.H{"title": "t", "content": "c"}), http.StatusForbidden, "not_a_writer")
+	ts.expectError(ts.do("POST", api+"projects/missing/posts", "bob", gin.H{"title": "t", "content": "c"}), http.StatusNotFound, "project_not_found")
+
+	ts.expectError(ts.do("PUT", members+"/bob", "bob", gin.H{"role": "writer"}), http.StatusForbidden, "forbidden")
+	ts.expectError(ts.do("PUT", members+"/bob", "alice", gin.H{"role": "boss"}), http.StatusBadRequest, "invalid_member_role")
+	ts.expectError(ts.do("PUT", members+"/bob", "alice", gin.H{"role": "owner"}), http.StatusBadRequest, "owner_role")
+	ts.expectError(ts.do("PUT", members+"/alice", "alice", gin.H{"role": "viewer"}), http.StatusBadRequest, "owner_role")
+	ts.expectError(ts.do("PUT", members+"/nobody", "alice", gin.H{"role": "viewer"}), http.StatusNotFound, "user_not_found")
+	ts.expect(ts.do("PUT", members+"/bob", "alice", gin.H{"role": "viewer"}), http.StatusOK, nil)
+	ts.expect(ts.do("PUT", members+"/dave", "alice", gin.H{"role": "writer"}), http.StatusOK, nil)
+
+	var list []model.Member
+	ts.expect(ts.do("GET", members, "", nil), http.StatusOK, &list)
+	want := []model.Member{{UserID: "alice", Role: model.MemberOwner}, {UserID: "dave", Role: model.MemberWriter}, {UserID: "bob", Role: model.MemberViewer}}
+	if len(list) != len(want) || list[0] != want[0] || list[1] != want[1] || list[2] != want[2] {
+		t.Fatalf("members = %+v, want %+v", list, want)
+	}
+
+	// viewers cannot post; writers can, but only edit their own posts
+	ts.expectError(ts.do("POST", api+"projects/"+project.ID+"/posts", "bob", gin.H{"title": "t", "content": "c"}), http.StatusForbidden, "not_a_writer")
+	byAlice := ts.createPost("alice", project.ID, "alice's")
+	byDave := ts.createPost("dave", project.ID, "dave's")
+	ts.expectError(ts.do("PUT", api+"posts/"+byAlice.ID, "dave", gin.H{"newTitle": "x"}), http.StatusForbidden, "forbidden")
+
+	// editors can edit and delete other members' posts
+	ts.expect(ts.do("PUT", members+"/bob", "alice", gin.H{"role": "editor"}), http.StatusOK, nil)
+	ts.expect(ts.do("PUT", api+"posts/"+byDave.ID, "bob", gin.H{"newTitle": "edited"}), http.StatusOK, nil)
+	ts.expect(ts.do("DELETE", api+"posts/"+byDave.ID, "bob", nil), http.StatusOK, nil)
+	ts.expectError(ts.do("DELETE", members+"/dave", "bob", nil), http.StatusForbidden, "forbidden")
+
+	ts.expectError(ts.do("DELETE", members+"/alice", "alice", nil), http.StatusBadRequest, "owner_role")
+	ts.expect(ts.do("DELETE", members+"/dave", "alice", nil), http.StatusOK, nil)
+	ts.expectError(ts.do("DELETE", members+"/dave", "alice", nil), http.StatusNotFound, "member_not_found")
+	ts.expectError(ts.do("POST", api+"projects/"+project.ID+"/posts", "dave", gin.H{"title": "t", "content": "c"}), http.StatusForbidden, "not_a_writer")
+
+	// transferring ownership keeps the previous owner as an editor
+	ts.expect(ts.do("PUT", api+"projects/"+project.ID, "alice", gin.H{"newUserId": "bob"}), http.StatusOK, nil)
+	ts.expect(ts.do("GET", members, "", nil), http.StatusOK, &list)
+	if len(list) != 2 || list[0] != (model.Member{UserID: "bob", Role: model.MemberOwner}) || list[1] != (model.Member{UserID: "alice", Role: model.MemberEditor}) {
+		t.Fatalf("members after transfer = %+v", list)
+	}
+}
@@ -1,10 +1,11 @@
 package handler
 
 import (
-	"io/ioutil"
-	"log"
+	"fmt"
+	"io/fs"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -13,33 +14,22 @@ import (
 	"github.com/n-inja/go-blog/model"
 )
 
-var hostname string
-var fileMap map[string][]byte
-
-func init() {
-	fileMap = make(map[string][]byte, 8)
-	js := filepath.Join(os.Getenv("BLOG_STATIC_FILE_PATH"), "static", "js")
-	css := filepath.Join(os.Getenv("BLOG_STATIC_FILE_PATH"), "static", "css")
-	files, err := ioutil.ReadDir(js)
-	if err != nil {
-		log.Fatal(err)
-	}
-	for _, f := range files {
-		fileMap[filepath.Join("js", f.Name())], err = ioutil.ReadFile(filepath.Join(js, f.Name()))
+// loadStatic reads the js and css assets once so they can be served from memory.
+func loadStatic(static fs.FS) (map[string][]byte, error) {
+	fileMap := make(map[string][]byte, 8)
+	for _, directory := range []string{"js", "css"} {
+		files, err := fs.ReadDir(static, path.Join("static", directory))
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-	}
-	files, err = ioutil.ReadDir(css)
-	if err != nil {
-		log.Fatal(err)
-	}
-	for _, f := range files {
-		fileMap[filepath.Join("css", f.Name())], err = ioutil.ReadFile(filepath.Join(css, f.Name()))
-		if err != nil {
-			log.Fatal(err)
+		for _, f := range files {
+			fileMap[filepath.Join(directory, f.Name())], err = fs.ReadFile(static, path.Join("static", directory, f.Name()))
+			if err != nil {
+				return nil, err
+			}
 		}
 	}
+	return fileMap, nil
 }
 
 func summarize(content []rune) string {
@@ -50,143 +40,173 @@ func summarize(content []rune) string {
 	return strings.Replace(string(content[0:length]), "\"", "", 0)
 }
 
-func returnNotFound(c *gin.Context) {
+func (s *Server) returnNotFound(c *gin.Context) {
 	c.HTML(http.StatusNotFound, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/",
-		"title":       "CLOG",
+		"url":         s.pageURL("/"),
+		"title":       s.config.Site.Title,
 		"description": "",
-		"imageURL":    "https://" + hostname + "/static/favicon.png",
+		"imageURL":    s.config.Site.FaviconURL,
 	})
 }
 
-func LoadTMPL(e *gin.Engine) {
-	hostname = os.Getenv("HOSTNAME")
-
-	e.LoadHTMLGlob(os.Getenv("BLOG_STATIC_FILE_PATH") + "/*.tmpl")
+// pageURL returns the absolute URL of a page below the page route prefix.
+func (s *Server) pageURL(path string) string {
+	return "https://" + s.config.Site.Hostname + "/" + s.config.Routes.Pages + path
 }
 
-func SetTop(c *gin.Context) {
+func (s *Server) SetTop(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/",
-		"title":       "CLOG",
+		"url":         s.pageURL("/"),
+		"title":       s.config.Site.Title,
 		"description": "",
-		"imageURL":    "https://" + hostname + "/static/favicon.png",
+		"imageURL":    s.config.Site.FaviconURL,
 	})
 }
 
-func SetUsers(c *gin.Context) {
+func (s *Server) SetUsers(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/",
-		"title":       "CLOG",
+		"url":         s.pageURL("/"),
+		"title":       s.config.Site.Title,
 		"description": "",
-		"imageURL":    "https://" + hostname + "/static/favicon.png",
+		"imageURL":    s.config.Site.FaviconURL,
 	})
 }
 
-func SetProjects(c *gin.Context) {
+func (s *Server) SetProjects(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/projects",
-		"title":       "CLOG",
+		"url":         s.pageURL("/projects"),
+		"title":       s.config.Site.Title,
 		"description": "",
-		"imageURL":    "https://" + hostname + "/static/favicon.png",
+		"imageURL":    s.config.Site.FaviconURL,
 	})
 }
 
-func SetMyPage(c *gin.Context) {
+func (s *Server) SetMyPage(c *gin.Context) {
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/mypage",
-		"title":       "CLOG",
+		"url":         s.pageURL("/mypage"),
+		"title":       s.config.Site.Title,
 		"description": "",
-		"imageURL":    "https://" + hostname + "/static/favicon.png",
+		"imageURL":    s.config.Site.FaviconURL,
 	})
 }
 
-func SetUser(c *gin.Context) {
+func (s *Server) SetUser(c *gin.Context) {
 	userID := c.Param("userID")
 
-	user, err := model.GetUser(userID)
+	user, err := s.storeFor(c).GetUser(userID)
 	if err != nil {
-		returnNotFound(c)
+		s.returnNotFound(c)
 		return
 	}
 	imageURL := user.IconSrc
 	if imageURL != "" {
-		imageURL = "https://" + hostname + "/static/favicon.png"
+		imageURL = s.config.Site.FaviconURL
 	}
 
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/users/" + user.Name,
+		"url":         s.pageURL("/users/" + user.Name),
 		"title":       user.Name,
 		"description": summarize([]rune(user.Description)),
 		"imageURL":    imageURL,
 	})
 }
 
-func SetProject(c *gin.Context) {
+func (s *Server) SetProject(c *gin.Context) {
 	projectName := c.Param("projectName")
 
-	project, err := model.GetProjectByName(projectName)
+	project, err := s.storeFor(c).GetProjectByName(projectName)
 	if err != nil {
-		returnNotFound(c)
+		s.returnNotFound(c)
 		return
 	}
 
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/projects/" + projectName,
+		"url":         s.pageURL("/projects/" + projectName),
 		"title":       project.Name,
 		"description": summarize([]rune(project.Description)),
-		"imageURL":    "https://" + hostname + "/static/favicon.png",
+		"imageURL":    s.config.Site.FaviconURL,
+	})
+}
+
+// SetTag serves the page of a tag. Tags written another way, or by an
+// alias, are redirected to the page of the tag they stand for.
+func (s *Server) SetTag(c *gin.Context) {
+	name, err := model.NormalizeTag(c.Param("tag"))
+	if err != nil {
+		s.returnNotFound(c)
+		return
+	}
+	resolved, err := s.storeFor(c).ResolveTags([]string{name})
+	if err != nil {
+		s.returnNotFound(c)
+		return
+	}
+	if resolved[0] != c.Param("tag") {
+		c.Redirect(http.StatusMovedPermanently, s.pageURL("/tags/"+url.PathEscape(resolved[0])))
+		return
+	}
+	tag, err := s.storeFor(c).GetTag(resolved[0])
+	if err != nil {
+		s.returnNotFound(c)
+		return
+	}
+
+	c.HTML(http.StatusOK, "index.tmpl", gin.H{
+		"url":         s.pageURL("/tags/" + url.PathEscape(tag.Name)),
+		"title":       tag.Name,
+		"description": fmt.Sprintf("%d posts tagged %s", tag.Count, tag.Name),
+		"imageURL":    s.config.Site.FaviconURL,
 	})
 }
 
-func SetPost(c *gin.Context) {
+func (s *Server) SetPost(c *gin.Context) {
 	postID := c.Param("postID")
 	projectName := c.Param("projectName")
 
 	if postID != "posts" {
-		post, err := model.GetPost(postID)
-		if err != nil {
-			returnNotFound(c)
+		post, err := s.storeFor(c).GetPost(postID)
+		if err != nil || !post.Published() {
+			s.returnNotFound(c)
 			return
 		}
-		c.Redirect(http.StatusMovedPermanently, "https://"+hostname+"/blog/projects/"+projectName+"/posts/"+strconv.Itoa(post.Number))
+		c.Redirect(http.StatusMovedPermanently, s.pageURL("/projects/"+projectName+"/posts/"+strconv.Itoa(post.Number)))
 		return
 	}
 
 	number, err := strconv.Atoi(c.Param("number"))
 
 	if err != nil {
-		returnNotFound(c)
+		s.returnNotFound(c)
 		return
 	}
 
-	project, err := model.GetProjectByName(projectName)
+	project, err := s.storeFor(c).GetProjectByName(projectName)
 
 	if err != nil {
-		returnNotFound(c)
+		s.returnNotFound(c)
 		return
 	}
 
-	post, err := model.GetProjectPostById(project.ID, number)
-	if err != nil {
-		returnNotFound(c)
+	post, err := s.storeFor(c).GetProjectPostById(project.ID, number)
+	if err != nil || !post.Published() {
+		s.returnNotFound(c)
 		return
 	}
 
 	imageURL := post.ThumbSrc
 	if imageURL == "" {
-		imageURL = "https://" + hostname + "/static/favicon.png"
+		imageURL = s.config.Site.FaviconURL
 	}
 
 	c.HTML(http.StatusOK, "index.tmpl", gin.H{
-		"url":         "https://" + hostname + "/blog/projects/" + projectName + "/post/" + strconv.Itoa(number),
+		"url":         s.pageURL("/projects/" + projectName + "/post/" + strconv.Itoa(number)),
 		"title":       project.Name + " - " + post.Title,
 		"description": summarize([]rune(post.Content)),
 		"imageURL":    imageURL,
 	})
 }
 
-func ServeStatic(c *gin.Context) {
+func (s *Server) ServeStatic(c *gin.Context) {
 	path := filepath.Join(c.Param("directory"), c.Param("filename"))
 	mime := ""
 	if filepath.Ext(path) == ".js" {
@@ -204,7 +224,7 @@ func ServeStatic(c *gin.Context) {
 
 	var bytes []byte
 	var ok bool
-	bytes, ok = fileMap[path]
+	bytes, ok = s.files[path]
 
 	if !ok {
 		c.String(http.StatusNotFound, "", gin.H{})
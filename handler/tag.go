@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+// resolveTags normalizes raw tags and replaces aliases with their tags as
+// store knows them; writes pass the primary, so that they never save an
+// alias a replica has not caught up on. It renders the error itself and
+// returns false when a tag is invalid.
+func resolveTags(c *gin.Context, store model.TagStore, raw []string) ([]string, bool) {
+	tags := make([]string, 0, len(raw))
+	for _, r := range raw {
+		tag, err := model.NormalizeTag(r)
+		if err != nil {
+			renderError(c, err)
+			return nil, false
+		}
+		tags = append(tags, tag)
+	}
+	tags, err := store.ResolveTags(tags)
+	if err != nil {
+		renderError(c, err)
+		return nil, false
+	}
+	return tags, true
+}
+
+// tagFilter reads the tags a listing is narrowed to, given as repeated tag
+// parameters or a comma separated tags one, and match, any or all.
+func (s *Server) tagFilter(c *gin.Context) (model.TagFilter, bool) {
+	raw := c.QueryArray("tag")
+	if tags := c.Query("tags"); tags != "" {
+		raw = append(raw, strings.Split(tags, ",")...)
+	}
+	match := c.DefaultQuery("match", "any")
+	if match != "any" && match != "all" {
+		renderError(c, model.Validation("invalid_query", "match should be any or all", map[string]string{"match": "must be any or all"}))
+		return model.TagFilter{}, false
+	}
+	tags, ok := resolveTags(c, s.storeFor(c), raw)
+	return model.TagFilter{Tags: tags, All: match == "all"}, ok
+}
+
+// GetTags lists every tag on a published post with the number of posts.
+func (s *Server) GetTags(c *gin.Context) {
+	tags, err := s.storeFor(c).GetTags()
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tags)
+}
+
+type tagsForm struct {
+	Tags []string `json:"tags" form:"tags" binding:"max=10"`
+}
+
+// PutPostTags replaces the tags of a post.
+func (s *Server) PutPostTags(c *gin.Context) {
+	post, _, ok := s.editablePost(c)
+	if !ok {
+		return
+	}
+	var body tagsForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	tags, ok := resolveTags(c, s.store, body.Tags)
+	if !ok {
+		return
+	}
+	err = s.store.SetPostTags(post.ID, tags)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	before := post
+	post.Tags = append(make([]string, 0, len(tags)), tags...)
+	sort.Strings(post.Tags)
+	s.audit(c, "post.tag", "post", post.ID, before, post)
+	c.JSON(http.StatusOK, post)
+}
+
+func (s *Server) GetTagAliases(c *gin.Context) {
+	aliases, err := s.store.GetTagAliases()
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, aliases)
+}
+
+type tagAliasForm struct {
+	Tag string `json:"tag" form:"tag" binding:"required"`
+}
+
+// PutTagAlias makes the alias of the route stand for the tag of the body.
+// Posts already tagged with the alias get the tag instead.
+func (s *Server) PutTagAlias(c *gin.Context) {
+	var body tagAliasForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	alias, err := model.NormalizeTag(c.Param("alias"))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	tags, ok := resolveTags(c, s.store, []string{body.Tag})
+	if !ok {
+		return
+	}
+	err = s.store.SetTagAlias(alias, tags[0])
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	record := model.TagAlias{Alias: alias, Tag: tags[0]}
+	s.audit(c, "tag.alias", "tag", alias, nil, record)
+	c.JSON(http.StatusOK, record)
+}
+
+func (s *Server) DeleteTagAlias(c *gin.Context) {
+	alias, err := model.NormalizeTag(c.Param("alias"))
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	err = s.store.DeleteTagAlias(alias)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.audit(c, "tag.unalias", "tag", alias, nil, nil)
+	c.JSON(http.StatusOK, gin.H{})
+}
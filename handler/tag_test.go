@@ -0,0 +1,91 @@
+package handler_test
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestTags(t *testing.T) {
+	ts := newTestServer(t)
+	ts.store.SetRole("alice", model.RoleAdmin)
+	project := ts.createProject("alice", "diary")
+	first := ts.createPost("alice", project.ID, "first")
+	second := ts.createPost("alice", project.ID, "second")
+	ts.createPost("alice", project.ID, "untagged")
+
+	var post model.Post
+	ts.expect(ts.do("PUT", api+"posts/"+first.ID+"/tags", "alice", gin.H{"tags": []string{"Go Lang", "web", "WEB"}}), http.StatusOK, &post)
+	if !reflect.DeepEqual(post.Tags, []string{"go-lang", "web"}) {
+		t.Fatalf("tags = %v", post.Tags)
+	}
+	ts.expect(ts.do("PUT", api+"posts/"+second.ID+"/tags", "alice", gin.H{"tags": []string{"go_lang"}}), http.StatusOK, nil)
+	ts.expectError(ts.do("PUT", api+"posts/"+second.ID+"/tags", "alice", gin.H{"tags": []string{"<b>"}}), http.StatusBadRequest, "invalid_tag")
+	ts.expectError(ts.do("PUT", api+"posts/"+second.ID+"/tags", "bob", gin.H{"tags": []string{"mine"}}), http.StatusForbidden, "forbidden")
+
+	var tags []model.TagCount
+	ts.expect(ts.do("GET", api+"tags", "", nil), http.StatusOK, &tags)
+	if !reflect.DeepEqual(tags, []model.TagCount{{Name: "go-lang", Count: 2}, {Name: "web", Count: 1}}) {
+		t.Fatalf("tags = %+v", tags)
+	}
+
+	titles := func(path string) string {
+		t.Helper()
+		var posts []model.Post
+		ts.expect(ts.do("GET", api+path, "", nil), http.StatusOK, &posts)
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		return strings.Join(titles, ",")
+	}
+	for path, want := range map[string]string{
+		"posts?tag=GO-LANG":                                     "second,first",
+		"posts?tags=web,go-lang&match=all":                      "first",
+		"posts?tag=web&tag=go-lang":                             "second,first",
+		"users/alice/posts?tag=web":                             "first",
+		"projects/" + project.ID + "/posts?tag=nothing":         "",
+		"projects/" + project.ID + "/posts?tag=go-lang&limit=1": "second",
+	} {
+		if got := titles(path); got != want {
+			t.Errorf("%s lists %q, want %q", path, got, want)
+		}
+	}
+	ts.expectError(ts.do("GET", api+"posts?tag=web&match=some", "", nil), http.StatusBadRequest, "invalid_query")
+
+	// an alias takes over the posts of the tag it replaces
+	ts.expectError(ts.do("PUT", api+"admin/tags/aliases/golang", "bob", gin.H{"tag": "go"}), http.StatusForbidden, "forbidden")
+	ts.expect(ts.do("PUT", api+"admin/tags/aliases/go-lang", "alice", gin.H{"tag": "Go"}), http.StatusOK, nil)
+	ts.expect(ts.do("PUT", api+"admin/tags/aliases/golang", "alice", gin.H{"tag": "go-lang"}), http.StatusOK, nil)
+	ts.expectError(ts.do("PUT", api+"admin/tags/aliases/go", "alice", gin.H{"tag": "golang"}), http.StatusBadRequest, "invalid_alias")
+	var aliases []model.TagAlias
+	ts.expect(ts.do("GET", api+"admin/tags/aliases", "alice", nil), http.StatusOK, &aliases)
+	if !reflect.DeepEqual(aliases, []model.TagAlias{{Alias: "go-lang", Tag: "go"}, {Alias: "golang", Tag: "go"}}) {
+		t.Fatalf("aliases = %+v", aliases)
+	}
+	if got := titles("posts?tag=golang"); got != "second,first" {
+		t.Fatalf("posts tagged golang = %q", got)
+	}
+	ts.expect(ts.do("GET", api+"posts/"+first.ID, "", nil), http.StatusOK, &post)
+	if !reflect.DeepEqual(post.Tags, []string{"go", "web"}) {
+		t.Fatalf("tags after aliasing = %v", post.Tags)
+	}
+	ts.expect(ts.do("DELETE", api+"admin/tags/aliases/golang", "alice", nil), http.StatusOK, nil)
+	ts.expectError(ts.do("DELETE", api+"admin/tags/aliases/golang", "alice", nil), http.StatusNotFound, "alias_not_found")
+
+	rec := ts.do("GET", "/blog/tags/go", "", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `content="2 posts tagged go"`) || !strings.Contains(rec.Body.String(), "https://blog.example/blog/tags/go") {
+		t.Fatalf("tag page = %d %s", rec.Code, rec.Body.String())
+	}
+	rec = ts.do("GET", "/blog/tags/Go-Lang", "", nil)
+	if rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "https://blog.example/blog/tags/go" {
+		t.Fatalf("alias page = %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := ts.do("GET", "/blog/tags/unused", "", nil); rec.Code != http.StatusNotFound {
+		t.Fatalf("unused tag page = %d, want 404", rec.Code)
+	}
+}
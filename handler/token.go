@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/policy"
+	"github.com/rs/xid"
+)
+
+func (s *Server) GetTokens(c *gin.Context) {
+	subject, ok := s.authorize(c, policy.ManageTokens, policy.Resource{})
+	if !ok {
+		return
+	}
+	tokens, err := s.store.GetTokens(subject.UserID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+type tokenForm struct {
+	Name   string   `json:"name" form:"name" binding:"required,max=64"`
+	Scopes []string `json:"scopes" form:"scopes" binding:"required,min=1"`
+	// ExpiresIn is a duration such as 720h; empty tokens never expire.
+	ExpiresIn string `json:"expiresIn" form:"expiresIn"`
+}
+
+type createdToken struct {
+	model.Token
+	// Secret is the token itself, shown only once.
+	Secret string `json:"token"`
+}
+
+func (s *Server) PostToken(c *gin.Context) {
+	subject, ok := s.authorize(c, policy.ManageTokens, policy.Resource{})
+	if !ok {
+		return
+	}
+	var body tokenForm
+	err := c.ShouldBindJSON(&body)
+	if err != nil {
+		renderError(c, bindError(err))
+		return
+	}
+	for _, scope := range body.Scopes {
+		if !policy.ValidScope(scope) {
+			renderError(c, model.Validation("invalid_scope", "unknown scope "+scope, map[string]string{"scopes": "must be posts:write, comments:write or projects:admin"}))
+			return
+		}
+	}
+	now := time.Now()
+	token := model.Token{ID: xid.New().String(), UserID: subject.UserID, Name: body.Name, Scopes: body.Scopes, CreatedAt: now.Format("2006-01-02 15:04:05")}
+	if body.ExpiresIn != "" {
+		d, err := time.ParseDuration(body.ExpiresIn)
+		if err != nil || d <= 0 {
+			renderError(c, model.Validation("invalid_expiry", "expiresIn must be a positive duration such as 720h", map[string]string{"expiresIn": "must be a positive duration"}))
+			return
+		}
+		token.ExpiresAt = now.Add(d).Format("2006-01-02 15:04:05")
+	}
+	secret, hash, err := auth.NewToken()
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	token.Hash = hash
+	err = s.store.InsertToken(&token)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	s.audit(c, "token.create", "token", token.ID, nil, token)
+	c.JSON(http.StatusOK, createdToken{Token: token, Secret: secret})
+}
+
+func (s *Server) DeleteToken(c *gin.Context) {
+	subject, ok := s.authorize(c, policy.ManageTokens, policy.Resource{})
+	if !ok {
+		return
+	}
+	tokens, err := s.store.GetTokens(subject.UserID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	var before *model.Token
+	for i := range tokens {
+		if tokens[i].ID == c.Param("tokenID") {
+			before = &tokens[i]
+		}
+	}
+	if before == nil {
+		renderError(c, model.NotFound("token_not_found", "token not found"))
+		return
+	}
+	err = s.store.RevokeToken(subject.UserID, before.ID)
+	if err != nil {
+		renderError(c, err)
+		return
+	}
+	after := *before
+	after.RevokedAt = time.Now().Format("2006-01-02 15:04:05")
+	s.audit(c, "token.revoke", "token", before.ID, before, after)
+	c.JSON(http.StatusOK, gin.H{})
+}
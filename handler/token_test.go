@@ -0,0 +1,87 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/auth"
+	"github.com/n-inja/go-blog/model"
+)
+
+// doToken sends a JSON request authenticated with a bearer token.
+func (ts *testServer) doToken(method, path, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	ts.server.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestTokens(t *testing.T) {
+	ts := newTestServer(t)
+	project := ts.createProject("alice", "diary")
+	post := ts.createPost("alice", project.ID, "first")
+
+	ts.expectError(ts.do("POST", api+"tokens", "", gin.H{"name": "ci", "scopes": []string{"posts:write"}}), http.StatusUnauthorized, "unauthorized")
+	ts.expectError(ts.do("POST", api+"tokens", "alice", gin.H{"name": "ci"}), http.StatusBadRequest, "invalid_body")
+	ts.expectError(ts.do("POST", api+"tokens", "alice", gin.H{"name": "ci", "scopes": []string{"everything"}}), http.StatusBadRequest, "invalid_scope")
+	ts.expectError(ts.do("POST", api+"tokens", "alice", gin.H{"name": "ci", "scopes": []string{"posts:write"}, "expiresIn": "soon"}), http.StatusBadRequest, "invalid_expiry")
+
+	var created struct {
+		model.Token
+		Secret string `json:"token"`
+	}
+	ts.expect(ts.do("POST", api+"tokens", "alice", gin.H{"name": "ci", "scopes": []string{"posts:write"}, "expiresIn": "24h"}), http.StatusOK, &created)
+	if !strings.HasPrefix(created.Secret, auth.TokenPrefix) || created.ExpiresAt == "" || created.UserID != "alice" {
+		t.Fatalf("token = %+v", created)
+	}
+
+	ts.expect(ts.doToken("POST", api+"projects/"+project.ID+"/posts", created.Secret, `{"title":"from ci","content":"built"}`), http.StatusOK, nil)
+	ts.expectError(ts.doToken("POST", api+"posts/"+post.ID+"/comments", created.Secret, `{"content":"hi"}`), http.StatusForbidden, "insufficient_scope")
+	ts.expectError(ts.doToken("PUT", api+"profile", created.Secret, `{"newDescription":"hi"}`), http.StatusForbidden, "insufficient_scope")
+	ts.expectError(ts.doToken("POST", api+"tokens", created.Secret, `{"name":"more","scopes":["projects:admin"]}`), http.StatusForbidden, "insufficient_scope")
+	ts.expectError(ts.doToken("POST", api+"projects/"+project.ID+"/posts", auth.TokenPrefix+"forged", `{"title":"t","content":"c"}`), http.StatusUnauthorized, "invalid_token")
+
+	var tokens []model.Token
+	ts.expect(ts.do("GET", api+"tokens", "alice", nil), http.StatusOK, &tokens)
+	if len(tokens) != 1 || tokens[0].ID != created.ID || tokens[0].LastUsedAt == "" {
+		t.Fatalf("tokens = %+v", tokens)
+	}
+	if strings.Contains(ts.do("GET", api+"tokens", "alice", nil).Body.String(), created.Secret) {
+		t.Fatal("listing tokens reveals the secret")
+	}
+	ts.expect(ts.do("GET", api+"tokens", "bob", nil), http.StatusOK, &tokens)
+	if len(tokens) != 0 {
+		t.Fatalf("bob sees tokens %+v", tokens)
+	}
+
+	ts.expectError(ts.do("DELETE", api+"tokens/"+created.ID, "bob", nil), http.StatusNotFound, "token_not_found")
+	ts.expect(ts.do("DELETE", api+"tokens/"+created.ID, "alice", nil), http.StatusOK, nil)
+	ts.expectError(ts.doToken("POST", api+"projects/"+project.ID+"/posts", created.Secret, `{"title":"t","content":"c"}`), http.StatusUnauthorized, "invalid_token")
+	ts.expect(ts.do("GET", api+"tokens", "alice", nil), http.StatusOK, &tokens)
+	if len(tokens) != 0 {
+		t.Fatalf("revoked tokens are listed: %+v", tokens)
+	}
+	events, err := ts.store.GetAuditEvents(model.AuditFilter{Action: "token.revoke"}, model.Page{Limit: 1})
+	if err != nil || len(events) != 1 || !strings.Contains(string(events[0].Before), `"name":"ci"`) || !strings.Contains(string(events[0].After), `"revokedAt":"`) {
+		t.Fatalf("revoke events = %+v, %v", events, err)
+	}
+}
+
+func TestExpiredToken(t *testing.T) {
+	ts := newTestServer(t)
+	secret, hash, err := auth.NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ts.store.InsertToken(&model.Token{ID: "old", UserID: "alice", Name: "old", Scopes: []string{"projects:admin"}, Hash: hash, ExpiresAt: time.Now().Add(-time.Minute).Format("2006-01-02 15:04:05")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts.expectError(ts.doToken("POST", api+"projects", secret, `{"name":"diary"}`), http.StatusUnauthorized, "invalid_token")
+}
@@ -1,26 +1,28 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/policy"
 )
 
-func GetAllUsers(c *gin.Context) {
-	users, err := model.GetUsers()
+func (s *Server) GetAllUsers(c *gin.Context) {
+	users, err := s.storeFor(c).GetUsers()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, users)
 }
 
-func GetUser(c *gin.Context) {
+func (s *Server) GetUser(c *gin.Context) {
 	userID := c.Param("userID")
-	user, err := model.GetUser(userID)
+	user, err := s.storeFor(c).GetUser(userID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{})
+		renderError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, user)
@@ -33,19 +35,27 @@ type updateProfileForm struct {
 	NewIconSrc     string `json:"newIconSrc" form:"newIconSrc"`
 }
 
-func UpdateProfile(c *gin.Context) {
-	ID := c.GetHeader("id")
-	user, err := model.GetUser(ID)
+func (s *Server) UpdateProfile(c *gin.Context) {
+	subject, ok := s.authorize(c, policy.UpdateProfile, policy.Resource{})
+	if !ok {
+		return
+	}
+	user, err := s.storeFor(c).GetUser(subject.UserID)
+	if errors.Is(err, model.ErrNotFound) {
+		renderError(c, model.Forbidden("no_profile", "only authors have a profile"))
+		return
+	}
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{})
+		renderError(c, err)
 		return
 	}
 	var body updateProfileForm
-	err = c.BindJSON(&body)
+	err = c.ShouldBindJSON(&body)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{})
+		renderError(c, bindError(err))
 		return
 	}
+	before := user
 	if body.NewDescription != "" {
 		user.Description = body.NewDescription
 	}
@@ -58,10 +68,11 @@ func UpdateProfile(c *gin.Context) {
 	if body.NewIconSrc != "" {
 		user.IconSrc = body.NewIconSrc
 	}
-	err = user.Update()
+	err = s.store.UpdateUser(&user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{})
+		renderError(c, err)
 		return
 	}
+	s.audit(c, "profile.update", "user", user.ID, before, user)
 	c.JSON(http.StatusOK, user)
 }
@@ -1,55 +1,156 @@
 package main
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
 
+	"github.com/n-inja/go-blog/config"
 	"github.com/n-inja/go-blog/handler"
-
-	"github.com/gin-gonic/gin"
+	"github.com/n-inja/go-blog/migration"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/publish"
+	"github.com/n-inja/go-blog/purge"
+	"github.com/n-inja/go-blog/utils"
 )
 
-func main() {
-	r := gin.Default()
+// newMigrator migrates db with the tables this configuration needs.
+func newMigrator(c config.Config, db *sql.DB) *migration.Migrator {
+	migrator := migration.New(db)
+	migrator.CreateUsers = c.Auth.LocalAccounts
+	return migrator
+}
 
-	handler.LoadTMPL(r)
+// migrateStore applies pending migrations before the server opens the
+// store; only a MySQL store has a schema.
+func migrateStore(c config.Config) error {
+	if c.Store != "mysql" {
+		return nil
+	}
+	db, err := utils.OpenDB(c.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return newMigrator(c, db).Up()
+}
 
-	r.GET("blog/", handler.SetTop)
+// openStore opens the configured store as it is, without migrating it.
+func openStore(c config.Config) (model.Store, error) {
+	switch c.Store {
+	case "mysql":
+		db, err := utils.OpenDB(c.Database)
+		if err != nil {
+			return nil, err
+		}
+		replicas, err := utils.OpenReplicas(c.Database)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		return model.NewMySQLStore(db, replicas...), nil
+	case "memory":
+		return model.NewMemoryStore(), nil
+	default:
+		return nil, errors.New("unknown store: " + c.Store)
+	}
+}
 
-	r.GET("blog/users", handler.SetUsers)
-	r.GET("blog/projects", handler.SetProjects)
-	r.GET("blog/mypage", handler.SetMyPage)
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			runMigrate(os.Args[2:])
+			return
+		case "purge":
+			runPurge(os.Args[2:])
+			return
+		}
+	}
 
-	r.GET("blog/users/:userID", handler.SetUser)
-	r.GET("blog/projects/:projectName", handler.SetProject)
+	c, _, err := config.Load(os.Args[0], os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = c.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	r.GET("blog/projects/:projectName/:postID/:number", handler.SetPost)
-	r.GET("blog/projects/:projectName/:postID", handler.SetPost)
+	err = migrateStore(c)
+	if err == nil {
+		err = serve(c)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
 
-	r.GET("blog/static/:directory/:filename", handler.ServeStatic)
+// serve runs the server and its workers until a signal asks it to stop or
+// it fails to serve. It returns only after closing everything it opened.
+func serve(c config.Config) error {
+	store, err := openStore(c)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
 
-	r.GET("go-blog/api/v1/users", handler.GetAllUsers)
-	r.GET("go-blog/api/v1/users/:userID", handler.GetUser)
-	r.PUT("go-blog/api/v1/profile", handler.UpdateProfile)
+	templates, err := template.ParseGlob(filepath.Join(c.Site.StaticPath, "*.tmpl"))
+	if err != nil {
+		return err
+	}
+	server, err := handler.NewServer(c, store, templates, os.DirFS(c.Site.StaticPath))
+	if err != nil {
+		return err
+	}
 
-	r.GET("go-blog/api/v1/projects", handler.GetProjects)
-	r.GET("go-blog/api/v1/projects/:projectID", handler.GetProject)
-	r.POST("go-blog/api/v1/projects", handler.PostProject)
-	r.DELETE("go-blog/api/v1/projects/:projectID", handler.DeleteProject)
-	r.PUT("go-blog/api/v1/projects/:projectID", handler.UpdateProject)
+	ctx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	// the workers finish their run before the deferred Close of the store
+	var workers sync.WaitGroup
+	workers.Add(2)
+	go func() {
+		defer workers.Done()
+		purge.NewWorker(store, c.Purge).Run(ctx)
+	}()
+	go func() {
+		defer workers.Done()
+		publish.NewWorker(store, c.Publish).Run(ctx)
+	}()
 
-	r.GET("go-blog/api/v1/users/:userID/posts", handler.GetUserPosts)
-	r.GET("go-blog/api/v1/projects/:projectID/posts", handler.GetProjectPosts)
-	r.GET("go-blog/api/v1/posts", handler.GetPosts)
-	r.GET("go-blog/api/v1/posts/:postID", handler.GetPost)
-	r.GET("go-blog/api/v1/projects/:projectID/post", handler.GetProjectPostById)
-	r.POST("go-blog/api/v1/projects/:projectID/posts", handler.PostPost)
-	r.DELETE("go-blog/api/v1/posts/:postID", handler.DeletePost)
-	r.PUT("go-blog/api/v1/posts/:postID", handler.UpdatePost)
+	srv := &http.Server{Addr: ":" + c.Port, Handler: server}
+	// a failure to serve comes back here, so the cleanup below still runs
+	serveErr := make(chan error, 1)
+	go func() {
+		err := srv.ListenAndServe()
+		if err != nil && err != http.ErrServerClosed {
+			serveErr <- err
+		}
+	}()
 
-	r.GET("go-blog/api/v1/posts/:postID/comments", handler.GetPostComments)
-	r.GET("go-blog/api/v1/comments/:commentID", handler.GetComment)
-	r.POST("go-blog/api/v1/posts/:postID/comments", handler.PostComment)
-	r.DELETE("go-blog/api/v1/comments/:commentID", handler.DeleteComment)
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-quit:
+		log.Println("shutting down, draining requests for up to", c.ShutdownTimeout)
+	case err = <-serveErr:
+	}
 
-	r.Run(":" + os.Getenv("GO_BLOG_PORT"))
+	stopWorkers()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+	shutdownErr := srv.Shutdown(shutdownCtx)
+	if shutdownErr != nil {
+		log.Println("shutdown:", shutdownErr)
+	}
+	workers.Wait()
+	return err
 }
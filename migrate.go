@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/migration"
+	"github.com/n-inja/go-blog/utils"
+)
+
+const migrateUsage = "usage: go-blog migrate [flags] [up | down [steps] | status]"
+
+func runMigrate(args []string) {
+	c, args, err := config.Load("migrate", args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = c.Database.Validate()
+	if err != nil {
+		log.Fatal(err)
+	}
+	db, err := utils.OpenDB(c.Database)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+	migrator := newMigrator(c, db)
+
+	command := "up"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	switch command {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				log.Fatal(migrateUsage)
+			}
+		}
+		err = migrator.Down(steps)
+	case "status":
+		var statuses []migration.Status
+		statuses, err = migrator.Status()
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + status.AppliedAt
+			}
+			fmt.Fprintf(os.Stdout, "%4d %-32s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		log.Fatal(migrateUsage)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,216 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const lockName = "go-blog-migration"
+
+// lockTimeout is how many seconds to wait for another instance to finish migrating.
+const lockTimeout = 60
+
+type Migration struct {
+	Version int
+	Name    string
+	Up      []string
+	Down    []string
+	// LocalUsers marks a migration of the tables an external user service
+	// owns otherwise; it only runs with Migrator.CreateUsers.
+	LocalUsers bool
+}
+
+type Status struct {
+	Version   int    `json:"version"`
+	Name      string `json:"name"`
+	Applied   bool   `json:"applied"`
+	AppliedAt string `json:"appliedAt"`
+}
+
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+	// CreateUsers runs the LocalUsers migrations, which create the users
+	// table, for installs that keep local accounts instead of using an
+	// external user service.
+	CreateUsers bool
+}
+
+func New(db *sql.DB) *Migrator {
+	sorted := append(make([]Migration, 0), migrations...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Version < sorted[j].Version
+	})
+	return &Migrator{db: db, migrations: sorted}
+}
+
+// list returns the migrations that apply to this install, in version order.
+func (m *Migrator) list() []Migration {
+	list := make([]Migration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		if migration.LocalUsers && !m.CreateUsers {
+			continue
+		}
+		list = append(list, migration)
+	}
+	return list
+}
+
+// Up applies every pending migration in version order.
+func (m *Migrator) Up() error {
+	return m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		for _, migration := range m.list() {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err = run(conn, migration.Up)
+			if err != nil {
+				return fmt.Errorf("migration %d %s: %v", migration.Version, migration.Name, err)
+			}
+			_, err = conn.ExecContext(context.Background(), "insert into schema_migrations (version, name) value(?, ?)", migration.Version, migration.Name)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Down rolls back the last steps applied migrations, newest first.
+func (m *Migrator) Down(steps int) error {
+	return m.withLock(func(conn *sql.Conn) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		list := m.list()
+		for i := len(list) - 1; i >= 0 && steps > 0; i-- {
+			migration := list[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			err = run(conn, migration.Down)
+			if err != nil {
+				return fmt.Errorf("rollback %d %s: %v", migration.Version, migration.Name, err)
+			}
+			_, err = conn.ExecContext(context.Background(), "delete from schema_migrations where version = ?", migration.Version)
+			if err != nil {
+				return err
+			}
+			steps--
+		}
+		return nil
+	})
+}
+
+// Status reports which migrations are applied. It only reads
+// schema_migrations, without the lock, so it changes nothing; before the
+// first migration every one is pending.
+func (m *Migrator) Status() ([]Status, error) {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, "show tables like 'schema_migrations'")
+	if err != nil {
+		return nil, err
+	}
+	found := rows.Next()
+	rows.Close()
+	applied := map[int]string{}
+	if found {
+		applied, err = appliedVersions(conn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	list := m.list()
+	statuses := make([]Status, 0, len(list))
+	for _, migration := range list {
+		appliedAt, ok := applied[migration.Version]
+		statuses = append(statuses, Status{Version: migration.Version, Name: migration.Name, Applied: ok, AppliedAt: appliedAt})
+	}
+	return statuses, nil
+}
+
+// withLock runs f on a single connection holding a MySQL named lock, so
+// that two instances starting at the same time never migrate concurrently.
+func (m *Migrator) withLock(f func(*sql.Conn) error) error {
+	ctx := context.Background()
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var locked sql.NullInt64
+	err = conn.QueryRowContext(ctx, "select get_lock(?, ?)", lockName, lockTimeout).Scan(&locked)
+	if err != nil {
+		return err
+	}
+	if !locked.Valid || locked.Int64 != 1 {
+		return errors.New("another instance is running migrations")
+	}
+	defer conn.ExecContext(ctx, "do release_lock(?)", lockName)
+
+	err = m.prepare(conn)
+	if err != nil {
+		return err
+	}
+	return f(conn)
+}
+
+func (m *Migrator) prepare(conn *sql.Conn) error {
+	ctx := context.Background()
+	rows, err := conn.QueryContext(ctx, "show tables like 'users'")
+	if err != nil {
+		return err
+	}
+	found := rows.Next()
+	rows.Close()
+	if !found && !m.CreateUsers {
+		return errors.New("users table not found; enable local accounts to create it")
+	}
+
+	_, err = conn.ExecContext(ctx, "create table if not exists schema_migrations (version int NOT NULL PRIMARY KEY, name varchar(64) NOT NULL, applied_at timestamp NOT NULL default current_timestamp) engine=innodb")
+	return err
+}
+
+func appliedVersions(conn *sql.Conn) (map[int]string, error) {
+	rows, err := conn.QueryContext(context.Background(), "select version, applied_at from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := map[int]string{}
+	for rows.Next() {
+		var version int
+		var appliedAt string
+		err = rows.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, rows.Err()
+}
+
+func run(conn *sql.Conn, statements []string) error {
+	for _, statement := range statements {
+		_, err := conn.ExecContext(context.Background(), statement)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package migration
+
+// migrations is the ordered schema history. Never edit an entry that has
+// been released; append a new version instead.
+var migrations = []Migration{
+	{
+		// Version 0 comes before the baseline, whose foreign keys need the
+		// table; it used to be created outside the versioned list, hence
+		// "if not exists".
+		Version: 0,
+		Name:    "local users",
+		Up: []string{
+			"create table if not exists users (id varchar(32) NOT NULL PRIMARY KEY, name varchar(64) unicode NOT NULL, auth varchar(16) NOT NULL default 'default') engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists users",
+		},
+		LocalUsers: true,
+	},
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up: []string{
+			"create table if not exists projects (id varchar(20) NOT NULL PRIMARY KEY, name varchar(32) NOT NULL UNIQUE, display_name varchar(64) unicode NOT NULL, user_id varchar(32) NOT NULL, description text unicode NULL, foreign key(user_id) references users(id)) engine=innodb",
+			"create table if not exists member (user_id varchar(32) NOT NULL, project_id varchar(20) NOT NULL, PRIMARY KEY(user_id, project_id), index(user_id), index(project_id), foreign key(user_id) references users(id)) engine=innodb",
+			"create table if not exists posts (id varchar(20) NOT NULL PRIMARY KEY, title text unicode NOT NULL, content longtext unicode NOT NULL, thumb_src varchar(64) NULL, user_id varchar(32) NOT NULL, number int NOT NULL, created_at timestamp NOT NULL default current_timestamp, updated_at timestamp NOT NULL default current_timestamp on update current_timestamp, project_id varchar(20) NOT NULL, views int NOT NULL, is_deleted boolean NOT NULL, index(user_id), index(created_at), index(is_deleted), index(project_id), unique(project_id, number), foreign key(user_id) references users(id)) engine=innodb",
+			"create table if not exists comments (id varchar(20) NOT NULL PRIMARY KEY, content text unicode NOT NULL, user_id varchar(32) NOT NULL, post_id varchar(20) NOT NULL, created_at timestamp NOT NULL, is_deleted boolean NOT NULL, index(user_id), index(created_at), index(is_deleted), index(post_id), foreign key(post_id) references posts(id), foreign key(user_id) references users(id)) engine=innodb",
+			"create table if not exists profiles (id varchar(32) NOT NULL PRIMARY KEY, description text unicode NULL, twitter_id varchar(32) NULL, github_id varchar(64) NULL, icon_src varchar(64) NULL, foreign key(id) references users(id)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists profiles",
+			"drop table if exists comments",
+			"drop table if exists posts",
+			"drop table if exists member",
+			"drop table if exists projects",
+		},
+	},
+	{
+		Version: 2,
+		Name:    "soft delete timestamps",
+		Up: []string{
+			"alter table posts add column deleted_at timestamp NULL, add index(deleted_at)",
+			"alter table comments add column deleted_at timestamp NULL, add index(deleted_at)",
+			"update posts set deleted_at = current_timestamp where is_deleted = true",
+			"update comments set deleted_at = current_timestamp where is_deleted = true",
+		},
+		Down: []string{
+			"alter table comments drop index deleted_at, drop column deleted_at",
+			"alter table posts drop index deleted_at, drop column deleted_at",
+		},
+	},
+	{
+		Version: 3,
+		Name:    "user roles",
+		Up: []string{
+			"alter table profiles add column role varchar(16) NULL",
+		},
+		Down: []string{
+			"alter table profiles drop column role",
+		},
+	},
+	{
+		Version: 4,
+		Name:    "project member roles",
+		Up: []string{
+			"alter table member add column role varchar(16) NOT NULL default 'writer'",
+			"update member join projects on projects.id = member.project_id and projects.user_id = member.user_id set member.role = 'owner'",
+		},
+		Down: []string{
+			"alter table member drop column role",
+		},
+	},
+	{
+		Version: 5,
+		Name:    "api tokens",
+		Up: []string{
+			"create table api_tokens (id varchar(20) NOT NULL PRIMARY KEY, user_id varchar(32) NOT NULL, name varchar(64) unicode NOT NULL, token_hash char(64) NOT NULL UNIQUE, scopes varchar(255) NOT NULL, created_at timestamp NOT NULL default current_timestamp, expires_at timestamp NULL, last_used_at timestamp NULL, revoked_at timestamp NULL, index(user_id), foreign key(user_id) references users(id)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists api_tokens",
+		},
+	},
+	{
+		Version: 6,
+		Name:    "local credentials",
+		Up: []string{
+			"create table credentials (user_id varchar(32) NOT NULL PRIMARY KEY, password_hash varchar(255) NOT NULL, created_at timestamp NOT NULL default current_timestamp, updated_at timestamp NOT NULL default current_timestamp on update current_timestamp, foreign key(user_id) references users(id)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists credentials",
+		},
+	},
+	{
+		Version: 7,
+		Name:    "oauth identities",
+		Up: []string{
+			"create table identities (provider varchar(32) NOT NULL, subject varchar(255) NOT NULL, user_id varchar(32) NOT NULL, created_at timestamp NOT NULL default current_timestamp, PRIMARY KEY(provider, subject), unique(user_id, provider), foreign key(user_id) references users(id)) engine=innodb",
+			"alter table profiles modify icon_src varchar(255) NULL",
+		},
+		Down: []string{
+			"alter table profiles modify icon_src varchar(64) NULL",
+			"drop table if exists identities",
+		},
+	},
+	{
+		Version: 8,
+		Name:    "moderation",
+		Up: []string{
+			"alter table posts add column hidden_at timestamp NULL, add index(hidden_at)",
+			"alter table comments add column hidden_at timestamp NULL, add index(hidden_at)",
+			"create table moderation_actions (id varchar(20) NOT NULL PRIMARY KEY, actor_id varchar(32) NOT NULL, action varchar(32) NOT NULL, target_type varchar(16) NOT NULL, target_id varchar(32) NOT NULL, reason varchar(255) unicode NOT NULL, detail varchar(64) NOT NULL default '', created_at timestamp NOT NULL default current_timestamp, index(target_type, target_id), index(created_at), foreign key(actor_id) references users(id)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists moderation_actions",
+			"alter table comments drop index hidden_at, drop column hidden_at",
+			"alter table posts drop index hidden_at, drop column hidden_at",
+		},
+	},
+	{
+		Version: 9,
+		Name:    "audit log",
+		Up: []string{
+			"create table audit_events (id varchar(20) NOT NULL PRIMARY KEY, actor_id varchar(32) NOT NULL, action varchar(32) NOT NULL, resource_type varchar(16) NOT NULL, resource_id varchar(32) NOT NULL, before_json longtext unicode NOT NULL, after_json longtext unicode NOT NULL, request_id varchar(64) NOT NULL, ip varchar(45) NOT NULL, created_at timestamp NOT NULL default current_timestamp, index(actor_id), index(resource_type, resource_id), index(action), index(created_at)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists audit_events",
+		},
+	},
+	{
+		Version: 10,
+		Name:    "rate limits",
+		Up: []string{
+			// times are unix milliseconds; updated_at is 0 until the bucket is first used
+			"create table rate_limits (bucket_key varchar(128) NOT NULL PRIMARY KEY, tokens double NOT NULL default 0, updated_at bigint NOT NULL default 0, expires_at bigint NOT NULL default 0, index(expires_at)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists rate_limits",
+		},
+	},
+	{
+		Version: 11,
+		Name:    "post status",
+		Up: []string{
+			"alter table posts add column status varchar(16) NOT NULL default 'published', add column publish_at timestamp NULL, add index(status, publish_at)",
+			"update posts set publish_at = created_at, updated_at = updated_at",
+		},
+		Down: []string{
+			"alter table posts drop index status, drop column publish_at, drop column status",
+		},
+	},
+	{
+		Version: 12,
+		Name:    "post revisions",
+		Up: []string{
+			"create table post_revisions (post_id varchar(20) NOT NULL, number int NOT NULL, editor_id varchar(32) NOT NULL, title text unicode NOT NULL, content longtext unicode NOT NULL, thumb_src varchar(255) NULL, created_at timestamp NOT NULL default current_timestamp, PRIMARY KEY(post_id, number), foreign key(post_id) references posts(id)) engine=innodb",
+			// the history starts with each post as it is now
+			"insert into post_revisions (post_id, number, editor_id, title, content, thumb_src, created_at) select id, 1, user_id, title, content, thumb_src, updated_at from posts",
+		},
+		Down: []string{
+			"drop table if exists post_revisions",
+		},
+	},
+	{
+		Version: 13,
+		Name:    "rendered post content",
+		Up: []string{
+			// posts keep an empty render key until they are first read, which renders them
+			"alter table posts add column content_html longtext unicode NULL, add column render_key varchar(32) NOT NULL default ''",
+		},
+		Down: []string{
+			"alter table posts drop column render_key, drop column content_html",
+		},
+	},
+	{
+		Version: 14,
+		Name:    "post tags",
+		Up: []string{
+			"create table post_tags (post_id varchar(20) NOT NULL, tag varchar(64) unicode NOT NULL, PRIMARY KEY(post_id, tag), index(tag), foreign key(post_id) references posts(id)) engine=innodb",
+			"create table tag_aliases (alias varchar(64) unicode NOT NULL PRIMARY KEY, tag varchar(64) unicode NOT NULL, index(tag)) engine=innodb",
+		},
+		Down: []string{
+			"drop table if exists tag_aliases",
+			"drop table if exists post_tags",
+		},
+	},
+	{
+		Version: 15,
+		Name:    "search",
+		Up: []string{
+			// unicode is ucs2, which full-text indexes do not support
+			"alter table posts modify title text character set utf8mb4 NOT NULL, modify content longtext character set utf8mb4 NOT NULL",
+			"alter table comments modify content text character set utf8mb4 NOT NULL",
+			// the ngram parser indexes bigrams, so Japanese is searchable without word breaks
+			"alter table posts add fulltext index posts_search (title, content) with parser ngram",
+			"alter table comments add fulltext index comments_search (content) with parser ngram",
+		},
+		Down: []string{
+			"alter table comments drop index comments_search",
+			"alter table posts drop index posts_search",
+			"alter table comments modify content text unicode NOT NULL",
+			"alter table posts modify title text unicode NOT NULL, modify content longtext unicode NOT NULL",
+		},
+	},
+	{
+		Version: 16,
+		Name:    "audit resource ids",
+		Up: []string{
+			// tag aliases are audited by name, up to 64 characters of any script
+			"alter table audit_events modify resource_id varchar(64) character set utf8mb4 NOT NULL",
+		},
+		Down: []string{
+			"alter table audit_events modify resource_id varchar(32) NOT NULL",
+		},
+	},
+	{
+		Version: 17,
+		Name:    "binary tag collation",
+		Up: []string{
+			// tags are normalized before they are stored, so they compare
+			// byte for byte; a general collation would take café for cafe
+			"alter table post_tags modify tag varchar(64) character set utf8mb4 collate utf8mb4_bin NOT NULL",
+			"alter table tag_aliases modify alias varchar(64) character set utf8mb4 collate utf8mb4_bin NOT NULL, modify tag varchar(64) character set utf8mb4 collate utf8mb4_bin NOT NULL",
+		},
+		Down: []string{
+			"alter table tag_aliases modify alias varchar(64) unicode NOT NULL, modify tag varchar(64) unicode NOT NULL",
+			"alter table post_tags modify tag varchar(64) unicode NOT NULL",
+		},
+	},
+}
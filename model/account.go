@@ -0,0 +1,98 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/n-inja/go-blog/utils"
+)
+
+// AccountStore keeps local accounts for installs without an external user
+// service.
+type AccountStore interface {
+	// CreateAccount inserts user with a password hash and an empty profile.
+	// An empty hash creates an account that can only sign in through an
+	// OAuth provider. The first user of an install becomes an admin
+	// regardless of role.
+	CreateAccount(user *User, role Role, passwordHash string) error
+	GetPasswordHash(userID string) (string, error)
+}
+
+// authOf is the users.auth value that roleOf maps back to role.
+func authOf(role Role) string {
+	if role == RoleAuthor {
+		return "default"
+	}
+	return string(role)
+}
+
+func (s *MySQLStore) CreateAccount(user *User, role Role, passwordHash string) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		return createAccount(tx, user, role, passwordHash)
+	})
+}
+
+func createAccount(tx *sql.Tx, user *User, role Role, passwordHash string) error {
+	var count int
+	err := tx.QueryRow("select count(*) from users for update").Scan(&count)
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		role = RoleAdmin
+	}
+	user.Auth = authOf(role)
+	_, err = tx.Exec("insert into users (id, name, auth) value(?, ?, ?)", user.ID, user.Name, user.Auth)
+	if err != nil {
+		return conflict(err, "user_exists", "user ID is already taken")
+	}
+	if passwordHash != "" {
+		_, err = tx.Exec("insert into credentials (user_id, password_hash) value(?, ?)", user.ID, passwordHash)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec("insert into profiles (id) value(?)", user.ID)
+	return err
+}
+
+func (s *MySQLStore) GetPasswordHash(userID string) (string, error) {
+	var hash string
+	err := s.db.QueryRow("select password_hash from credentials where user_id = ?", userID).Scan(&hash)
+	if err != nil {
+		return "", notFound(err, "user_not_found", "user not found")
+	}
+	return hash, nil
+}
+
+func (s *MemoryStore) CreateAccount(user *User, role Role, passwordHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.createAccount(user, role, passwordHash)
+}
+
+func (s *MemoryStore) createAccount(user *User, role Role, passwordHash string) error {
+	if _, ok := s.users[user.ID]; ok {
+		return Conflict("user_exists", "user ID is already taken")
+	}
+	if len(s.users) == 0 {
+		role = RoleAdmin
+	}
+	user.Auth = authOf(role)
+	u := *user
+	u.ProjectIDs = nil
+	s.users[u.ID] = &u
+	if passwordHash != "" {
+		s.passwords[u.ID] = passwordHash
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetPasswordHash(userID string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	hash, ok := s.passwords[userID]
+	if !ok {
+		return "", NotFound("user_not_found", "user not found")
+	}
+	return hash, nil
+}
@@ -0,0 +1,119 @@
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+)
+
+// AuditEvent records one change made through the API. Before and After are
+// JSON snapshots of the resource, null when it did not exist.
+type AuditEvent struct {
+	ID           string          `json:"id"`
+	ActorID      string          `json:"actorId"`
+	Action       string          `json:"action"`
+	ResourceType string          `json:"resourceType"`
+	ResourceID   string          `json:"resourceId"`
+	Before       json.RawMessage `json:"before"`
+	After        json.RawMessage `json:"after"`
+	RequestID    string          `json:"requestId"`
+	IP           string          `json:"ip"`
+	CreatedAt    string          `json:"createdAt"`
+}
+
+// AuditFilter narrows the audit log. Empty fields match everything; Since
+// and Until bound CreatedAt, inclusive.
+type AuditFilter struct {
+	ActorID      string
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Since        string
+	Until        string
+}
+
+// AuditStore keeps the audit log. Events are never changed or deleted.
+type AuditStore interface {
+	InsertAuditEvent(event *AuditEvent) error
+	GetAuditEvents(filter AuditFilter, page Page) ([]AuditEvent, error)
+}
+
+func (filter AuditFilter) where() (string, []interface{}) {
+	conditions := []string{"true"}
+	var args []interface{}
+	for _, c := range []struct {
+		condition, value string
+	}{
+		{"actor_id = ?", filter.ActorID},
+		{"action = ?", filter.Action},
+		{"resource_type = ?", filter.ResourceType},
+		{"resource_id = ?", filter.ResourceID},
+		{"created_at >= ?", filter.Since},
+		{"created_at <= ?", filter.Until},
+	} {
+		if c.value != "" {
+			conditions = append(conditions, c.condition)
+			args = append(args, c.value)
+		}
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func (filter AuditFilter) matches(event AuditEvent) bool {
+	return (filter.ActorID == "" || filter.ActorID == event.ActorID) &&
+		(filter.Action == "" || filter.Action == event.Action) &&
+		(filter.ResourceType == "" || filter.ResourceType == event.ResourceType) &&
+		(filter.ResourceID == "" || filter.ResourceID == event.ResourceID) &&
+		(filter.Since == "" || event.CreatedAt >= filter.Since) &&
+		(filter.Until == "" || event.CreatedAt <= filter.Until)
+}
+
+func (s *MySQLStore) InsertAuditEvent(event *AuditEvent) error {
+	_, err := s.db.Exec("insert into audit_events (id, actor_id, action, resource_type, resource_id, before_json, after_json, request_id, ip, created_at) value(?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", event.ID, event.ActorID, event.Action, event.ResourceType, event.ResourceID, string(event.Before), string(event.After), event.RequestID, event.IP, event.CreatedAt)
+	return err
+}
+
+func (s *MySQLStore) GetAuditEvents(filter AuditFilter, page Page) ([]AuditEvent, error) {
+	where, args := filter.where()
+	clause, pageArgs := page.clause()
+	rows, err := s.db.Query("select id, actor_id, action, resource_type, resource_id, before_json, after_json, request_id, ip, created_at from audit_events where "+where+clause, append(args, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	events := make([]AuditEvent, 0)
+	for rows.Next() {
+		var event AuditEvent
+		var before, after string
+		err = rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.ResourceType, &event.ResourceID, &before, &after, &event.RequestID, &event.IP, &event.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		event.Before = json.RawMessage(before)
+		event.After = json.RawMessage(after)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+func (s *MemoryStore) InsertAuditEvent(event *AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.auditEvents = append(s.auditEvents, *event)
+	return nil
+}
+
+func (s *MemoryStore) GetAuditEvents(filter AuditFilter, page Page) ([]AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := make([]AuditEvent, 0)
+	for _, event := range s.auditEvents {
+		if filter.matches(event) && page.includes(event.CreatedAt, event.ID) {
+			events = append(events, event)
+		}
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return newestFirst(events[i].CreatedAt, events[i].ID, events[j].CreatedAt, events[j].ID)
+	})
+	return cut(events, page), nil
+}
@@ -1,23 +1,17 @@
 package model
 
-import (
-	"errors"
-
-	"github.com/n-inja/go-blog/utils"
-)
-
-func (comment *Comment) Insert() error {
-	_, err := utils.DB.Exec("insert into comments (id, content, user_id, post_id, created_at, is_deleted) value(?, ?, ?, ?, ?, ?)", comment.ID, comment.Content, comment.UserID, comment.PostID, comment.CreatedAt, false)
-	return err
+func (s *MySQLStore) InsertComment(comment *Comment) error {
+	_, err := s.db.Exec("insert into comments (id, content, user_id, post_id, created_at, is_deleted) value(?, ?, ?, ?, ?, ?)", comment.ID, comment.Content, comment.UserID, comment.PostID, comment.CreatedAt, false)
+	return conflict(err, "comment_exists", "comment already exists")
 }
 
-func (comment *Comment) Delete() error {
-	_, err := utils.DB.Exec("update comments set is_deleted = true where id = ?", comment.ID)
+func (s *MySQLStore) DeleteComment(comment *Comment) error {
+	_, err := s.db.Exec("update comments set is_deleted = true, deleted_at = current_timestamp where id = ?", comment.ID)
 	return err
 }
 
-func (comment *Comment) Update() error {
-	_, err := utils.DB.Exec("update comments set content = ? where id = ?", comment.Content, comment.ID)
+func (s *MySQLStore) UpdateComment(comment *Comment) error {
+	_, err := s.db.Exec("update comments set content = ? where id = ?", comment.Content, comment.ID)
 	return err
 }
 
@@ -29,8 +23,9 @@ type Comment struct {
 	CreatedAt string `json:"createdAt" form:"createdAt"`
 }
 
-func GetPostComments(postID string, offset, limit int) ([]Comment, error) {
-	rows, err := utils.DB.Query("select id, content, user_id, post_id, created_at from comments where post_id = ? and is_deleted = false order by created_at desc limit ?, ?", postID, offset, limit)
+func (s *MySQLStore) GetPostComments(postID string, page Page) ([]Comment, error) {
+	clause, args := page.clause()
+	rows, err := s.reader().Query("select id, content, user_id, post_id, created_at from comments where post_id = ? and is_deleted = false and hidden_at is null"+clause, append([]interface{}{postID}, args...)...)
 	if err != nil {
 		return make([]Comment, 0), err
 	}
@@ -43,13 +38,13 @@ func GetPostComments(postID string, offset, limit int) ([]Comment, error) {
 	return comments, nil
 }
 
-func GetComment(commentID string) (Comment, error) {
-	rows, err := utils.DB.Query("select id, content, user_id, post_id, created_at from comments where id = ? and is_deleted = false", commentID)
+func (s *MySQLStore) GetComment(commentID string) (Comment, error) {
+	rows, err := s.reader().Query("select id, content, user_id, post_id, created_at from comments where id = ? and is_deleted = false and hidden_at is null", commentID)
 	if err != nil {
 		return Comment{}, err
 	}
 	if !rows.Next() {
-		return Comment{}, errors.New("comment not found")
+		return Comment{}, NotFound("comment_not_found", "comment not found")
 	}
 	var comment Comment
 	rows.Scan(&comment.ID, &comment.Content, &comment.UserID, &comment.PostID, &comment.CreatedAt)
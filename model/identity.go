@@ -0,0 +1,118 @@
+package model
+
+import (
+	"database/sql"
+
+	"github.com/n-inja/go-blog/utils"
+)
+
+// IdentityStore links users to accounts at OAuth providers.
+type IdentityStore interface {
+	// GetIdentityUser returns the user linked to subject at provider.
+	GetIdentityUser(provider, subject string) (string, error)
+	// LinkIdentity links subject at provider to userID. A user has at most
+	// one account per provider.
+	LinkIdentity(provider, subject, userID string) error
+	// CreateIdentityAccount creates an account for user, as CreateAccount
+	// does without a password, and links subject at provider to it. Neither
+	// happens without the other.
+	CreateIdentityAccount(user *User, role Role, provider, subject string) error
+	// SyncProfile records githubID, when given, and sets the icon of a
+	// profile that has none.
+	SyncProfile(userID, githubID, iconSrc string) error
+}
+
+func (s *MySQLStore) GetIdentityUser(provider, subject string) (string, error) {
+	var userID string
+	err := s.db.QueryRow("select user_id from identities where provider = ? and subject = ?", provider, subject).Scan(&userID)
+	if err != nil {
+		return "", notFound(err, "identity_not_found", "identity is not linked")
+	}
+	return userID, nil
+}
+
+func (s *MySQLStore) LinkIdentity(provider, subject, userID string) error {
+	_, err := s.db.Exec("insert into identities (provider, subject, user_id) value(?, ?, ?)", provider, subject, userID)
+	return conflict(err, "identity_linked", "the account is already linked to a user")
+}
+
+func (s *MySQLStore) CreateIdentityAccount(user *User, role Role, provider, subject string) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		err := createAccount(tx, user, role, "")
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("insert into identities (provider, subject, user_id) value(?, ?, ?)", provider, subject, user.ID)
+		return conflict(err, "identity_linked", "the account is already linked to a user")
+	})
+}
+
+func (s *MySQLStore) SyncProfile(userID, githubID, iconSrc string) error {
+	_, err := s.db.Exec("insert into profiles (id, github_id, icon_src) value(?, nullif(?, ''), nullif(?, '')) on duplicate key update github_id = coalesce(values(github_id), github_id), icon_src = if(coalesce(icon_src, '') = '', values(icon_src), icon_src)", userID, githubID, iconSrc)
+	return err
+}
+
+type identityKey struct {
+	provider, subject string
+}
+
+func (s *MemoryStore) GetIdentityUser(provider, subject string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	userID, ok := s.identities[identityKey{provider, subject}]
+	if !ok {
+		return "", NotFound("identity_not_found", "identity is not linked")
+	}
+	return userID, nil
+}
+
+func (s *MemoryStore) LinkIdentity(provider, subject, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.linkIdentity(provider, subject, userID)
+}
+
+func (s *MemoryStore) CreateIdentityAccount(user *User, role Role, provider, subject string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.identities[identityKey{provider, subject}]; ok {
+		return Conflict("identity_linked", "the account is already linked to a user")
+	}
+	err := s.createAccount(user, role, "")
+	if err != nil {
+		return err
+	}
+	return s.linkIdentity(provider, subject, user.ID)
+}
+
+func (s *MemoryStore) linkIdentity(provider, subject, userID string) error {
+	if _, ok := s.users[userID]; !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	if _, ok := s.identities[identityKey{provider, subject}]; ok {
+		return Conflict("identity_linked", "the account is already linked to a user")
+	}
+	for key, linked := range s.identities {
+		if key.provider == provider && linked == userID {
+			return Conflict("identity_linked", "the user is already linked to another account")
+		}
+	}
+	s.identities[identityKey{provider, subject}] = userID
+	return nil
+}
+
+func (s *MemoryStore) SyncProfile(userID, githubID, iconSrc string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	if githubID != "" {
+		u.GithubId = githubID
+	}
+	if u.IconSrc == "" {
+		u.IconSrc = iconSrc
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"sort"
+)
+
+// MemberRole is what a member may do inside one project.
+type MemberRole string
+
+const (
+	MemberOwner  MemberRole = "owner"
+	MemberEditor MemberRole = "editor"
+	MemberWriter MemberRole = "writer"
+	MemberViewer MemberRole = "viewer"
+)
+
+var memberRanks = map[MemberRole]int{
+	MemberViewer: 1,
+	MemberWriter: 2,
+	MemberEditor: 3,
+	MemberOwner:  4,
+}
+
+func (r MemberRole) Valid() bool {
+	return memberRanks[r] > 0
+}
+
+// AtLeast reports whether r grants everything min does. Non-members have
+// the empty role, which grants nothing.
+func (r MemberRole) AtLeast(min MemberRole) bool {
+	return memberRanks[r] > 0 && memberRanks[r] >= memberRanks[min]
+}
+
+type Member struct {
+	UserID string     `json:"userId"`
+	Role   MemberRole `json:"role"`
+}
+
+// Members lists the project members, highest role first.
+func (project *Project) Members() []Member {
+	members := make([]Member, 0, len(project.Member))
+	for _, userID := range project.Member {
+		members = append(members, Member{UserID: userID, Role: project.Roles[userID]})
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Role != members[j].Role {
+			return memberRanks[members[i].Role] > memberRanks[members[j].Role]
+		}
+		return members[i].UserID < members[j].UserID
+	})
+	return members
+}
+
+func validateMemberRole(role MemberRole) error {
+	if role == MemberOwner {
+		return Validation("owner_role", "transfer ownership by changing the project owner", map[string]string{"role": "must not be owner"})
+	}
+	if !role.Valid() {
+		return Validation("invalid_member_role", "role must be editor, writer or viewer", map[string]string{"role": "must be editor, writer or viewer"})
+	}
+	return nil
+}
+
+// SetMember adds userID to the project with role, or changes the role of an
+// existing member. The owner is changed through UpdateProject instead.
+func (s *MySQLStore) SetMember(projectID, userID string, role MemberRole) error {
+	err := validateMemberRole(role)
+	if err != nil {
+		return err
+	}
+	var owner string
+	err = s.db.QueryRow("select user_id from projects where id = ?", projectID).Scan(&owner)
+	if err != nil {
+		return notFound(err, "project_not_found", "project not found")
+	}
+	if owner == userID {
+		return Validation("owner_role", "transfer ownership by changing the project owner", map[string]string{"userId": "is the owner"})
+	}
+	var exists string
+	err = s.db.QueryRow("select id from users where id = ?", userID).Scan(&exists)
+	if err != nil {
+		return notFound(err, "user_not_found", "user not found")
+	}
+	_, err = s.db.Exec("insert into member (user_id, project_id, role) value(?, ?, ?) on duplicate key update role = values(role)", userID, projectID, string(role))
+	return err
+}
+
+func (s *MySQLStore) RemoveMember(projectID, userID string) error {
+	var owner string
+	err := s.db.QueryRow("select user_id from projects where id = ?", projectID).Scan(&owner)
+	if err != nil {
+		return notFound(err, "project_not_found", "project not found")
+	}
+	if owner == userID {
+		return Validation("owner_role", "the owner cannot leave the project", map[string]string{"userId": "is the owner"})
+	}
+	result, err := s.db.Exec("delete from member where project_id = ? and user_id = ?", projectID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return NotFound("member_not_found", "user is not a member")
+	}
+	return nil
+}
@@ -0,0 +1,596 @@
+package model
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/n-inja/go-blog/search"
+)
+
+type memoryPost struct {
+	Post
+	isDeleted bool
+	deletedAt time.Time
+	hidden    bool
+}
+
+type memoryComment struct {
+	Comment
+	isDeleted bool
+	deletedAt time.Time
+	hidden    bool
+}
+
+// MemoryStore keeps everything in process memory. It is meant for local
+// development and tests, and loses all data when the server stops.
+type MemoryStore struct {
+	mu    sync.RWMutex
+	users map[string]*User
+	roles map[string]Role
+	// passwords holds the hashes of local accounts
+	passwords map[string]string
+	projects  map[string]*Project
+	posts     []*memoryPost
+	comments  []*memoryComment
+	tokens    []*memoryToken
+	// identities maps provider accounts to users
+	identities map[identityKey]string
+	// moderationActions is kept in insertion order
+	moderationActions []ModerationAction
+	auditEvents       []AuditEvent
+	revisions         []Revision
+	// postTags maps posts to their sorted tags, and tagAliases aliases
+	// to the tags they stand for
+	postTags   map[string][]string
+	tagAliases map[string]string
+	// index holds posts as post:ID and comments as comment:ID
+	index *search.Index
+}
+
+func (post *memoryPost) delete() {
+	if !post.isDeleted {
+		post.isDeleted = true
+		post.deletedAt = time.Now()
+	}
+}
+
+func (comment *memoryComment) delete() {
+	if !comment.isDeleted {
+		comment.isDeleted = true
+		comment.deletedAt = time.Now()
+	}
+}
+
+// live reports whether the public may see the post.
+func (post *memoryPost) live() bool {
+	return !post.isDeleted && !post.hidden
+}
+
+func (comment *memoryComment) live() bool {
+	return !comment.isDeleted && !comment.hidden
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		users:      map[string]*User{},
+		roles:      map[string]Role{},
+		passwords:  map[string]string{},
+		projects:   map[string]*Project{},
+		posts:      make([]*memoryPost, 0),
+		comments:   make([]*memoryComment, 0),
+		identities: map[identityKey]string{},
+		postTags:   map[string][]string{},
+		tagAliases: map[string]string{},
+		index:      search.NewIndex(),
+	}
+}
+
+func (s *MemoryStore) Primary() Store {
+	return s
+}
+
+func (s *MemoryStore) Ping() error {
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// AddUser registers a user, standing in for the externally managed users table.
+func (s *MemoryStore) AddUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user.ProjectIDs = nil
+	s.users[user.ID] = &user
+}
+
+func (s *MemoryStore) GetRole(ID string) (Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[ID]
+	if !ok {
+		return "", NotFound("user_not_found", "user not found")
+	}
+	return roleOf(u.Auth, string(s.roles[ID])), nil
+}
+
+func (s *MemoryStore) SetRole(ID string, role Role) error {
+	if !role.Valid() {
+		return Validation("invalid_role", "role must be admin, author, commenter or suspended", map[string]string{"role": "must be admin, author, commenter or suspended"})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[ID]; !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	s.roles[ID] = role
+	return nil
+}
+
+func (s *MemoryStore) UpdateUser(user *User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[user.ID]
+	if !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	u.Description = user.Description
+	u.TwitterId = user.TwitterId
+	u.GithubId = user.GithubId
+	u.IconSrc = user.IconSrc
+	return nil
+}
+
+func (s *MemoryStore) GetUsers() ([]User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	users := make([]User, 0)
+	for _, u := range s.users {
+		if !hasProfile(roleOf(u.Auth, string(s.roles[u.ID]))) {
+			continue
+		}
+		users = append(users, s.user(u))
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].ID > users[j].ID
+	})
+	return users, nil
+}
+
+func (s *MemoryStore) GetUser(ID string) (User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[ID]
+	if !ok || !hasProfile(roleOf(u.Auth, string(s.roles[ID]))) {
+		return User{}, NotFound("user_not_found", "user not found")
+	}
+	return s.user(u), nil
+}
+
+func (s *MemoryStore) user(u *User) User {
+	user := *u
+	user.ProjectIDs = make([]string, 0)
+	for _, project := range s.projects {
+		for _, userID := range project.Member {
+			if userID == u.ID {
+				user.ProjectIDs = append(user.ProjectIDs, project.ID)
+			}
+		}
+	}
+	sort.Strings(user.ProjectIDs)
+	return user
+}
+
+func (s *MemoryStore) InsertProject(project *Project) error {
+	err := project.validate()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.projects[project.ID]; ok {
+		return Conflict("project_exists", "project already exists")
+	}
+	for _, p := range s.projects {
+		if p.Name == project.Name {
+			return Conflict("project_name_taken", "project name is already taken")
+		}
+	}
+	project.Roles = map[string]MemberRole{}
+	for _, userID := range project.Member {
+		project.Roles[userID] = MemberWriter
+		if userID == project.UserID {
+			project.Roles[userID] = MemberOwner
+		}
+	}
+	p := *project
+	p.Member = append(make([]string, 0), project.Member...)
+	p.Roles = copyRoles(project.Roles)
+	s.projects[p.ID] = &p
+	return nil
+}
+
+func (s *MemoryStore) DeleteProject(project *Project) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.projects, project.ID)
+	for _, post := range s.posts {
+		if post.ProjectID != project.ID {
+			continue
+		}
+		post.delete()
+		for _, comment := range s.comments {
+			if comment.PostID == post.ID {
+				comment.delete()
+			}
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateProject(project *Project, invites, removes []string) error {
+	err := project.validate()
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[project.ID]
+	if !ok {
+		return NotFound("project_not_found", "project not found")
+	}
+	for _, other := range s.projects {
+		if other.ID != project.ID && other.Name == project.Name {
+			return Conflict("project_name_taken", "project name is already taken")
+		}
+	}
+	member := append(make([]string, 0), p.Member...)
+	roles := copyRoles(p.Roles)
+	for _, userID := range invites {
+		if _, ok := roles[userID]; ok {
+			return Conflict("duplicate_member", "user is already a member")
+		}
+		member = append(member, userID)
+		roles[userID] = MemberWriter
+	}
+	for _, userID := range removes {
+		for i, m := range member {
+			if m == userID {
+				member = append(member[:i], member[i+1:]...)
+				delete(roles, userID)
+				break
+			}
+		}
+	}
+	for userID, role := range roles {
+		if role == MemberOwner && userID != project.UserID {
+			roles[userID] = MemberEditor
+		}
+	}
+	if _, ok := roles[project.UserID]; ok {
+		roles[project.UserID] = MemberOwner
+	}
+	p.Name = project.Name
+	p.DisplayName = project.DisplayName
+	p.UserID = project.UserID
+	p.Description = project.Description
+	p.Member = member
+	p.Roles = roles
+	return nil
+}
+
+func (s *MemoryStore) SetMember(projectID, userID string, role MemberRole) error {
+	err := validateMemberRole(role)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[projectID]
+	if !ok {
+		return NotFound("project_not_found", "project not found")
+	}
+	if p.UserID == userID {
+		return Validation("owner_role", "transfer ownership by changing the project owner", map[string]string{"userId": "is the owner"})
+	}
+	if _, ok := s.users[userID]; !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	if _, ok := p.Roles[userID]; !ok {
+		p.Member = append(p.Member, userID)
+	}
+	p.Roles[userID] = role
+	return nil
+}
+
+func (s *MemoryStore) RemoveMember(projectID, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[projectID]
+	if !ok {
+		return NotFound("project_not_found", "project not found")
+	}
+	if p.UserID == userID {
+		return Validation("owner_role", "the owner cannot leave the project", map[string]string{"userId": "is the owner"})
+	}
+	for i, m := range p.Member {
+		if m == userID {
+			p.Member = append(p.Member[:i], p.Member[i+1:]...)
+			delete(p.Roles, userID)
+			return nil
+		}
+	}
+	return NotFound("member_not_found", "user is not a member")
+}
+
+func (s *MemoryStore) GetProjects() ([]Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	projects := make([]Project, 0)
+	for _, p := range s.projects {
+		projects = append(projects, s.project(p))
+	}
+	sort.Slice(projects, func(i, j int) bool {
+		return projects[i].ID < projects[j].ID
+	})
+	return projects, nil
+}
+
+func (s *MemoryStore) GetProject(ID string) (Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	p, ok := s.projects[ID]
+	if !ok {
+		return Project{}, NotFound("project_not_found", "project not found")
+	}
+	return s.project(p), nil
+}
+
+func (s *MemoryStore) GetProjectByName(projectName string) (Project, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.projects {
+		if p.Name == projectName {
+			return s.project(p), nil
+		}
+	}
+	return Project{}, NotFound("project_not_found", "project not found")
+}
+
+func (s *MemoryStore) project(p *Project) Project {
+	project := *p
+	project.Member = append(make([]string, 0), p.Member...)
+	project.Roles = copyRoles(p.Roles)
+	project.PostCount = 0
+	for _, post := range s.posts {
+		if post.ProjectID == p.ID && post.live() && post.Published() {
+			project.PostCount++
+		}
+	}
+	return project
+}
+
+func (s *MemoryStore) InsertPost(post *Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	post.Number = 0
+	for _, p := range s.posts {
+		if p.ID == post.ID {
+			return Conflict("post_exists", "post already exists")
+		}
+		if p.ProjectID == post.ProjectID && p.Number >= post.Number {
+			post.Number = p.Number + 1
+		}
+	}
+	s.posts = append(s.posts, &memoryPost{Post: *post})
+	s.index.Put(HitPost+":"+post.ID, post.Title, post.Content)
+	s.insertRevision(post, post.UserID)
+	return nil
+}
+
+func (s *MemoryStore) DeletePost(post *Post) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.posts {
+		if p.ID == post.ID {
+			p.delete()
+		}
+	}
+	for _, comment := range s.comments {
+		if comment.PostID == post.ID {
+			comment.delete()
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdatePost(post *Post, editorID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.posts {
+		if p.ID == post.ID {
+			p.Title = post.Title
+			p.Content = post.Content
+			p.ContentHTML = post.ContentHTML
+			p.RenderKey = post.RenderKey
+			p.ThumbSrc = post.ThumbSrc
+			p.Status = post.Status
+			p.PublishAt = post.PublishAt
+			p.CreatedAt = post.CreatedAt
+			p.UpdatedAt = time.Now().Format("2006-01-02 15:04:05")
+			s.index.Put(HitPost+":"+post.ID, post.Title, post.Content)
+			s.insertRevision(post, editorID)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetUserPosts(userID string, tags TagFilter, page Page) ([]Post, error) {
+	return s.listPosts(func(post *memoryPost) bool {
+		return post.UserID == userID && post.Published() && tags.matches(s.postTags[post.ID])
+	}, page), nil
+}
+
+func (s *MemoryStore) GetProjectPosts(projectID string, tags TagFilter, page Page) ([]Post, error) {
+	return s.listPosts(func(post *memoryPost) bool {
+		return post.ProjectID == projectID && post.Published() && tags.matches(s.postTags[post.ID])
+	}, page), nil
+}
+
+func (s *MemoryStore) GetPosts(tags TagFilter, page Page) ([]Post, error) {
+	return s.listPosts(func(post *memoryPost) bool {
+		return post.Published() && tags.matches(s.postTags[post.ID])
+	}, page), nil
+}
+
+func (s *MemoryStore) GetDrafts(userID string, page Page) ([]Post, error) {
+	return s.listPosts(func(post *memoryPost) bool {
+		return post.UserID == userID && !post.Published()
+	}, page), nil
+}
+
+func (s *MemoryStore) PublishDue(now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	due := now.Format("2006-01-02 15:04:05")
+	var published int64
+	for _, post := range s.posts {
+		if post.Status == PostScheduled && post.PublishAt <= due {
+			post.Status = PostPublished
+			post.CreatedAt = post.PublishAt
+			published++
+		}
+	}
+	return published, nil
+}
+
+func (s *MemoryStore) SetContentHTML(postID, contentHTML, renderKey string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, post := range s.posts {
+		if post.ID == postID {
+			post.ContentHTML = contentHTML
+			post.RenderKey = renderKey
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetPost(postID string) (Post, error) {
+	posts := s.listPosts(func(post *memoryPost) bool {
+		return post.ID == postID
+	}, Page{Limit: 1})
+	if len(posts) == 0 {
+		return Post{}, NotFound("post_not_found", "post not found")
+	}
+	return posts[0], nil
+}
+
+func (s *MemoryStore) GetProjectPostById(projectID string, postNumber int) (Post, error) {
+	posts := s.listPosts(func(post *memoryPost) bool {
+		return post.ProjectID == projectID && post.Number == postNumber
+	}, Page{Limit: 1})
+	if len(posts) == 0 {
+		return Post{}, NotFound("post_not_found", "post not found")
+	}
+	return posts[0], nil
+}
+
+// listPosts returns the live posts accepted by match, newest first.
+func (s *MemoryStore) listPosts(match func(*memoryPost) bool, page Page) []Post {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matched := make([]Post, 0)
+	for i := len(s.posts) - 1; i >= 0; i-- {
+		p := s.posts[i]
+		if !p.live() || !match(p) || !page.includes(p.CreatedAt, p.ID) {
+			continue
+		}
+		post := p.Post
+		post.Tags = append(make([]string, 0), s.postTags[post.ID]...)
+		post.CommentNum = 0
+		for _, comment := range s.comments {
+			if comment.PostID == post.ID && comment.live() {
+				post.CommentNum++
+			}
+		}
+		matched = append(matched, post)
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		return newestFirst(matched[i].CreatedAt, matched[i].ID, matched[j].CreatedAt, matched[j].ID)
+	})
+	return cut(matched, page)
+}
+
+func (s *MemoryStore) InsertComment(comment *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.comments {
+		if c.ID == comment.ID {
+			return Conflict("comment_exists", "comment already exists")
+		}
+	}
+	s.comments = append(s.comments, &memoryComment{Comment: *comment})
+	s.index.Put(HitComment+":"+comment.ID, "", comment.Content)
+	return nil
+}
+
+func (s *MemoryStore) DeleteComment(comment *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.comments {
+		if c.ID == comment.ID {
+			c.delete()
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) UpdateComment(comment *Comment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.comments {
+		if c.ID == comment.ID {
+			c.Content = comment.Content
+			s.index.Put(HitComment+":"+c.ID, "", c.Content)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) GetPostComments(postID string, page Page) ([]Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	comments := make([]Comment, 0)
+	for i := len(s.comments) - 1; i >= 0; i-- {
+		c := s.comments[i]
+		if c.PostID == postID && c.live() && page.includes(c.CreatedAt, c.ID) {
+			comments = append(comments, c.Comment)
+		}
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return newestFirst(comments[i].CreatedAt, comments[i].ID, comments[j].CreatedAt, comments[j].ID)
+	})
+	return cut(comments, page), nil
+}
+
+func (s *MemoryStore) GetComment(commentID string) (Comment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.comments {
+		if c.ID == commentID && c.live() {
+			return c.Comment, nil
+		}
+	}
+	return Comment{}, NotFound("comment_not_found", "comment not found")
+}
+
+func copyRoles(roles map[string]MemberRole) map[string]MemberRole {
+	c := make(map[string]MemberRole, len(roles))
+	for userID, role := range roles {
+		c[userID] = role
+	}
+	return c
+}
@@ -0,0 +1,615 @@
+package model
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/n-inja/go-blog/utils"
+)
+
+// States of a post or comment in moderation listings. Hidden items are
+// kept but left out of every public listing.
+const (
+	StateLive    = "live"
+	StateHidden  = "hidden"
+	StateDeleted = "deleted"
+)
+
+// ModerationFilter narrows moderation listings. Empty fields match
+// everything; Query matches a substring of the title or content.
+type ModerationFilter struct {
+	State     string
+	ProjectID string
+	PostID    string
+	UserID    string
+	Query     string
+}
+
+type ModeratedPost struct {
+	Post
+	State string `json:"state"`
+}
+
+type ModeratedComment struct {
+	Comment
+	State string `json:"state"`
+}
+
+// ModerationAction records an admin acting on a user's content or account.
+// Detail completes some actions, such as the new owner of a project.
+type ModerationAction struct {
+	ID         string `json:"id"`
+	ActorID    string `json:"actorId"`
+	Action     string `json:"action"`
+	TargetType string `json:"targetType"`
+	TargetID   string `json:"targetId"`
+	Reason     string `json:"reason"`
+	Detail     string `json:"detail"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+// ModerationStore lets admins see and act on everything, including hidden
+// and soft-deleted items.
+type ModerationStore interface {
+	GetModerationPosts(filter ModerationFilter, page Page) ([]ModeratedPost, error)
+	GetModerationComments(filter ModerationFilter, page Page) ([]ModeratedComment, error)
+	GetModerationPost(postID string) (ModeratedPost, error)
+	GetModerationComment(commentID string) (ModeratedComment, error)
+	// The actions below store record along with their change, so that
+	// nothing changes without one.
+	SetPostHidden(postID string, hidden bool, record *ModerationAction) error
+	SetCommentHidden(commentID string, hidden bool, record *ModerationAction) error
+	// RestorePost undeletes a post and the comments deleted with it.
+	RestorePost(postID string, record *ModerationAction) error
+	RestoreComment(commentID string, record *ModerationAction) error
+	SuspendUser(ID string, record *ModerationAction) error
+	// ReinstateUser lifts a suspension, giving the user role, or the role
+	// their account had before when role is empty.
+	ReinstateUser(ID string, role Role, record *ModerationAction) error
+	// TransferProject makes userID the owner, adding them as a member if
+	// needed. The previous owner stays on as an editor.
+	TransferProject(projectID, userID string, record *ModerationAction) error
+	// GetModerationActions lists actions on a target, or all actions when
+	// targetType is empty, newest first.
+	GetModerationActions(targetType, targetID string, page Page) ([]ModerationAction, error)
+}
+
+func stateOf(isDeleted, hidden bool) string {
+	if isDeleted {
+		return StateDeleted
+	}
+	if hidden {
+		return StateHidden
+	}
+	return StateLive
+}
+
+// where returns the conditions selecting filter from posts or comments.
+func (filter ModerationFilter) where(posts bool) (string, []interface{}) {
+	conditions := []string{"true"}
+	var args []interface{}
+	switch filter.State {
+	case StateLive:
+		conditions = append(conditions, "is_deleted = false and hidden_at is null")
+	case StateHidden:
+		conditions = append(conditions, "is_deleted = false and hidden_at is not null")
+	case StateDeleted:
+		conditions = append(conditions, "is_deleted = true")
+	}
+	if filter.ProjectID != "" && posts {
+		conditions = append(conditions, "project_id = ?")
+		args = append(args, filter.ProjectID)
+	}
+	if filter.PostID != "" && !posts {
+		conditions = append(conditions, "post_id = ?")
+		args = append(args, filter.PostID)
+	}
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Query != "" {
+		like := "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(filter.Query) + "%"
+		if posts {
+			conditions = append(conditions, "(title like ? or content like ?)")
+			args = append(args, like, like)
+		} else {
+			conditions = append(conditions, "content like ?")
+			args = append(args, like)
+		}
+	}
+	return strings.Join(conditions, " and "), args
+}
+
+func (filter ModerationFilter) matches(state, projectID, postID, userID string, text ...string) bool {
+	if filter.State != "" && filter.State != state || filter.ProjectID != "" && filter.ProjectID != projectID || filter.PostID != "" && filter.PostID != postID || filter.UserID != "" && filter.UserID != userID {
+		return false
+	}
+	if filter.Query == "" {
+		return true
+	}
+	for _, t := range text {
+		if strings.Contains(t, filter.Query) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *MySQLStore) GetModerationPosts(filter ModerationFilter, page Page) ([]ModeratedPost, error) {
+	where, args := filter.where(true)
+	clause, pageArgs := page.clause()
+	return s.moderationPosts(where+clause, append(args, pageArgs...))
+}
+
+func (s *MySQLStore) GetModerationPost(postID string) (ModeratedPost, error) {
+	posts, err := s.moderationPosts("id = ?", []interface{}{postID})
+	if err != nil {
+		return ModeratedPost{}, err
+	}
+	if len(posts) == 0 {
+		return ModeratedPost{}, NotFound("post_not_found", "post not found")
+	}
+	return posts[0], nil
+}
+
+// moderationPosts selects the posts matching where, which may end in an
+// order and a limit, with their state.
+func (s *MySQLStore) moderationPosts(where string, args []interface{}) ([]ModeratedPost, error) {
+	rows, err := s.db.Query("select posts.id, title, posts.content, posts.content_html, posts.render_key, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, posts.status, posts.publish_at, count(comments.id), posts.is_deleted, posts.hidden_at is not null from (select * from posts where "+where+") posts left join comments on comments.post_id = posts.id and comments.is_deleted = false and comments.hidden_at is null group by posts.id order by posts.created_at desc, posts.id desc", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	posts := make([]ModeratedPost, 0)
+	for rows.Next() {
+		var post ModeratedPost
+		var thumbSrc, publishAt, contentHTML sql.NullString
+		var isDeleted, hidden bool
+		err = rows.Scan(&post.ID, &post.Title, &post.Content, &contentHTML, &post.RenderKey, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.Status, &publishAt, &post.CommentNum, &isDeleted, &hidden)
+		if err != nil {
+			return nil, err
+		}
+		post.ThumbSrc = thumbSrc.String
+		post.PublishAt = publishAt.String
+		post.ContentHTML = contentHTML.String
+		post.State = stateOf(isDeleted, hidden)
+		posts = append(posts, post)
+	}
+	return posts, rows.Err()
+}
+
+func (s *MySQLStore) GetModerationComments(filter ModerationFilter, page Page) ([]ModeratedComment, error) {
+	where, args := filter.where(false)
+	clause, pageArgs := page.clause()
+	return s.moderationComments(where+clause, append(args, pageArgs...))
+}
+
+func (s *MySQLStore) GetModerationComment(commentID string) (ModeratedComment, error) {
+	comments, err := s.moderationComments("id = ?", []interface{}{commentID})
+	if err != nil {
+		return ModeratedComment{}, err
+	}
+	if len(comments) == 0 {
+		return ModeratedComment{}, NotFound("comment_not_found", "comment not found")
+	}
+	return comments[0], nil
+}
+
+func (s *MySQLStore) moderationComments(where string, args []interface{}) ([]ModeratedComment, error) {
+	rows, err := s.db.Query("select id, content, user_id, post_id, created_at, is_deleted, hidden_at is not null from comments where "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := make([]ModeratedComment, 0)
+	for rows.Next() {
+		var comment ModeratedComment
+		var isDeleted, hidden bool
+		err = rows.Scan(&comment.ID, &comment.Content, &comment.UserID, &comment.PostID, &comment.CreatedAt, &isDeleted, &hidden)
+		if err != nil {
+			return nil, err
+		}
+		comment.State = stateOf(isDeleted, hidden)
+		comments = append(comments, comment)
+	}
+	return comments, rows.Err()
+}
+
+func (s *MySQLStore) SetPostHidden(postID string, hidden bool, record *ModerationAction) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var exists string
+		err := tx.QueryRow("select id from posts where id = ? for update", postID).Scan(&exists)
+		if err != nil {
+			return notFound(err, "post_not_found", "post not found")
+		}
+		_, err = tx.Exec("update posts set hidden_at = if(?, coalesce(hidden_at, current_timestamp), null) where id = ?", hidden, postID)
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func (s *MySQLStore) SetCommentHidden(commentID string, hidden bool, record *ModerationAction) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var exists string
+		err := tx.QueryRow("select id from comments where id = ? for update", commentID).Scan(&exists)
+		if err != nil {
+			return notFound(err, "comment_not_found", "comment not found")
+		}
+		_, err = tx.Exec("update comments set hidden_at = if(?, coalesce(hidden_at, current_timestamp), null) where id = ?", hidden, commentID)
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func (s *MySQLStore) RestorePost(postID string, record *ModerationAction) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var isDeleted bool
+		var deletedAt sql.NullString
+		var projectID string
+		err := tx.QueryRow("select is_deleted, deleted_at, project_id from posts where id = ? for update", postID).Scan(&isDeleted, &deletedAt, &projectID)
+		if err != nil {
+			return notFound(err, "post_not_found", "post not found")
+		}
+		if !isDeleted {
+			return Conflict("not_deleted", "post is not deleted")
+		}
+		var exists string
+		err = tx.QueryRow("select id from projects where id = ?", projectID).Scan(&exists)
+		if err == sql.ErrNoRows {
+			return Conflict("project_deleted", "the project of this post was deleted")
+		}
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("update posts set is_deleted = false, deleted_at = null where id = ?", postID)
+		if err != nil {
+			return err
+		}
+		// comments deleted with the post or later come back with it
+		_, err = tx.Exec("update comments set is_deleted = false, deleted_at = null where post_id = ? and is_deleted = true and deleted_at >= ?", postID, deletedAt.String)
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func (s *MySQLStore) RestoreComment(commentID string, record *ModerationAction) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var commentDeleted, postDeleted bool
+		err := tx.QueryRow("select comments.is_deleted, posts.is_deleted from comments join posts on posts.id = comments.post_id where comments.id = ? for update", commentID).Scan(&commentDeleted, &postDeleted)
+		if err != nil {
+			return notFound(err, "comment_not_found", "comment not found")
+		}
+		if !commentDeleted {
+			return Conflict("not_deleted", "comment is not deleted")
+		}
+		if postDeleted {
+			return Conflict("post_deleted", "restore the post of this comment first")
+		}
+		_, err = tx.Exec("update comments set is_deleted = false, deleted_at = null where id = ?", commentID)
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func (s *MySQLStore) SuspendUser(ID string, record *ModerationAction) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var exists string
+		err := tx.QueryRow("select id from users where id = ?", ID).Scan(&exists)
+		if err != nil {
+			return notFound(err, "user_not_found", "user not found")
+		}
+		_, err = tx.Exec("insert into profiles (id, role) value(?, ?) on duplicate key update role = values(role)", ID, string(RoleSuspended))
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func (s *MySQLStore) ReinstateUser(ID string, role Role, record *ModerationAction) error {
+	if role != "" && !role.Valid() {
+		return Validation("invalid_role", "role must be admin, author, commenter or suspended", map[string]string{"role": "must be admin, author, commenter or suspended"})
+	}
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var exists string
+		err := tx.QueryRow("select id from users where id = ?", ID).Scan(&exists)
+		if err != nil {
+			return notFound(err, "user_not_found", "user not found")
+		}
+		if role == "" {
+			_, err = tx.Exec("update profiles set role = null where id = ? and role = ?", ID, string(RoleSuspended))
+		} else {
+			_, err = tx.Exec("insert into profiles (id, role) value(?, ?) on duplicate key update role = values(role)", ID, string(role))
+		}
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func (s *MySQLStore) TransferProject(projectID, userID string, record *ModerationAction) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var owner string
+		err := tx.QueryRow("select user_id from projects where id = ? for update", projectID).Scan(&owner)
+		if err != nil {
+			return notFound(err, "project_not_found", "project not found")
+		}
+		var exists string
+		err = tx.QueryRow("select id from users where id = ?", userID).Scan(&exists)
+		if err != nil {
+			return notFound(err, "user_not_found", "user not found")
+		}
+		if owner == userID {
+			return insertModerationAction(tx, record)
+		}
+		_, err = tx.Exec("update projects set user_id = ? where id = ?", userID, projectID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("update member set role = ? where project_id = ? and role = ?", string(MemberEditor), projectID, string(MemberOwner))
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("insert into member (user_id, project_id, role) value(?, ?, ?) on duplicate key update role = values(role)", userID, projectID, string(MemberOwner))
+		if err != nil {
+			return err
+		}
+		return insertModerationAction(tx, record)
+	})
+}
+
+func insertModerationAction(tx *sql.Tx, action *ModerationAction) error {
+	_, err := tx.Exec("insert into moderation_actions (id, actor_id, action, target_type, target_id, reason, detail, created_at) value(?, ?, ?, ?, ?, ?, ?, ?)", action.ID, action.ActorID, action.Action, action.TargetType, action.TargetID, action.Reason, action.Detail, action.CreatedAt)
+	return err
+}
+
+func (s *MySQLStore) GetModerationActions(targetType, targetID string, page Page) ([]ModerationAction, error) {
+	where, args := "true", []interface{}{}
+	if targetType != "" {
+		where, args = "target_type = ? and target_id = ?", []interface{}{targetType, targetID}
+	}
+	clause, pageArgs := page.clause()
+	rows, err := s.db.Query("select id, actor_id, action, target_type, target_id, reason, detail, created_at from moderation_actions where "+where+clause, append(args, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	actions := make([]ModerationAction, 0)
+	for rows.Next() {
+		var action ModerationAction
+		err = rows.Scan(&action.ID, &action.ActorID, &action.Action, &action.TargetType, &action.TargetID, &action.Reason, &action.Detail, &action.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+	return actions, rows.Err()
+}
+
+func (s *MemoryStore) GetModerationPosts(filter ModerationFilter, page Page) ([]ModeratedPost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	posts := make([]ModeratedPost, 0)
+	for _, p := range s.posts {
+		state := stateOf(p.isDeleted, p.hidden)
+		if !filter.matches(state, p.ProjectID, "", p.UserID, p.Title, p.Content) || !page.includes(p.CreatedAt, p.ID) {
+			continue
+		}
+		posts = append(posts, s.moderatedPost(p))
+	}
+	sort.Slice(posts, func(i, j int) bool {
+		return newestFirst(posts[i].CreatedAt, posts[i].ID, posts[j].CreatedAt, posts[j].ID)
+	})
+	return cut(posts, page), nil
+}
+
+func (s *MemoryStore) GetModerationComments(filter ModerationFilter, page Page) ([]ModeratedComment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	comments := make([]ModeratedComment, 0)
+	for _, c := range s.comments {
+		state := stateOf(c.isDeleted, c.hidden)
+		if !filter.matches(state, "", c.PostID, c.UserID, c.Content) || !page.includes(c.CreatedAt, c.ID) {
+			continue
+		}
+		comments = append(comments, ModeratedComment{Comment: c.Comment, State: state})
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return newestFirst(comments[i].CreatedAt, comments[i].ID, comments[j].CreatedAt, comments[j].ID)
+	})
+	return cut(comments, page), nil
+}
+
+func (s *MemoryStore) GetModerationPost(postID string) (ModeratedPost, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, p := range s.posts {
+		if p.ID == postID {
+			return s.moderatedPost(p), nil
+		}
+	}
+	return ModeratedPost{}, NotFound("post_not_found", "post not found")
+}
+
+func (s *MemoryStore) moderatedPost(p *memoryPost) ModeratedPost {
+	post := ModeratedPost{Post: p.Post, State: stateOf(p.isDeleted, p.hidden)}
+	post.CommentNum = 0
+	for _, comment := range s.comments {
+		if comment.PostID == post.ID && comment.live() {
+			post.CommentNum++
+		}
+	}
+	return post
+}
+
+func (s *MemoryStore) GetModerationComment(commentID string) (ModeratedComment, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, c := range s.comments {
+		if c.ID == commentID {
+			return ModeratedComment{Comment: c.Comment, State: stateOf(c.isDeleted, c.hidden)}, nil
+		}
+	}
+	return ModeratedComment{}, NotFound("comment_not_found", "comment not found")
+}
+
+func (s *MemoryStore) SetPostHidden(postID string, hidden bool, record *ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.posts {
+		if p.ID == postID {
+			p.hidden = hidden
+			s.moderationActions = append(s.moderationActions, *record)
+			return nil
+		}
+	}
+	return NotFound("post_not_found", "post not found")
+}
+
+func (s *MemoryStore) SetCommentHidden(commentID string, hidden bool, record *ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.comments {
+		if c.ID == commentID {
+			c.hidden = hidden
+			s.moderationActions = append(s.moderationActions, *record)
+			return nil
+		}
+	}
+	return NotFound("comment_not_found", "comment not found")
+}
+
+func (s *MemoryStore) RestorePost(postID string, record *ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.posts {
+		if p.ID != postID {
+			continue
+		}
+		if !p.isDeleted {
+			return Conflict("not_deleted", "post is not deleted")
+		}
+		if _, ok := s.projects[p.ProjectID]; !ok {
+			return Conflict("project_deleted", "the project of this post was deleted")
+		}
+		for _, comment := range s.comments {
+			if comment.PostID == postID && comment.isDeleted && !comment.deletedAt.Before(p.deletedAt) {
+				comment.isDeleted = false
+				comment.deletedAt = time.Time{}
+			}
+		}
+		p.isDeleted = false
+		p.deletedAt = time.Time{}
+		s.moderationActions = append(s.moderationActions, *record)
+		return nil
+	}
+	return NotFound("post_not_found", "post not found")
+}
+
+func (s *MemoryStore) RestoreComment(commentID string, record *ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.comments {
+		if c.ID != commentID {
+			continue
+		}
+		if !c.isDeleted {
+			return Conflict("not_deleted", "comment is not deleted")
+		}
+		for _, p := range s.posts {
+			if p.ID == c.PostID && p.isDeleted {
+				return Conflict("post_deleted", "restore the post of this comment first")
+			}
+		}
+		c.isDeleted = false
+		c.deletedAt = time.Time{}
+		s.moderationActions = append(s.moderationActions, *record)
+		return nil
+	}
+	return NotFound("comment_not_found", "comment not found")
+}
+
+func (s *MemoryStore) SuspendUser(ID string, record *ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[ID]; !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	s.roles[ID] = RoleSuspended
+	s.moderationActions = append(s.moderationActions, *record)
+	return nil
+}
+
+func (s *MemoryStore) ReinstateUser(ID string, role Role, record *ModerationAction) error {
+	if role != "" && !role.Valid() {
+		return Validation("invalid_role", "role must be admin, author, commenter or suspended", map[string]string{"role": "must be admin, author, commenter or suspended"})
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.users[ID]; !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	if role != "" {
+		s.roles[ID] = role
+	} else if s.roles[ID] == RoleSuspended {
+		delete(s.roles, ID)
+	}
+	s.moderationActions = append(s.moderationActions, *record)
+	return nil
+}
+
+func (s *MemoryStore) TransferProject(projectID, userID string, record *ModerationAction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.projects[projectID]
+	if !ok {
+		return NotFound("project_not_found", "project not found")
+	}
+	if _, ok := s.users[userID]; !ok {
+		return NotFound("user_not_found", "user not found")
+	}
+	if p.UserID == userID {
+		s.moderationActions = append(s.moderationActions, *record)
+		return nil
+	}
+	for member, role := range p.Roles {
+		if role == MemberOwner {
+			p.Roles[member] = MemberEditor
+		}
+	}
+	if _, ok := p.Roles[userID]; !ok {
+		p.Member = append(p.Member, userID)
+	}
+	p.Roles[userID] = MemberOwner
+	p.UserID = userID
+	s.moderationActions = append(s.moderationActions, *record)
+	return nil
+}
+
+func (s *MemoryStore) GetModerationActions(targetType, targetID string, page Page) ([]ModerationAction, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	actions := make([]ModerationAction, 0)
+	for _, action := range s.moderationActions {
+		if targetType != "" && (action.TargetType != targetType || action.TargetID != targetID) || !page.includes(action.CreatedAt, action.ID) {
+			continue
+		}
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return newestFirst(actions[i].CreatedAt, actions[i].ID, actions[j].CreatedAt, actions[j].ID)
+	})
+	return cut(actions, page), nil
+}
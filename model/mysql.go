@@ -0,0 +1,69 @@
+package model
+
+import (
+	"database/sql"
+	"sync/atomic"
+)
+
+// MySQLStore sends writes and transactions to db and spreads read-only
+// queries over the replicas, falling back to db when there are none.
+type MySQLStore struct {
+	db       *sql.DB
+	replicas []*sql.DB
+	next     uint32
+}
+
+func NewMySQLStore(db *sql.DB, replicas ...*sql.DB) *MySQLStore {
+	return &MySQLStore{db: db, replicas: replicas}
+}
+
+// Primary returns a view of the store that also reads from the primary.
+func (s *MySQLStore) Primary() Store {
+	if len(s.replicas) == 0 {
+		return s
+	}
+	return &MySQLStore{db: s.db}
+}
+
+func (s *MySQLStore) reader() *sql.DB {
+	if len(s.replicas) == 0 {
+		return s.db
+	}
+	n := atomic.AddUint32(&s.next, 1)
+	return s.replicas[int(n)%len(s.replicas)]
+}
+
+func (s *MySQLStore) Ping() error {
+	err := s.db.Ping()
+	if err != nil {
+		return err
+	}
+	for _, replica := range s.replicas {
+		err = replica.Ping()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MySQLStore) Close() error {
+	for _, replica := range s.replicas {
+		replica.Close()
+	}
+	return s.db.Close()
+}
+
+func (s *MySQLStore) checkProfile(ID string) {
+
+	rows, err := s.db.Query("select id from profiles where id = ?", ID)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		rows.Close()
+		s.db.Exec("insert into profiles (id) value(?)", ID)
+		return
+	}
+}
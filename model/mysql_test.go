@@ -0,0 +1,115 @@
+package model_test
+
+import (
+	"database/sql"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/n-inja/go-blog/migration"
+	"github.com/n-inja/go-blog/model"
+)
+
+// mysqlStore migrates a real server up for a test and back down after it.
+// It needs GO_BLOG_TEST_MYSQL, the DSN of a scratch database whose tables
+// it may create and drop, and skips the test without it. The store holds
+// alice, an author, and her project p1.
+func mysqlStore(t *testing.T) *model.MySQLStore {
+	t.Helper()
+	dsn := os.Getenv("GO_BLOG_TEST_MYSQL")
+	if dsn == "" {
+		t.Skip("GO_BLOG_TEST_MYSQL is not set")
+	}
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrator := migration.New(db)
+	migrator.CreateUsers = true
+	err = migrator.Up()
+	if err != nil {
+		db.Close()
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		statuses, _ := migrator.Status()
+		err := migrator.Down(len(statuses))
+		if err != nil {
+			t.Error(err)
+		}
+		db.Close()
+	})
+
+	store := model.NewMySQLStore(db)
+	user := model.User{ID: "alice", Name: "Alice"}
+	err = store.CreateAccount(&user, model.RoleAuthor, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.InsertProject(&model.Project{ID: "p1", Name: "diary", DisplayName: "日記", UserID: "alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestMySQLSearch(t *testing.T) {
+	store := mysqlStore(t)
+	now := time.Now().Format("2006-01-02 15:04:05")
+	post := model.Post{ID: "post1", Title: "東京の天気", Content: "今日は晴れ", UserID: "alice", ProjectID: "p1", CreatedAt: now, Status: model.PostPublished, PublishAt: now}
+	comment := model.Comment{ID: "comment1", Content: "京都も晴れ", UserID: "alice", PostID: "post1", CreatedAt: now}
+	for _, err := range []error{store.InsertPost(&post), store.InsertComment(&comment)} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"東京", []string{"post1"}},
+		{"晴れ", []string{"comment1", "post1"}},
+		{"大阪", nil},
+	}
+	for _, tt := range tests {
+		hits, err := store.Search(model.SearchQuery{Text: tt.text, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, hit := range hits {
+			got = append(got, hit.ID)
+		}
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Search(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestMySQLTags(t *testing.T) {
+	store := mysqlStore(t)
+	now := time.Now().Format("2006-01-02 15:04:05")
+	post := model.Post{ID: "post1", Title: "menu", Content: "coffee", UserID: "alice", ProjectID: "p1", CreatedAt: now, Status: model.PostPublished, PublishAt: now}
+	err := store.InsertPost(&post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// tags the store tells apart the way NormalizeTag does
+	err = store.SetPostTags("post1", []string{"café", "cafe"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagged, err := store.GetPost("post1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(tagged.Tags)
+	if !reflect.DeepEqual(tagged.Tags, []string{"cafe", "café"}) {
+		t.Errorf("tags = %v", tagged.Tags)
+	}
+}
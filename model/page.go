@@ -0,0 +1,78 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+)
+
+// Page selects part of a listing ordered newest first. When Cursor is set
+// the listing continues after it and Offset is ignored.
+type Page struct {
+	Offset int
+	Limit  int
+	Cursor *Cursor
+}
+
+// Cursor is the position of the last item a client has seen.
+type Cursor struct {
+	CreatedAt string `json:"c"`
+	ID        string `json:"i"`
+}
+
+func (cursor Cursor) Encode() string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func DecodeCursor(s string) (Cursor, error) {
+	var cursor Cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cursor, Validation("invalid_cursor", "invalid cursor", map[string]string{"cursor": "is not a cursor returned by this API"})
+	}
+	err = json.Unmarshal(data, &cursor)
+	if err != nil || cursor.CreatedAt == "" || cursor.ID == "" {
+		return cursor, Validation("invalid_cursor", "invalid cursor", map[string]string{"cursor": "is not a cursor returned by this API"})
+	}
+	return cursor, nil
+}
+
+// clause returns the SQL that follows a where clause to select page from
+// a table with created_at and id columns.
+func (page Page) clause() (string, []interface{}) {
+	if page.Cursor != nil {
+		return " and (created_at < ? or (created_at = ? and id < ?)) order by created_at desc, id desc limit ?", []interface{}{page.Cursor.CreatedAt, page.Cursor.CreatedAt, page.Cursor.ID, page.Limit}
+	}
+	return " order by created_at desc, id desc limit ?, ?", []interface{}{page.Offset, page.Limit}
+}
+
+// includes reports whether an item lies after the cursor, for in-memory listings.
+func (page Page) includes(createdAt, ID string) bool {
+	if page.Cursor == nil {
+		return true
+	}
+	return createdAt < page.Cursor.CreatedAt || createdAt == page.Cursor.CreatedAt && ID < page.Cursor.ID
+}
+
+// cut returns the items of page out of items, which are sorted and, when
+// paging by cursor, already start after the cursor.
+func cut[T any](items []T, page Page) []T {
+	if page.Cursor == nil {
+		if page.Offset >= len(items) {
+			return make([]T, 0)
+		}
+		items = items[page.Offset:]
+	}
+	if page.Limit < len(items) {
+		items = items[:page.Limit]
+	}
+	return items
+}
+
+// newestFirst reports whether item a sorts before item b in a listing.
+func newestFirst(aCreatedAt, aID, bCreatedAt, bID string) bool {
+	if aCreatedAt != bCreatedAt {
+		return aCreatedAt > bCreatedAt
+	}
+	return aID > bID
+}
@@ -2,8 +2,7 @@ package model
 
 import (
 	"database/sql"
-	"errors"
-	"fmt"
+	"time"
 
 	"github.com/n-inja/go-blog/utils"
 )
@@ -20,87 +19,137 @@ type Post struct {
 	Views      int    `json:"views" form:"views"`
 	CommentNum int    `json:"commentNum" form:"commentNum"`
 	Number     int    `json:"number" form:"number"`
+	// Status is draft, scheduled or published. PublishAt is when a
+	// scheduled post goes out, or when a published one did; publishing
+	// also moves CreatedAt there, so the post is listed as new.
+	Status    string `json:"status" form:"status"`
+	PublishAt string `json:"publishAt" form:"publishAt"`
+	// ContentHTML caches Content as rendered by the pipeline RenderKey
+	// names. It is rendered again when the pipeline changes.
+	ContentHTML string `json:"contentHtml" form:"-"`
+	RenderKey   string `json:"-" form:"-"`
+	// Tags are sorted and normalized.
+	Tags []string `json:"tags" form:"-"`
 }
 
-func (post *Post) Insert() error {
-	return utils.Transact(func(tx *sql.Tx) error {
-		err := tx.QueryRow("select count(*) from posts where project_id = ?", post.ProjectID).Scan(&post.Number)
+const (
+	PostDraft     = "draft"
+	PostScheduled = "scheduled"
+	PostPublished = "published"
+)
+
+// Published reports whether the post is listed for everyone.
+func (post Post) Published() bool {
+	return post.Status == PostPublished
+}
+
+// PublishStore publishes scheduled posts.
+type PublishStore interface {
+	// PublishDue publishes the scheduled posts whose time is not after now.
+	PublishDue(now time.Time) (int64, error)
+}
+
+// RenderStore caches rendered content.
+type RenderStore interface {
+	// SetContentHTML replaces the cached HTML of a post without counting
+	// as an update.
+	SetContentHTML(postID, contentHTML, renderKey string) error
+}
+
+// nullable stores an empty string as NULL.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (s *MySQLStore) InsertPost(post *Post) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		err := tx.QueryRow("select coalesce(max(number) + 1, 0) from posts where project_id = ?", post.ProjectID).Scan(&post.Number)
 		if err != nil {
-			fmt.Println("a")
 			return err
 		}
-		_, err = tx.Exec("insert into posts (id, title, content, thumb_src, user_id, number, project_id, views, is_deleted) value(?, ?, ?, ?, ?, ?, ?, ?, ?)", post.ID, post.Title, post.Content, post.ThumbSrc, post.UserID, post.Number, post.ProjectID, post.Views, false)
-		fmt.Println("b")
-		return err
+		_, err = tx.Exec("insert into posts (id, title, content, content_html, render_key, thumb_src, user_id, number, created_at, project_id, views, is_deleted, status, publish_at) value(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)", post.ID, post.Title, post.Content, post.ContentHTML, post.RenderKey, post.ThumbSrc, post.UserID, post.Number, post.CreatedAt, post.ProjectID, post.Views, false, post.Status, nullable(post.PublishAt))
+		if err != nil {
+			return conflict(err, "post_exists", "post already exists")
+		}
+		return insertRevision(tx, post, post.UserID)
 	})
 }
 
-func (post *Post) Delete() error {
-	return utils.Transact(func(tx *sql.Tx) error {
-		_, err := tx.Exec("update posts set is_deleted = true where id = ?", post.ID)
+func (s *MySQLStore) DeletePost(post *Post) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("update posts set is_deleted = true, deleted_at = current_timestamp where id = ?", post.ID)
 		if err != nil {
 			return err
 		}
-		_, err = tx.Exec("update comments set is_deleted = true where post_id = ?", post.ID)
+		_, err = tx.Exec("update comments set is_deleted = true, deleted_at = current_timestamp where post_id = ? and is_deleted = false", post.ID)
 		return err
 	})
 }
 
-func (post *Post) Update() error {
-	_, err := utils.DB.Exec("update posts set title = ?, content = ?, thumb_src = ? where id = ?", post.Title, post.Content, post.ThumbSrc, post.ID)
+func (s *MySQLStore) UpdatePost(post *Post, editorID string) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("update posts set title = ?, content = ?, content_html = ?, render_key = ?, thumb_src = ?, status = ?, publish_at = ?, created_at = ? where id = ?", post.Title, post.Content, post.ContentHTML, post.RenderKey, post.ThumbSrc, post.Status, nullable(post.PublishAt), post.CreatedAt, post.ID)
+		if err != nil {
+			return err
+		}
+		return insertRevision(tx, post, editorID)
+	})
+}
+
+// SetContentHTML leaves updated_at alone, which the column would otherwise
+// move to now.
+func (s *MySQLStore) SetContentHTML(postID, contentHTML, renderKey string) error {
+	_, err := s.db.Exec("update posts set content_html = ?, render_key = ?, updated_at = updated_at where id = ?", contentHTML, renderKey, postID)
 	return err
 }
 
-func GetUserPosts(userID string, offset, limit int) ([]Post, error) {
-	rows, err := utils.DB.Query("select posts.id, title, posts.content, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, count(comments.id) from (select * from posts where user_id = ? and is_deleted = false order by created_at desc limit ?, ?) posts left join comments on comments.post_id = posts.id group by posts.id", userID, offset, limit)
+// PublishDue moves CreatedAt to the scheduled time, which is at most one
+// scheduler interval in the past.
+func (s *MySQLStore) PublishDue(now time.Time) (int64, error) {
+	result, err := s.db.Exec("update posts set status = 'published', created_at = publish_at where status = 'scheduled' and publish_at <= ?", now.Format("2006-01-02 15:04:05"))
 	if err != nil {
-		return make([]Post, 0), err
-	}
-	posts := make([]Post, 0)
-	for rows.Next() {
-		var post Post
-		var thumbSrc sql.NullString
-		rows.Scan(&post.ID, &post.Title, &post.Content, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.CommentNum)
-		post.ThumbSrc = ""
-		if thumbSrc.Valid {
-			post.ThumbSrc = thumbSrc.String
-		}
-		posts = append(posts, post)
+		return 0, err
 	}
-	rows.Close()
-	return posts, nil
+	return result.RowsAffected()
 }
 
-func GetProjectPosts(projectID string, offset, limit int) ([]Post, error) {
-	rows, err := utils.DB.Query("select posts.id, title, posts.content, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, count(comments.id) from (select * from posts where project_id = ? and is_deleted = false order by created_at desc limit ?, ?) posts left join comments on comments.post_id = posts.id group by posts.id", projectID, offset, limit)
-	if err != nil {
-		return make([]Post, 0), err
-	}
-	posts := make([]Post, 0)
-	for rows.Next() {
-		var post Post
-		var thumbSrc sql.NullString
-		rows.Scan(&post.ID, &post.Title, &post.Content, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.CommentNum)
-		post.ThumbSrc = ""
-		if thumbSrc.Valid {
-			post.ThumbSrc = thumbSrc.String
-		}
-		posts = append(posts, post)
-	}
-	rows.Close()
-	return posts, nil
+// GetDrafts lists the user's drafts and scheduled posts.
+func (s *MySQLStore) GetDrafts(userID string, page Page) ([]Post, error) {
+	return s.queryPosts("user_id = ? and status != 'published'", page, userID)
+}
+
+func (s *MySQLStore) GetUserPosts(userID string, tags TagFilter, page Page) ([]Post, error) {
+	where, args := tags.where("user_id = ? and status = 'published'", []interface{}{userID})
+	return s.queryPosts(where, page, args...)
 }
 
-func GetPosts(offset, limit int) ([]Post, error) {
-	rows, err := utils.DB.Query("select posts.id, title, posts.content, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, count(comments.id) from (select * from posts where is_deleted = false order by created_at desc limit ?, ?) posts left join comments on comments.post_id = posts.id group by posts.id", offset, limit)
+func (s *MySQLStore) GetProjectPosts(projectID string, tags TagFilter, page Page) ([]Post, error) {
+	where, args := tags.where("project_id = ? and status = 'published'", []interface{}{projectID})
+	return s.queryPosts(where, page, args...)
+}
+
+func (s *MySQLStore) GetPosts(tags TagFilter, page Page) ([]Post, error) {
+	where, args := tags.where("status = 'published'", nil)
+	return s.queryPosts(where, page, args...)
+}
+
+// queryPosts lists the live posts matching where, newest first.
+func (s *MySQLStore) queryPosts(where string, page Page, args ...interface{}) ([]Post, error) {
+	clause, pageArgs := page.clause()
+	rows, err := s.reader().Query("select posts.id, title, posts.content, posts.content_html, posts.render_key, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, posts.status, posts.publish_at, count(comments.id) from (select * from posts where "+where+" and is_deleted = false and hidden_at is null"+clause+") posts left join comments on comments.post_id = posts.id and comments.is_deleted = false and comments.hidden_at is null group by posts.id order by posts.created_at desc, posts.id desc", append(args, pageArgs...)...)
 	if err != nil {
 		return make([]Post, 0), err
 	}
 	posts := make([]Post, 0)
 	for rows.Next() {
 		var post Post
-		var thumbSrc sql.NullString
-		rows.Scan(&post.ID, &post.Title, &post.Content, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.CommentNum)
+		var thumbSrc, publishAt, contentHTML sql.NullString
+		rows.Scan(&post.ID, &post.Title, &post.Content, &contentHTML, &post.RenderKey, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.Status, &publishAt, &post.CommentNum)
+		post.PublishAt = publishAt.String
+		post.ContentHTML = contentHTML.String
 		post.ThumbSrc = ""
 		if thumbSrc.Valid {
 			post.ThumbSrc = thumbSrc.String
@@ -108,37 +157,43 @@ func GetPosts(offset, limit int) ([]Post, error) {
 		posts = append(posts, post)
 	}
 	rows.Close()
-	return posts, nil
+	return posts, s.loadTags(posts)
 }
 
-func GetPost(postID string) (Post, error) {
+func (s *MySQLStore) GetPost(postID string) (Post, error) {
 	var post Post
-	var thumbSrc sql.NullString
-	err := utils.DB.QueryRow("select posts.id, title, posts.content, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, count(comments.id) from (select * from posts where id = ? and is_deleted = false) posts left join comments on posts.id = comments.post_id group by posts.id", postID).Scan(&post.ID, &post.Title, &post.Content, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.CommentNum)
+	var thumbSrc, publishAt, contentHTML sql.NullString
+	err := s.reader().QueryRow("select posts.id, title, posts.content, posts.content_html, posts.render_key, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, posts.status, posts.publish_at, count(comments.id) from (select * from posts where id = ? and is_deleted = false and hidden_at is null) posts left join comments on posts.id = comments.post_id and comments.is_deleted = false and comments.hidden_at is null group by posts.id", postID).Scan(&post.ID, &post.Title, &post.Content, &contentHTML, &post.RenderKey, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.Status, &publishAt, &post.CommentNum)
 	if err != nil {
-		return Post{}, err
+		return Post{}, notFound(err, "post_not_found", "post not found")
 	}
+	post.PublishAt = publishAt.String
+	post.ContentHTML = contentHTML.String
 	post.ThumbSrc = ""
 	if thumbSrc.Valid {
 		post.ThumbSrc = thumbSrc.String
 	}
-
-	return post, nil
+	posts := []Post{post}
+	err = s.loadTags(posts)
+	return posts[0], err
 }
 
-func GetProjectPostById(projectID string, postNumber int) (Post, error) {
+func (s *MySQLStore) GetProjectPostById(projectID string, postNumber int) (Post, error) {
 	var post Post
-	var thumbSrc sql.NullString
-	err := utils.DB.QueryRow("select posts.id, title, posts.content, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, count(comments.id) from (select * from posts where project_id = ? and number = ? and is_deleted = false) posts left join comments on posts.id = comments.post_id group by posts.id", projectID, postNumber).Scan(&post.ID, &post.Title, &post.Content, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.CommentNum)
+	var thumbSrc, publishAt, contentHTML sql.NullString
+	err := s.reader().QueryRow("select posts.id, title, posts.content, posts.content_html, posts.render_key, thumb_src, posts.user_id, posts.number, posts.created_at, updated_at, project_id, views, posts.status, posts.publish_at, count(comments.id) from (select * from posts where project_id = ? and number = ? and is_deleted = false and hidden_at is null) posts left join comments on posts.id = comments.post_id and comments.is_deleted = false and comments.hidden_at is null group by posts.id", projectID, postNumber).Scan(&post.ID, &post.Title, &post.Content, &contentHTML, &post.RenderKey, &thumbSrc, &post.UserID, &post.Number, &post.CreatedAt, &post.UpdatedAt, &post.ProjectID, &post.Views, &post.Status, &publishAt, &post.CommentNum)
 	if err != nil {
-		return Post{}, errors.New("db error")
+		return Post{}, notFound(err, "post_not_found", "post not found")
 	}
+	post.PublishAt = publishAt.String
+	post.ContentHTML = contentHTML.String
 
 	post.ThumbSrc = ""
 	if thumbSrc.Valid {
 		post.ThumbSrc = thumbSrc.String
 	}
 	post.Number = postNumber
-
-	return post, nil
+	posts := []Post{post}
+	err = s.loadTags(posts)
+	return posts[0], err
 }
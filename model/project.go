@@ -2,7 +2,6 @@ package model
 
 import (
 	"database/sql"
-	"errors"
 
 	"github.com/n-inja/go-blog/utils"
 )
@@ -13,59 +12,76 @@ type Project struct {
 	DisplayName string   `json:"displayName" form:"displayName"`
 	UserID      string   `json:"userId" form:"userId"`
 	Member      []string `json:"member" form:"member"`
-	Description string   `json:"description" form:"description"`
-	PostCount   int      `json:"postCount" form:"postCount"`
+	// Roles maps each member to their role in this project.
+	Roles       map[string]MemberRole `json:"roles" form:"roles"`
+	Description string                `json:"description" form:"description"`
+	PostCount   int                   `json:"postCount" form:"postCount"`
 }
 
-func (project *Project) Insert() error {
+func (project *Project) validate() error {
 	if !utils.RegexProjectName.MatchString(project.Name) {
-		return errors.New("project name := ^[a-zA-Z0-9_-]+$")
+		return Validation("invalid_project_name", "project name must match ^[a-zA-Z0-9_-]+$", map[string]string{"name": "must match ^[a-zA-Z0-9_-]+$"})
 	}
-	return utils.Transact(func(tx *sql.Tx) error {
+	return nil
+}
+
+func (s *MySQLStore) InsertProject(project *Project) error {
+	err := project.validate()
+	if err != nil {
+		return err
+	}
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
 		_, err := tx.Exec("insert into projects (id, name, display_name, user_id, description) value(?, ?, ?, ?, ?)", project.ID, project.Name, project.DisplayName, project.UserID, project.Description)
 		if err != nil {
-			return err
+			return conflict(err, "project_name_taken", "project name is already taken")
 		}
+		project.Roles = map[string]MemberRole{}
 		for _, userID := range project.Member {
-			_, err = tx.Exec("insert into member (user_id, project_id) value(?, ?)", userID, project.ID)
+			role := MemberWriter
+			if userID == project.UserID {
+				role = MemberOwner
+			}
+			_, err = tx.Exec("insert into member (user_id, project_id, role) value(?, ?, ?)", userID, project.ID, string(role))
 			if err != nil {
-				return err
+				return conflict(err, "duplicate_member", "user is already a member")
 			}
+			project.Roles[userID] = role
 		}
 		return nil
 	})
 }
 
-func (project *Project) Delete() error {
-	_, err := utils.DB.Exec("delete from projects where id = ?", project.ID)
+func (s *MySQLStore) DeleteProject(project *Project) error {
+	_, err := s.db.Exec("delete from projects where id = ?", project.ID)
 	if err != nil {
 		return err
 	}
-	_, err = utils.DB.Exec("update posts set is_deleted = true where project_id = ?", project.ID)
+	_, err = s.db.Exec("update posts set is_deleted = true, deleted_at = current_timestamp where project_id = ? and is_deleted = false", project.ID)
 	if err != nil {
 		return err
 	}
-	_, err = utils.DB.Exec("delete from member where project_id = ?", project.ID)
+	_, err = s.db.Exec("delete from member where project_id = ?", project.ID)
 	if err != nil {
 		return err
 	}
-	_, err = utils.DB.Exec("update comments set is_deleted = true where post_id in (select id from posts where project_id = ?)", project.ID)
+	_, err = s.db.Exec("update comments set is_deleted = true, deleted_at = current_timestamp where is_deleted = false and post_id in (select id from posts where project_id = ?)", project.ID)
 	return err
 }
 
-func (project *Project) Update(invites, removes []string) error {
-	if !utils.RegexProjectName.MatchString(project.Name) {
-		return errors.New("project name := ^[a-zA-Z0-9_-]+$")
+func (s *MySQLStore) UpdateProject(project *Project, invites, removes []string) error {
+	err := project.validate()
+	if err != nil {
+		return err
 	}
-	return utils.Transact(func(tx *sql.Tx) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
 		_, err := tx.Exec("update projects set name = ?, display_name = ?, user_id = ?, description = ? where id = ?", project.Name, project.DisplayName, project.UserID, project.Description, project.ID)
 		if err != nil {
-			return err
+			return conflict(err, "project_name_taken", "project name is already taken")
 		}
 		for _, userID := range invites {
-			_, err = tx.Exec("insert into member (user_id, project_id) value(?, ?)", userID, project.ID)
+			_, err = tx.Exec("insert into member (user_id, project_id, role) value(?, ?, ?)", userID, project.ID, string(MemberWriter))
 			if err != nil {
-				return err
+				return conflict(err, "duplicate_member", "user is already a member")
 			}
 		}
 		for _, userID := range removes {
@@ -74,27 +90,37 @@ func (project *Project) Update(invites, removes []string) error {
 				return err
 			}
 		}
-		return nil
+		// the previous owner stays on as an editor
+		_, err = tx.Exec("update member set role = ? where project_id = ? and role = ? and user_id <> ?", string(MemberEditor), project.ID, string(MemberOwner), project.UserID)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("update member set role = ? where project_id = ? and user_id = ?", string(MemberOwner), project.ID, project.UserID)
+		return err
 	})
 }
 
-func GetProjects() ([]Project, error) {
-	memberRows, err := utils.DB.Query("select project_id, user_id from member")
+func (s *MySQLStore) GetProjects() ([]Project, error) {
+	db := s.reader()
+	memberRows, err := db.Query("select project_id, user_id, role from member")
 	if err != nil {
 		return nil, err
 	}
 	defer memberRows.Close()
 	userMap := map[string][]string{}
+	roleMap := map[string]map[string]MemberRole{}
 	for memberRows.Next() {
-		var userID, projectID string
-		memberRows.Scan(&projectID, &userID)
+		var userID, projectID, role string
+		memberRows.Scan(&projectID, &userID, &role)
 		if userMap[projectID] == nil {
 			userMap[projectID] = make([]string, 0)
+			roleMap[projectID] = map[string]MemberRole{}
 		}
 		userMap[projectID] = append(userMap[projectID], userID)
+		roleMap[projectID][userID] = MemberRole(role)
 	}
 
-	projectRows, err := utils.DB.Query("select projects.id, name, display_name, projects.user_id, description, count(*) from projects left join posts on posts.project_id = projects.id group by projects.id")
+	projectRows, err := db.Query("select projects.id, name, display_name, projects.user_id, description, count(posts.id) from projects left join posts on posts.project_id = projects.id and posts.is_deleted = false and posts.hidden_at is null and posts.status = 'published' group by projects.id")
 	if err != nil {
 		return nil, err
 	}
@@ -110,52 +136,58 @@ func GetProjects() ([]Project, error) {
 		}
 		if userMap[project.ID] == nil {
 			project.Member = make([]string, 0)
+			project.Roles = map[string]MemberRole{}
 		} else {
 			project.Member = userMap[project.ID]
+			project.Roles = roleMap[project.ID]
 		}
 		projects = append(projects, project)
 	}
 	return projects, nil
 }
 
-func GetProject(ID string) (Project, error) {
+func (s *MySQLStore) GetProject(ID string) (Project, error) {
+	db := s.reader()
 	var project Project
 	var description sql.NullString
-	err := utils.DB.QueryRow("select p.id, p.name, p.display_name, p.user_id, p.description, count(*) from (select * from projects where id = ?) p left join posts on p.id = posts.project_id group by p.id", ID).Scan(&project.ID, &project.Name, &project.DisplayName, &project.UserID, &description, &project.PostCount)
+	err := db.QueryRow("select p.id, p.name, p.display_name, p.user_id, p.description, count(posts.id) from (select * from projects where id = ?) p left join posts on p.id = posts.project_id and posts.is_deleted = false and posts.hidden_at is null and posts.status = 'published' group by p.id", ID).Scan(&project.ID, &project.Name, &project.DisplayName, &project.UserID, &description, &project.PostCount)
 	if err != nil {
-		return Project{}, err
+		return Project{}, notFound(err, "project_not_found", "project not found")
 	}
 	project.Description = ""
 	if description.Valid {
 		project.Description = description.String
 	}
 
-	rows, err := utils.DB.Query("select user_id from member where project_id = ?", project.ID)
+	rows, err := db.Query("select user_id, role from member where project_id = ?", project.ID)
 	if err != nil {
 		return Project{}, err
 	}
 	defer rows.Close()
 
 	userIDs := make([]string, 0)
+	project.Roles = map[string]MemberRole{}
 	for rows.Next() {
-		var ID string
-		rows.Scan(&ID)
+		var ID, role string
+		rows.Scan(&ID, &role)
 		userIDs = append(userIDs, ID)
+		project.Roles[ID] = MemberRole(role)
 	}
 	project.Member = userIDs
 
 	return project, nil
 }
 
-func GetProjectByName(projectName string) (Project, error) {
+func (s *MySQLStore) GetProjectByName(projectName string) (Project, error) {
+	db := s.reader()
 	var projectID string
-	err := utils.DB.QueryRow("select id from projects where name = ?", projectName).Scan(&projectID)
+	err := db.QueryRow("select id from projects where name = ?", projectName).Scan(&projectID)
 	if err != nil {
-		return Project{}, err
+		return Project{}, notFound(err, "project_not_found", "project not found")
 	}
 
 	var project Project
-	err = utils.DB.QueryRow("select id, name, description from projects where name = ?", projectName).Scan(&project.ID, &project.Name, &project.Description)
+	err = db.QueryRow("select id, name, description from projects where name = ?", projectName).Scan(&project.ID, &project.Name, &project.Description)
 
 	if err != nil {
 		return Project{}, err
@@ -0,0 +1,125 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/n-inja/go-blog/utils"
+)
+
+// PurgeDeleted formats before in local time like every other timestamp;
+// the driver would send a time.Time as UTC.
+func (s *MySQLStore) PurgeDeleted(before time.Time, batchSize int) (PurgeResult, error) {
+	cutoff := before.Format("2006-01-02 15:04:05")
+	var result PurgeResult
+	for {
+		res, err := s.db.Exec("delete from comments where is_deleted = true and deleted_at < ? limit ?", cutoff, batchSize)
+		if err != nil {
+			return result, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return result, err
+		}
+		result.Comments += int(n)
+		if int(n) < batchSize {
+			break
+		}
+	}
+
+	for {
+		var postIDs []interface{}
+		err := utils.Transact(s.db, func(tx *sql.Tx) error {
+			rows, err := tx.Query("select id from posts where is_deleted = true and deleted_at < ? limit ? for update", cutoff, batchSize)
+			if err != nil {
+				return err
+			}
+			defer rows.Close()
+			for rows.Next() {
+				var postID string
+				err = rows.Scan(&postID)
+				if err != nil {
+					return err
+				}
+				postIDs = append(postIDs, postID)
+			}
+			rows.Close()
+			if len(postIDs) == 0 {
+				return nil
+			}
+
+			in := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(postIDs)), ", ") + ")"
+			// comments reference posts, so they have to go first whatever their own state
+			res, err := tx.Exec("delete from comments where post_id in "+in, postIDs...)
+			if err != nil {
+				return err
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+			result.Comments += int(n)
+			_, err = tx.Exec("delete from post_revisions where post_id in "+in, postIDs...)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("delete from post_tags where post_id in "+in, postIDs...)
+			if err != nil {
+				return err
+			}
+			_, err = tx.Exec("delete from posts where id in "+in, postIDs...)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Posts += len(postIDs)
+		if len(postIDs) < batchSize {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStore) PurgeDeleted(before time.Time, batchSize int) (PurgeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var result PurgeResult
+
+	purged := map[string]bool{}
+	posts := make([]*memoryPost, 0, len(s.posts))
+	for _, post := range s.posts {
+		if post.isDeleted && post.deletedAt.Before(before) {
+			purged[post.ID] = true
+			s.index.Remove(HitPost + ":" + post.ID)
+			result.Posts++
+			continue
+		}
+		posts = append(posts, post)
+	}
+	s.posts = posts
+
+	comments := make([]*memoryComment, 0, len(s.comments))
+	for _, comment := range s.comments {
+		if purged[comment.PostID] || comment.isDeleted && comment.deletedAt.Before(before) {
+			s.index.Remove(HitComment + ":" + comment.ID)
+			result.Comments++
+			continue
+		}
+		comments = append(comments, comment)
+	}
+	s.comments = comments
+
+	revisions := make([]Revision, 0, len(s.revisions))
+	for _, revision := range s.revisions {
+		if !purged[revision.PostID] {
+			revisions = append(revisions, revision)
+		}
+	}
+	s.revisions = revisions
+	for postID := range purged {
+		delete(s.postTags, postID)
+	}
+	return result, nil
+}
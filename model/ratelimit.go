@@ -0,0 +1,56 @@
+package model
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/n-inja/go-blog/utils"
+)
+
+// Bucket is a token bucket as it was last updated. The zero Bucket is one
+// that has never been used. After ExpiresAt the bucket has refilled and can
+// be forgotten.
+type Bucket struct {
+	Tokens    float64
+	UpdatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// RateLimitStore keeps token buckets. Updates of one key are serialized,
+// so a bucket shared by several servers is never spent twice.
+type RateLimitStore interface {
+	// UpdateBucket lets update change the bucket stored under key and
+	// saves the result.
+	UpdateBucket(key string, update func(bucket *Bucket)) error
+	DeleteExpiredBuckets(now time.Time) error
+}
+
+// UpdateBucket locks the bucket row for the length of a transaction. The
+// row is created empty first, so that concurrent first requests wait for
+// each other instead of deadlocking on a gap lock.
+func (s *MySQLStore) UpdateBucket(key string, update func(bucket *Bucket)) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("insert into rate_limits (bucket_key) value(?) on duplicate key update bucket_key = bucket_key", key)
+		if err != nil {
+			return err
+		}
+		var tokens float64
+		var updatedAt, expiresAt int64
+		err = tx.QueryRow("select tokens, updated_at, expires_at from rate_limits where bucket_key = ? for update", key).Scan(&tokens, &updatedAt, &expiresAt)
+		if err != nil {
+			return err
+		}
+		var bucket Bucket
+		if updatedAt != 0 {
+			bucket = Bucket{Tokens: tokens, UpdatedAt: time.UnixMilli(updatedAt), ExpiresAt: time.UnixMilli(expiresAt)}
+		}
+		update(&bucket)
+		_, err = tx.Exec("update rate_limits set tokens = ?, updated_at = ?, expires_at = ? where bucket_key = ?", bucket.Tokens, bucket.UpdatedAt.UnixMilli(), bucket.ExpiresAt.UnixMilli(), key)
+		return err
+	})
+}
+
+func (s *MySQLStore) DeleteExpiredBuckets(now time.Time) error {
+	_, err := s.db.Exec("delete from rate_limits where expires_at < ?", now.UnixMilli())
+	return err
+}
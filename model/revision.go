@@ -0,0 +1,127 @@
+package model
+
+import (
+	"database/sql"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Revision is a post as one save left it. Revisions are numbered from 1 in
+// the order the post was saved.
+type Revision struct {
+	PostID    string `json:"postId"`
+	Number    int    `json:"number"`
+	EditorID  string `json:"editorId"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	ThumbSrc  string `json:"thumbSrc"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// RevisionStore reads the history of posts. InsertPost and UpdatePost
+// write it.
+type RevisionStore interface {
+	// GetRevisions lists the revisions of a post, newest first.
+	GetRevisions(postID string, page Page) ([]Revision, error)
+	GetRevision(postID string, number int) (Revision, error)
+}
+
+// revisionClause pages revisions by number rather than by creation time,
+// as several saves can share a second. A cursor's ID holds the number of
+// the last revision seen.
+func revisionClause(page Page) (string, []interface{}) {
+	if page.Cursor != nil {
+		number, _ := strconv.Atoi(page.Cursor.ID)
+		return " and number < ? order by number desc limit ?", []interface{}{number, page.Limit}
+	}
+	return " order by number desc limit ?, ?", []interface{}{page.Offset, page.Limit}
+}
+
+// insertRevision records post as saved by editorID. The caller must hold
+// the lock on the post row, which keeps revision numbers from colliding.
+func insertRevision(tx *sql.Tx, post *Post, editorID string) error {
+	_, err := tx.Exec("insert into post_revisions (post_id, number, editor_id, title, content, thumb_src) select ?, coalesce(max(number), 0) + 1, ?, ?, ?, ? from post_revisions where post_id = ?", post.ID, editorID, post.Title, post.Content, post.ThumbSrc, post.ID)
+	return err
+}
+
+func (s *MySQLStore) GetRevisions(postID string, page Page) ([]Revision, error) {
+	clause, pageArgs := revisionClause(page)
+	rows, err := s.reader().Query("select post_id, number, editor_id, title, content, thumb_src, created_at from post_revisions where post_id = ?"+clause, append([]interface{}{postID}, pageArgs...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	revisions := make([]Revision, 0)
+	for rows.Next() {
+		var revision Revision
+		var thumbSrc sql.NullString
+		err = rows.Scan(&revision.PostID, &revision.Number, &revision.EditorID, &revision.Title, &revision.Content, &thumbSrc, &revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		revision.ThumbSrc = thumbSrc.String
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+func (s *MySQLStore) GetRevision(postID string, number int) (Revision, error) {
+	var revision Revision
+	var thumbSrc sql.NullString
+	err := s.reader().QueryRow("select post_id, number, editor_id, title, content, thumb_src, created_at from post_revisions where post_id = ? and number = ?", postID, number).Scan(&revision.PostID, &revision.Number, &revision.EditorID, &revision.Title, &revision.Content, &thumbSrc, &revision.CreatedAt)
+	if err != nil {
+		return Revision{}, notFound(err, "revision_not_found", "revision not found")
+	}
+	revision.ThumbSrc = thumbSrc.String
+	return revision, nil
+}
+
+// insertRevision records post as saved by editorID. The caller holds s.mu.
+func (s *MemoryStore) insertRevision(post *Post, editorID string) {
+	number := 1
+	for _, revision := range s.revisions {
+		if revision.PostID == post.ID && revision.Number >= number {
+			number = revision.Number + 1
+		}
+	}
+	s.revisions = append(s.revisions, Revision{
+		PostID:    post.ID,
+		Number:    number,
+		EditorID:  editorID,
+		Title:     post.Title,
+		Content:   post.Content,
+		ThumbSrc:  post.ThumbSrc,
+		CreatedAt: time.Now().Format("2006-01-02 15:04:05"),
+	})
+}
+
+func (s *MemoryStore) GetRevisions(postID string, page Page) ([]Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	before := 0
+	if page.Cursor != nil {
+		before, _ = strconv.Atoi(page.Cursor.ID)
+	}
+	revisions := make([]Revision, 0)
+	for _, revision := range s.revisions {
+		if revision.PostID == postID && (page.Cursor == nil || revision.Number < before) {
+			revisions = append(revisions, revision)
+		}
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Number > revisions[j].Number
+	})
+	return cut(revisions, page), nil
+}
+
+func (s *MemoryStore) GetRevision(postID string, number int) (Revision, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, revision := range s.revisions {
+		if revision.PostID == postID && revision.Number == number {
+			return revision, nil
+		}
+	}
+	return Revision{}, NotFound("revision_not_found", "revision not found")
+}
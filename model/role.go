@@ -0,0 +1,71 @@
+package model
+
+import (
+	"database/sql"
+)
+
+// Role decides what a user may do; see the policy package.
+type Role string
+
+const (
+	RoleAdmin     Role = "admin"
+	RoleAuthor    Role = "author"
+	RoleCommenter Role = "commenter"
+	RoleSuspended Role = "suspended"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleAdmin || r == RoleAuthor || r == RoleCommenter || r == RoleSuspended
+}
+
+// roleOf returns the role stored in the profile, falling back to the auth
+// column of the externally managed users table.
+func roleOf(auth string, role string) Role {
+	if Role(role).Valid() {
+		return Role(role)
+	}
+	switch auth {
+	case "admin":
+		return RoleAdmin
+	case "default":
+		return RoleAuthor
+	case "suspended":
+		return RoleSuspended
+	}
+	return RoleCommenter
+}
+
+// roleColumn is roleOf in SQL, over users left joined with profiles.
+const roleColumn = "coalesce(profiles.role, case users.auth when 'admin' then 'admin' when 'default' then 'author' when 'suspended' then 'suspended' else 'commenter' end)"
+
+// hasProfile reports whether users of role are listed and keep a profile.
+func hasProfile(role Role) bool {
+	return role == RoleAuthor || role == RoleAdmin
+}
+
+func (s *MySQLStore) GetRole(ID string) (Role, error) {
+	var auth string
+	var profileID, role sql.NullString
+	err := s.db.QueryRow("select users.auth, profiles.id, profiles.role from users left join profiles on users.id = profiles.id where users.id = ?", ID).Scan(&auth, &profileID, &role)
+	if err != nil {
+		return "", notFound(err, "user_not_found", "user not found")
+	}
+	r := roleOf(auth, role.String)
+	if !profileID.Valid && (r == RoleAuthor || r == RoleAdmin) {
+		go s.checkProfile(ID)
+	}
+	return r, nil
+}
+
+func (s *MySQLStore) SetRole(ID string, role Role) error {
+	if !role.Valid() {
+		return Validation("invalid_role", "role must be admin, author, commenter or suspended", map[string]string{"role": "must be admin, author, commenter or suspended"})
+	}
+	var exists string
+	err := s.db.QueryRow("select id from users where id = ?", ID).Scan(&exists)
+	if err != nil {
+		return notFound(err, "user_not_found", "user not found")
+	}
+	_, err = s.db.Exec("insert into profiles (id, role) value(?, ?) on duplicate key update role = values(role)", ID, string(role))
+	return err
+}
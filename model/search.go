@@ -0,0 +1,187 @@
+package model
+
+import (
+	"sort"
+	"strings"
+)
+
+// SearchQuery looks for Text in the titles and content of published posts
+// and in their comments. The other fields narrow it down when set; Since
+// and Until are inclusive.
+type SearchQuery struct {
+	Text      string
+	Type      string
+	ProjectID string
+	UserID    string
+	Since     string
+	Until     string
+	Offset    int
+	Limit     int
+}
+
+const (
+	HitPost    = "post"
+	HitComment = "comment"
+)
+
+// SearchHit is a post or a comment found by a search. PostID and Title are
+// those of the post, for comments too. Content is the text the snippet is
+// cut from.
+type SearchHit struct {
+	Type      string  `json:"type"`
+	ID        string  `json:"id"`
+	PostID    string  `json:"postId"`
+	ProjectID string  `json:"projectId"`
+	UserID    string  `json:"userId"`
+	Title     string  `json:"title"`
+	Snippet   string  `json:"snippet"`
+	Score     float64 `json:"score"`
+	CreatedAt string  `json:"createdAt"`
+	Content   string  `json:"-"`
+}
+
+type SearchStore interface {
+	// Search returns the hits of query, most relevant first.
+	Search(query SearchQuery) ([]SearchHit, error)
+}
+
+// against writes the words of text as a boolean mode full-text query that
+// requires each of them. A quoted word matches its n-grams in sequence.
+func against(text string) string {
+	var terms []string
+	for _, word := range strings.Fields(text) {
+		word = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`"+-<>()~*@`, r) {
+				return -1
+			}
+			return r
+		}, word)
+		if word != "" {
+			terms = append(terms, `+"`+word+`"`)
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// where returns the conditions of query on the rows of table, posts or
+// comments, joined with their post.
+func (query SearchQuery) where(table string) (string, []interface{}) {
+	var where string
+	var args []interface{}
+	if query.ProjectID != "" {
+		where += " and posts.project_id = ?"
+		args = append(args, query.ProjectID)
+	}
+	if query.UserID != "" {
+		where += " and " + table + ".user_id = ?"
+		args = append(args, query.UserID)
+	}
+	if query.Since != "" {
+		where += " and " + table + ".created_at >= ?"
+		args = append(args, query.Since)
+	}
+	if query.Until != "" {
+		where += " and " + table + ".created_at <= ?"
+		args = append(args, query.Until)
+	}
+	return where, args
+}
+
+// Search uses the full-text indexes with the ngram parser, which cuts
+// Japanese into bigrams as well as English.
+func (s *MySQLStore) Search(query SearchQuery) ([]SearchHit, error) {
+	text := against(query.Text)
+	if text == "" {
+		return make([]SearchHit, 0), nil
+	}
+	var selects []string
+	var args []interface{}
+	if query.Type != HitComment {
+		where, whereArgs := query.where("posts")
+		selects = append(selects, "select 'post' as type, posts.id, posts.id as post_id, posts.project_id, posts.user_id, posts.title, posts.content, posts.created_at, match(posts.title, posts.content) against (? in boolean mode) as score from posts where match(posts.title, posts.content) against (? in boolean mode) and posts.is_deleted = false and posts.hidden_at is null and posts.status = 'published'"+where)
+		args = append(append(args, text, text), whereArgs...)
+	}
+	if query.Type != HitPost {
+		where, whereArgs := query.where("comments")
+		selects = append(selects, "select 'comment' as type, comments.id, comments.post_id, posts.project_id, comments.user_id, posts.title, comments.content, comments.created_at, match(comments.content) against (? in boolean mode) as score from comments join posts on posts.id = comments.post_id where match(comments.content) against (? in boolean mode) and comments.is_deleted = false and comments.hidden_at is null and posts.is_deleted = false and posts.hidden_at is null and posts.status = 'published'"+where)
+		args = append(append(args, text, text), whereArgs...)
+	}
+	rows, err := s.reader().Query(strings.Join(selects, " union all ")+" order by score desc, created_at desc limit ?, ?", append(args, query.Offset, query.Limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	hits := make([]SearchHit, 0)
+	for rows.Next() {
+		var hit SearchHit
+		err = rows.Scan(&hit.Type, &hit.ID, &hit.PostID, &hit.ProjectID, &hit.UserID, &hit.Title, &hit.Content, &hit.CreatedAt, &hit.Score)
+		if err != nil {
+			return nil, err
+		}
+		hits = append(hits, hit)
+	}
+	return hits, rows.Err()
+}
+
+// Search looks the text up in the inverted index, which holds every post
+// and comment ever saved, and leaves out those the public cannot see.
+func (s *MemoryStore) Search(query SearchQuery) ([]SearchHit, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	posts := map[string]*memoryPost{}
+	for _, post := range s.posts {
+		posts[post.ID] = post
+	}
+	comments := map[string]*memoryComment{}
+	for _, comment := range s.comments {
+		comments[comment.ID] = comment
+	}
+	visible := func(hit SearchHit) bool {
+		post := posts[hit.PostID]
+		return post != nil && post.live() && post.Published() &&
+			(query.Type == "" || query.Type == hit.Type) &&
+			(query.ProjectID == "" || query.ProjectID == hit.ProjectID) &&
+			(query.UserID == "" || query.UserID == hit.UserID) &&
+			(query.Since == "" || hit.CreatedAt >= query.Since) &&
+			(query.Until == "" || hit.CreatedAt <= query.Until)
+	}
+
+	hits := make([]SearchHit, 0)
+	for _, match := range s.index.Search(query.Text) {
+		hitType, id, _ := strings.Cut(match.ID, ":")
+		var hit SearchHit
+		switch hitType {
+		case HitPost:
+			post := posts[id]
+			if post == nil {
+				continue
+			}
+			hit = SearchHit{Type: HitPost, ID: id, PostID: id, ProjectID: post.ProjectID, UserID: post.UserID, Title: post.Title, CreatedAt: post.CreatedAt, Content: post.Content}
+		case HitComment:
+			comment := comments[id]
+			if comment == nil || !comment.live() || posts[comment.PostID] == nil {
+				continue
+			}
+			post := posts[comment.PostID]
+			hit = SearchHit{Type: HitComment, ID: id, PostID: post.ID, ProjectID: post.ProjectID, UserID: comment.UserID, Title: post.Title, CreatedAt: comment.CreatedAt, Content: comment.Content}
+		}
+		hit.Score = match.Score
+		if visible(hit) {
+			hits = append(hits, hit)
+		}
+	}
+	sort.SliceStable(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].CreatedAt > hits[j].CreatedAt
+	})
+	if query.Offset >= len(hits) {
+		return make([]SearchHit, 0), nil
+	}
+	hits = hits[query.Offset:]
+	if query.Limit < len(hits) {
+		hits = hits[:query.Limit]
+	}
+	return hits, nil
+}
@@ -0,0 +1,81 @@
+package model
+
+import (
+	"time"
+)
+
+type PostStore interface {
+	InsertPost(post *Post) error
+	DeletePost(post *Post) error
+	// InsertPost and UpdatePost also record a revision of the post.
+	UpdatePost(post *Post, editorID string) error
+	GetPosts(tags TagFilter, page Page) ([]Post, error)
+	GetUserPosts(userID string, tags TagFilter, page Page) ([]Post, error)
+	GetProjectPosts(projectID string, tags TagFilter, page Page) ([]Post, error)
+	GetDrafts(userID string, page Page) ([]Post, error)
+	GetPost(postID string) (Post, error)
+	GetProjectPostById(projectID string, postNumber int) (Post, error)
+}
+
+type ProjectStore interface {
+	InsertProject(project *Project) error
+	DeleteProject(project *Project) error
+	UpdateProject(project *Project, invites, removes []string) error
+	SetMember(projectID, userID string, role MemberRole) error
+	RemoveMember(projectID, userID string) error
+	GetProjects() ([]Project, error)
+	GetProject(ID string) (Project, error)
+	GetProjectByName(projectName string) (Project, error)
+}
+
+type UserStore interface {
+	UpdateUser(user *User) error
+	GetUsers() ([]User, error)
+	GetUser(ID string) (User, error)
+	// GetRole returns the role of an existing user.
+	GetRole(ID string) (Role, error)
+	SetRole(ID string, role Role) error
+}
+
+type CommentStore interface {
+	InsertComment(comment *Comment) error
+	DeleteComment(comment *Comment) error
+	UpdateComment(comment *Comment) error
+	GetPostComments(postID string, page Page) ([]Comment, error)
+	GetComment(commentID string) (Comment, error)
+}
+
+// Store is the persistence backend behind the handlers.
+type Store interface {
+	PostStore
+	ProjectStore
+	UserStore
+	CommentStore
+	TokenStore
+	AccountStore
+	IdentityStore
+	ModerationStore
+	AuditStore
+	PublishStore
+	RenderStore
+	RevisionStore
+	TagStore
+	SearchStore
+	PurgeStore
+	// Primary returns a view whose reads see every committed write, for
+	// requests that must not be served by a lagging replica.
+	Primary() Store
+	Ping() error
+	Close() error
+}
+
+type PurgeResult struct {
+	Comments int `json:"comments"`
+	Posts    int `json:"posts"`
+}
+
+type PurgeStore interface {
+	// PurgeDeleted hard-deletes rows soft-deleted before the given time,
+	// at most batchSize rows per statement.
+	PurgeDeleted(before time.Time, batchSize int) (PurgeResult, error)
+}
@@ -0,0 +1,391 @@
+package model
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/n-inja/go-blog/utils"
+)
+
+// TagCount is a tag with the number of published posts carrying it.
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// TagAlias makes Alias stand for Tag: posts tagged with the alias, or
+// filtered by it, get the tag instead.
+type TagAlias struct {
+	Alias string `json:"alias"`
+	Tag   string `json:"tag"`
+}
+
+// TagFilter narrows a listing of posts to those tagged with any of Tags,
+// or with all of them. Without tags it keeps every post.
+type TagFilter struct {
+	Tags []string
+	All  bool
+}
+
+// TagStore keeps the tags of posts. Tags are stored normalized, as
+// NormalizeTag leaves them.
+type TagStore interface {
+	// SetPostTags replaces the tags of a post with tags, which must be
+	// normalized and resolved.
+	SetPostTags(postID string, tags []string) error
+	// GetTags counts the published posts of each tag, most used first.
+	GetTags() ([]TagCount, error)
+	GetTag(name string) (TagCount, error)
+	// ResolveTags replaces the aliases among tags with the tags they stand
+	// for, dropping repeats.
+	ResolveTags(tags []string) ([]string, error)
+	GetTagAliases() ([]TagAlias, error)
+	// SetTagAlias makes alias stand for tag, moving the posts and aliases of
+	// alias over to tag.
+	SetTagAlias(alias, tag string) error
+	DeleteTagAlias(alias string) error
+}
+
+// NormalizeTag folds case and joins the words of a tag with hyphens, so
+// that "Go Lang", "go_lang" and "GO-LANG" are the same tag.
+func NormalizeTag(raw string) (string, error) {
+	tag := strings.Join(strings.FieldsFunc(strings.ToLower(raw), func(r rune) bool {
+		return unicode.IsSpace(r) || r == '_' || r == '-'
+	}), "-")
+	if tag == "" || utf8.RuneCountInString(tag) > 64 {
+		return "", Validation("invalid_tag", "a tag should have 1 to 64 characters", map[string]string{"tags": "must have 1 to 64 characters each"})
+	}
+	for _, r := range tag {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune("-.+#", r) {
+			return "", Validation("invalid_tag", "a tag should only have letters, digits and - . + #", map[string]string{"tags": "must only have letters, digits and - . + #"})
+		}
+	}
+	return tag, nil
+}
+
+// where adds the conditions of filter to those on posts.
+func (filter TagFilter) where(where string, args []interface{}) (string, []interface{}) {
+	if len(filter.Tags) == 0 {
+		return where, args
+	}
+	for _, tag := range filter.Tags {
+		args = append(args, tag)
+	}
+	if filter.All {
+		return where + " and id in (select post_id from post_tags where tag in " + placeholders(len(filter.Tags)) + " group by post_id having count(*) = ?)", append(args, len(filter.Tags))
+	}
+	return where + " and id in (select post_id from post_tags where tag in " + placeholders(len(filter.Tags)) + ")", args
+}
+
+func (filter TagFilter) matches(tags []string) bool {
+	if len(filter.Tags) == 0 {
+		return true
+	}
+	found := 0
+	for _, want := range filter.Tags {
+		for _, tag := range tags {
+			if tag == want {
+				found++
+				break
+			}
+		}
+	}
+	return found == len(filter.Tags) || !filter.All && found > 0
+}
+
+// unique drops the repeats among tags, keeping the first of each.
+func unique(tags []string) []string {
+	seen := map[string]bool{}
+	kept := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if !seen[tag] {
+			seen[tag] = true
+			kept = append(kept, tag)
+		}
+	}
+	return kept
+}
+
+func placeholders(n int) string {
+	return "(" + strings.TrimSuffix(strings.Repeat("?, ", n), ", ") + ")"
+}
+
+// loadTags fills in the tags of posts.
+func (s *MySQLStore) loadTags(posts []Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+	index := map[string]int{}
+	postIDs := make([]interface{}, 0, len(posts))
+	for i := range posts {
+		posts[i].Tags = make([]string, 0)
+		index[posts[i].ID] = i
+		postIDs = append(postIDs, posts[i].ID)
+	}
+	rows, err := s.reader().Query("select post_id, tag from post_tags where post_id in "+placeholders(len(postIDs))+" order by tag", postIDs...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var postID, tag string
+		err = rows.Scan(&postID, &tag)
+		if err != nil {
+			return err
+		}
+		posts[index[postID]].Tags = append(posts[index[postID]].Tags, tag)
+	}
+	return rows.Err()
+}
+
+func (s *MySQLStore) SetPostTags(postID string, tags []string) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		_, err := tx.Exec("delete from post_tags where post_id = ?", postID)
+		if err != nil {
+			return err
+		}
+		for _, tag := range tags {
+			_, err = tx.Exec("insert into post_tags (post_id, tag) values (?, ?)", postID, tag)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+const tagCounts = "select post_tags.tag, count(*) from post_tags join posts on posts.id = post_tags.post_id where posts.is_deleted = false and posts.hidden_at is null and posts.status = 'published'"
+
+func (s *MySQLStore) GetTags() ([]TagCount, error) {
+	rows, err := s.reader().Query(tagCounts + " group by post_tags.tag order by count(*) desc, post_tags.tag")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tags := make([]TagCount, 0)
+	for rows.Next() {
+		var tag TagCount
+		err = rows.Scan(&tag.Name, &tag.Count)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (s *MySQLStore) GetTag(name string) (TagCount, error) {
+	var tag TagCount
+	err := s.reader().QueryRow(tagCounts+" and post_tags.tag = ? group by post_tags.tag", name).Scan(&tag.Name, &tag.Count)
+	if err != nil {
+		return TagCount{}, notFound(err, "tag_not_found", "tag not found")
+	}
+	return tag, nil
+}
+
+func (s *MySQLStore) ResolveTags(tags []string) ([]string, error) {
+	if len(tags) == 0 {
+		return make([]string, 0), nil
+	}
+	args := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		args = append(args, tag)
+	}
+	rows, err := s.reader().Query("select alias, tag from tag_aliases where alias in "+placeholders(len(args)), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aliases := map[string]string{}
+	for rows.Next() {
+		var alias, tag string
+		err = rows.Scan(&alias, &tag)
+		if err != nil {
+			return nil, err
+		}
+		aliases[alias] = tag
+	}
+	resolved := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if aliases[tag] != "" {
+			tag = aliases[tag]
+		}
+		resolved = append(resolved, tag)
+	}
+	return unique(resolved), rows.Err()
+}
+
+func (s *MySQLStore) GetTagAliases() ([]TagAlias, error) {
+	rows, err := s.reader().Query("select alias, tag from tag_aliases order by alias")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	aliases := make([]TagAlias, 0)
+	for rows.Next() {
+		var alias TagAlias
+		err = rows.Scan(&alias.Alias, &alias.Tag)
+		if err != nil {
+			return nil, err
+		}
+		aliases = append(aliases, alias)
+	}
+	return aliases, rows.Err()
+}
+
+// SetTagAlias keeps aliases one step deep: an alias of an alias is stored
+// as an alias of the tag, and the aliases of a tag that becomes an alias
+// move on with its posts.
+func (s *MySQLStore) SetTagAlias(alias, tag string) error {
+	return utils.Transact(s.db, func(tx *sql.Tx) error {
+		var target string
+		err := tx.QueryRow("select tag from tag_aliases where alias = ? for update", tag).Scan(&target)
+		if err == nil {
+			tag = target
+		} else if err != sql.ErrNoRows {
+			return err
+		}
+		if tag == alias {
+			return Validation("invalid_alias", "a tag cannot be an alias of itself", map[string]string{"tag": "is the alias"})
+		}
+		_, err = tx.Exec("insert into tag_aliases (alias, tag) values (?, ?) on duplicate key update tag = values(tag)", alias, tag)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("update tag_aliases set tag = ? where tag = ?", tag, alias)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("insert ignore into post_tags (post_id, tag) select post_id, ? from post_tags where tag = ?", tag, alias)
+		if err != nil {
+			return err
+		}
+		_, err = tx.Exec("delete from post_tags where tag = ?", alias)
+		return err
+	})
+}
+
+func (s *MySQLStore) DeleteTagAlias(alias string) error {
+	result, err := s.db.Exec("delete from tag_aliases where alias = ?", alias)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return NotFound("alias_not_found", "tag alias not found")
+	}
+	return nil
+}
+
+func (s *MemoryStore) SetPostTags(postID string, tags []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tags = append(make([]string, 0, len(tags)), tags...)
+	sort.Strings(tags)
+	s.postTags[postID] = tags
+	return nil
+}
+
+func (s *MemoryStore) GetTags() ([]TagCount, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	counts := map[string]int{}
+	for _, post := range s.posts {
+		if post.live() && post.Published() {
+			for _, tag := range s.postTags[post.ID] {
+				counts[tag]++
+			}
+		}
+	}
+	tags := make([]TagCount, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, TagCount{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+func (s *MemoryStore) GetTag(name string) (TagCount, error) {
+	tags, _ := s.GetTags()
+	for _, tag := range tags {
+		if tag.Name == name {
+			return tag, nil
+		}
+	}
+	return TagCount{}, NotFound("tag_not_found", "tag not found")
+}
+
+func (s *MemoryStore) ResolveTags(tags []string) ([]string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	resolved := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		if target, ok := s.tagAliases[tag]; ok {
+			tag = target
+		}
+		resolved = append(resolved, tag)
+	}
+	return unique(resolved), nil
+}
+
+func (s *MemoryStore) GetTagAliases() ([]TagAlias, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	aliases := make([]TagAlias, 0, len(s.tagAliases))
+	for alias, tag := range s.tagAliases {
+		aliases = append(aliases, TagAlias{Alias: alias, Tag: tag})
+	}
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Alias < aliases[j].Alias
+	})
+	return aliases, nil
+}
+
+func (s *MemoryStore) SetTagAlias(alias, tag string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if target, ok := s.tagAliases[tag]; ok {
+		tag = target
+	}
+	if tag == alias {
+		return Validation("invalid_alias", "a tag cannot be an alias of itself", map[string]string{"tag": "is the alias"})
+	}
+	s.tagAliases[alias] = tag
+	for other, target := range s.tagAliases {
+		if target == alias {
+			s.tagAliases[other] = tag
+		}
+	}
+	for postID, tags := range s.postTags {
+		for i := range tags {
+			if tags[i] == alias {
+				tags[i] = tag
+			}
+		}
+		tags = unique(tags)
+		sort.Strings(tags)
+		s.postTags[postID] = tags
+	}
+	return nil
+}
+
+func (s *MemoryStore) DeleteTagAlias(alias string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tagAliases[alias]; !ok {
+		return NotFound("alias_not_found", "tag alias not found")
+	}
+	delete(s.tagAliases, alias)
+	return nil
+}
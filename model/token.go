@@ -0,0 +1,161 @@
+package model
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Token is a personal access token. Only the hash of the secret is stored.
+// Times are formatted like the other timestamps and empty when unset.
+type Token struct {
+	ID         string   `json:"id"`
+	UserID     string   `json:"userId"`
+	Name       string   `json:"name"`
+	Scopes     []string `json:"scopes"`
+	CreatedAt  string   `json:"createdAt"`
+	ExpiresAt  string   `json:"expiresAt"`
+	LastUsedAt string   `json:"lastUsedAt"`
+	RevokedAt  string   `json:"revokedAt,omitempty"`
+	Hash       string   `json:"-"`
+}
+
+type TokenStore interface {
+	InsertToken(token *Token) error
+	// GetTokens lists the tokens of a user that have not been revoked.
+	GetTokens(userID string) ([]Token, error)
+	// GetActiveToken finds an unrevoked token by hash that has not expired at now.
+	GetActiveToken(hash string, now string) (Token, error)
+	RevokeToken(userID, ID string) error
+	TouchToken(ID string, at string) error
+}
+
+func (s *MySQLStore) InsertToken(token *Token) error {
+	var expiresAt interface{}
+	if token.ExpiresAt != "" {
+		expiresAt = token.ExpiresAt
+	}
+	_, err := s.db.Exec("insert into api_tokens (id, user_id, name, token_hash, scopes, created_at, expires_at) value(?, ?, ?, ?, ?, ?, ?)", token.ID, token.UserID, token.Name, token.Hash, strings.Join(token.Scopes, ","), token.CreatedAt, expiresAt)
+	return conflict(err, "token_exists", "token already exists")
+}
+
+func (s *MySQLStore) GetTokens(userID string) ([]Token, error) {
+	rows, err := s.db.Query("select id, user_id, name, token_hash, scopes, created_at, expires_at, last_used_at from api_tokens where user_id = ? and revoked_at is null order by created_at desc, id desc", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	tokens := make([]Token, 0)
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+func (s *MySQLStore) GetActiveToken(hash string, now string) (Token, error) {
+	row := s.db.QueryRow("select id, user_id, name, token_hash, scopes, created_at, expires_at, last_used_at from api_tokens where token_hash = ? and revoked_at is null and (expires_at is null or expires_at > ?)", hash, now)
+	token, err := scanToken(row)
+	if err != nil {
+		return Token{}, notFound(err, "token_not_found", "token not found")
+	}
+	return token, nil
+}
+
+func (s *MySQLStore) RevokeToken(userID, ID string) error {
+	result, err := s.db.Exec("update api_tokens set revoked_at = current_timestamp where id = ? and user_id = ? and revoked_at is null", ID, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return NotFound("token_not_found", "token not found")
+	}
+	return nil
+}
+
+func (s *MySQLStore) TouchToken(ID string, at string) error {
+	_, err := s.db.Exec("update api_tokens set last_used_at = ? where id = ?", at, ID)
+	return err
+}
+
+func scanToken(row interface{ Scan(...interface{}) error }) (Token, error) {
+	var token Token
+	var scopes string
+	var expiresAt, lastUsedAt sql.NullString
+	err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Hash, &scopes, &token.CreatedAt, &expiresAt, &lastUsedAt)
+	if err != nil {
+		return Token{}, err
+	}
+	token.Scopes = strings.Split(scopes, ",")
+	token.ExpiresAt = expiresAt.String
+	token.LastUsedAt = lastUsedAt.String
+	return token, nil
+}
+
+type memoryToken struct {
+	Token
+	revoked bool
+}
+
+func (s *MemoryStore) InsertToken(token *Token) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.ID == token.ID || t.Hash == token.Hash {
+			return Conflict("token_exists", "token already exists")
+		}
+	}
+	t := *token
+	t.Scopes = append(make([]string, 0), token.Scopes...)
+	s.tokens = append(s.tokens, &memoryToken{Token: t})
+	return nil
+}
+
+func (s *MemoryStore) GetTokens(userID string) ([]Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	tokens := make([]Token, 0)
+	for i := len(s.tokens) - 1; i >= 0; i-- {
+		t := s.tokens[i]
+		if t.UserID == userID && !t.revoked {
+			tokens = append(tokens, t.Token)
+		}
+	}
+	return tokens, nil
+}
+
+func (s *MemoryStore) GetActiveToken(hash string, now string) (Token, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, t := range s.tokens {
+		if t.Hash == hash && !t.revoked && (t.ExpiresAt == "" || t.ExpiresAt > now) {
+			return t.Token, nil
+		}
+	}
+	return Token{}, NotFound("token_not_found", "token not found")
+}
+
+func (s *MemoryStore) RevokeToken(userID, ID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.ID == ID && t.UserID == userID && !t.revoked {
+			t.revoked = true
+			return nil
+		}
+	}
+	return NotFound("token_not_found", "token not found")
+}
+
+func (s *MemoryStore) TouchToken(ID string, at string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tokens {
+		if t.ID == ID {
+			t.LastUsedAt = at
+		}
+	}
+	return nil
+}
@@ -2,9 +2,6 @@ package model
 
 import (
 	"database/sql"
-	"errors"
-
-	"github.com/n-inja/go-blog/utils"
 )
 
 type User struct {
@@ -18,13 +15,14 @@ type User struct {
 	GithubId    string   `json:"githubId" form:"githubId"`
 }
 
-func (user *User) Update() error {
-	_, err := utils.DB.Exec("update profiles set description = ?, twitter_id = ?, github_id = ?, icon_src = ? where id = ?", user.Description, user.TwitterId, user.GithubId, user.IconSrc, user.ID)
+func (s *MySQLStore) UpdateUser(user *User) error {
+	_, err := s.db.Exec("update profiles set description = ?, twitter_id = ?, github_id = ?, icon_src = ? where id = ?", user.Description, user.TwitterId, user.GithubId, user.IconSrc, user.ID)
 	return err
 }
 
-func GetUsers() ([]User, error) {
-	rows, err := utils.DB.Query("select user_id, project_id from member")
+func (s *MySQLStore) GetUsers() ([]User, error) {
+	db := s.reader()
+	rows, err := db.Query("select user_id, project_id from member")
 	if err != nil {
 		return nil, err
 	}
@@ -40,7 +38,7 @@ func GetUsers() ([]User, error) {
 	}
 	rows.Close()
 
-	rows, err = utils.DB.Query("select name, users.id, description, auth, icon_src, twitter_id, github_id from users left join profiles on users.id = profiles.id where auth = 'default' order by id desc")
+	rows, err = db.Query("select name, users.id, description, auth, icon_src, twitter_id, github_id from users left join profiles on users.id = profiles.id where " + roleColumn + " in ('author', 'admin') order by id desc")
 	if err != nil {
 		return nil, err
 	}
@@ -78,13 +76,14 @@ func GetUsers() ([]User, error) {
 	return users, nil
 }
 
-func GetUser(ID string) (User, error) {
-	rows, err := utils.DB.Query("select name, users.id, description, auth, icon_src, twitter_id, github_id from users left join profiles on users.id = profiles.id where users.id = ? and auth = 'default'", ID)
+func (s *MySQLStore) GetUser(ID string) (User, error) {
+	db := s.reader()
+	rows, err := db.Query("select name, users.id, description, auth, icon_src, twitter_id, github_id from users left join profiles on users.id = profiles.id where users.id = ? and "+roleColumn+" in ('author', 'admin')", ID)
 	if err != nil {
 		return User{}, err
 	}
 	if !rows.Next() {
-		return User{}, errors.New("user not found")
+		return User{}, NotFound("user_not_found", "user not found")
 	}
 	var user User
 	var description, iconSrc, twitterId, githubId sql.NullString
@@ -108,7 +107,7 @@ func GetUser(ID string) (User, error) {
 		user.GithubId = githubId.String
 	}
 
-	rows, err = utils.DB.Query("select project_id from member where user_id = ?", ID)
+	rows, err = db.Query("select project_id from member where user_id = ?", ID)
 	if err != nil {
 		return user, err
 	}
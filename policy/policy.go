@@ -0,0 +1,149 @@
+package policy
+
+import (
+	"github.com/n-inja/go-blog/model"
+)
+
+// Action is something a user asks to do to a resource.
+type Action string
+
+const (
+	CreateProject Action = "project.create"
+	UpdateProject Action = "project.update"
+	DeleteProject Action = "project.delete"
+	ManageMembers Action = "project.members"
+	CreatePost    Action = "post.create"
+	// ReadPost is needed only for posts that are not published yet.
+	ReadPost      Action = "post.read"
+	UpdatePost    Action = "post.update"
+	DeletePost    Action = "post.delete"
+	CreateComment Action = "comment.create"
+	DeleteComment Action = "comment.delete"
+	UpdateProfile Action = "profile.update"
+	ManageTokens  Action = "tokens.manage"
+	// Moderate covers the admin API: hiding, restoring, suspending and
+	// transferring projects.
+	Moderate Action = "moderate"
+)
+
+// Scopes a personal access token can be limited to.
+const (
+	ScopePostsWrite    = "posts:write"
+	ScopeCommentsWrite = "comments:write"
+	ScopeProjectsAdmin = "projects:admin"
+)
+
+// scopes maps each action to the scope a token needs for it. Actions not
+// listed cannot be performed with a token at all.
+var scopes = map[Action]string{
+	CreatePost:    ScopePostsWrite,
+	ReadPost:      ScopePostsWrite,
+	UpdatePost:    ScopePostsWrite,
+	DeletePost:    ScopePostsWrite,
+	CreateComment: ScopeCommentsWrite,
+	DeleteComment: ScopeCommentsWrite,
+	CreateProject: ScopeProjectsAdmin,
+	UpdateProject: ScopeProjectsAdmin,
+	DeleteProject: ScopeProjectsAdmin,
+	ManageMembers: ScopeProjectsAdmin,
+}
+
+func ValidScope(scope string) bool {
+	return scope == ScopePostsWrite || scope == ScopeCommentsWrite || scope == ScopeProjectsAdmin
+}
+
+// Subject is the user asking. An empty UserID is an anonymous request.
+// Scopes is nil for full access and otherwise lists what a token allows.
+type Subject struct {
+	UserID string
+	Role   model.Role
+	Scopes []string
+}
+
+// Resource is what an action applies to. Set the fields the action needs:
+// the project for project actions, the post and its project for post
+// actions, the comment for comment actions. Member roles are read from the
+// project.
+type Resource struct {
+	Project *model.Project
+	Post    *model.Post
+	Comment *model.Comment
+}
+
+// Authorize returns nil if subject may perform action on resource, and an
+// Unauthorized or Forbidden model error otherwise.
+func Authorize(subject Subject, action Action, resource Resource) error {
+	if action == ReadPost && resource.Post != nil && resource.Post.Published() {
+		return nil
+	}
+	if subject.UserID == "" {
+		return model.Unauthorized("unauthorized", "sign in to do this")
+	}
+	if subject.Role == model.RoleSuspended {
+		return model.Forbidden("suspended", "your account is suspended")
+	}
+	if subject.Scopes != nil && !hasScope(subject.Scopes, scopes[action]) {
+		return model.Forbidden("insufficient_scope", "the token does not allow "+string(action))
+	}
+	if subject.Role == model.RoleAdmin {
+		return nil
+	}
+
+	switch action {
+	case CreateProject:
+		if subject.Role == model.RoleAuthor {
+			return nil
+		}
+		return model.Forbidden("forbidden", "only authors can create projects")
+	case UpdateProject, DeleteProject, ManageMembers:
+		if resource.Project != nil && resource.Project.UserID == subject.UserID {
+			return nil
+		}
+		return model.Forbidden("forbidden", "only the project owner can change the project")
+	case CreatePost:
+		if subject.Role != model.RoleAuthor {
+			return model.Forbidden("forbidden", "only authors can write posts")
+		}
+		if memberRole(subject, resource).AtLeast(model.MemberWriter) {
+			return nil
+		}
+		return model.Forbidden("not_a_writer", "only writers of the project can post to it")
+	case ReadPost, UpdatePost, DeletePost:
+		if resource.Post != nil && resource.Post.UserID == subject.UserID {
+			return nil
+		}
+		if memberRole(subject, resource).AtLeast(model.MemberEditor) {
+			return nil
+		}
+		return model.Forbidden("forbidden", "only the author or a project editor can change this post")
+	case CreateComment, UpdateProfile, ManageTokens:
+		return nil
+	case DeleteComment:
+		if resource.Comment != nil && resource.Comment.UserID == subject.UserID {
+			return nil
+		}
+		return model.Forbidden("forbidden", "only the author can delete this comment")
+	case Moderate:
+		return model.Forbidden("forbidden", "only admins can moderate")
+	}
+	return model.Forbidden("forbidden", "unknown action "+string(action))
+}
+
+func memberRole(subject Subject, resource Resource) model.MemberRole {
+	if resource.Project == nil {
+		return ""
+	}
+	return resource.Project.Roles[subject.UserID]
+}
+
+func hasScope(granted []string, scope string) bool {
+	if scope == "" {
+		return false
+	}
+	for _, g := range granted {
+		if g == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package policy
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/n-inja/go-blog/model"
+)
+
+func TestAuthorize(t *testing.T) {
+	project := &model.Project{ID: "p", UserID: "owner", Roles: map[string]model.MemberRole{
+		"owner":  model.MemberOwner,
+		"editor": model.MemberEditor,
+		"writer": model.MemberWriter,
+		"viewer": model.MemberViewer,
+	}}
+	post := &model.Post{ID: "x", UserID: "writer", ProjectID: "p"}
+	comment := &model.Comment{ID: "c", UserID: "reader", PostID: "x"}
+	author := func(ID string) Subject { return Subject{UserID: ID, Role: model.RoleAuthor} }
+
+	tests := []struct {
+		subject  Subject
+		action   Action
+		resource Resource
+		want     error
+	}{
+		{Subject{}, CreateComment, Resource{}, model.ErrUnauthorized},
+		{Subject{UserID: "x", Role: model.RoleSuspended}, CreateComment, Resource{}, model.ErrForbidden},
+		{Subject{UserID: "reader", Role: model.RoleCommenter}, CreateComment, Resource{}, nil},
+		{Subject{UserID: "reader", Role: model.RoleCommenter}, CreatePost, Resource{Project: project}, model.ErrForbidden},
+		{Subject{UserID: "reader", Role: model.RoleCommenter}, CreateProject, Resource{}, model.ErrForbidden},
+		{author("writer"), CreatePost, Resource{Project: project}, nil},
+		{author("writer"), CreateProject, Resource{}, nil},
+		{author("owner"), UpdateProject, Resource{Project: project}, nil},
+		{author("writer"), UpdateProject, Resource{Project: project}, model.ErrForbidden},
+		{author("writer"), DeleteProject, Resource{Project: project}, model.ErrForbidden},
+		{author("writer"), UpdatePost, Resource{Post: post}, nil},
+		{author("owner"), UpdatePost, Resource{Project: project, Post: post}, nil},
+		{author("writer"), UpdatePost, Resource{Post: &model.Post{UserID: "someone"}, Project: project}, model.ErrForbidden},
+		{author("owner"), DeletePost, Resource{Project: project, Post: post}, nil},
+		{author("writer"), DeletePost, Resource{Project: project, Post: post}, nil},
+		{author("other"), DeletePost, Resource{Project: project, Post: post}, model.ErrForbidden},
+		{author("editor"), DeletePost, Resource{Project: project, Post: post}, nil},
+		{author("editor"), UpdatePost, Resource{Project: project, Post: post}, nil},
+		{author("viewer"), UpdatePost, Resource{Project: project, Post: post}, model.ErrForbidden},
+		{author("viewer"), CreatePost, Resource{Project: project}, model.ErrForbidden},
+		{author("other"), CreatePost, Resource{Project: project}, model.ErrForbidden},
+		{author("editor"), ManageMembers, Resource{Project: project}, model.ErrForbidden},
+		{author("owner"), ManageMembers, Resource{Project: project}, nil},
+		{Subject{UserID: "reader", Role: model.RoleCommenter}, DeleteComment, Resource{Comment: comment}, nil},
+		{author("writer"), DeleteComment, Resource{Comment: comment}, model.ErrForbidden},
+		{Subject{UserID: "root", Role: model.RoleAdmin}, DeleteProject, Resource{Project: project}, nil},
+		{Subject{UserID: "root", Role: model.RoleAdmin}, DeleteComment, Resource{Comment: comment}, nil},
+		{author("owner"), Action("project.rename"), Resource{Project: project}, model.ErrForbidden},
+		{Subject{UserID: "writer", Role: model.RoleAuthor, Scopes: []string{ScopePostsWrite}}, CreatePost, Resource{Project: project}, nil},
+		{Subject{UserID: "writer", Role: model.RoleAuthor, Scopes: []string{ScopePostsWrite}}, CreateComment, Resource{}, model.ErrForbidden},
+		{Subject{UserID: "owner", Role: model.RoleAuthor, Scopes: []string{ScopeCommentsWrite}}, DeleteProject, Resource{Project: project}, model.ErrForbidden},
+		{Subject{UserID: "owner", Role: model.RoleAuthor, Scopes: []string{ScopeProjectsAdmin}}, ManageMembers, Resource{Project: project}, nil},
+		{Subject{UserID: "root", Role: model.RoleAdmin, Scopes: []string{ScopePostsWrite}}, ManageTokens, Resource{}, model.ErrForbidden},
+		{Subject{UserID: "root", Role: model.RoleAdmin}, Moderate, Resource{}, nil},
+		{Subject{}, ReadPost, Resource{Post: &model.Post{Status: model.PostPublished}}, nil},
+		{Subject{}, ReadPost, Resource{Project: project, Post: post}, model.ErrUnauthorized},
+		{author("writer"), ReadPost, Resource{Project: project, Post: post}, nil},
+		{author("editor"), ReadPost, Resource{Project: project, Post: post}, nil},
+		{author("viewer"), ReadPost, Resource{Project: project, Post: post}, model.ErrForbidden},
+		{author("owner"), Moderate, Resource{}, model.ErrForbidden},
+		{Subject{UserID: "root", Role: model.RoleAdmin, Scopes: []string{ScopeProjectsAdmin}}, Moderate, Resource{}, model.ErrForbidden},
+	}
+	for _, test := range tests {
+		err := Authorize(test.subject, test.action, test.resource)
+		if test.want == nil && err != nil || test.want != nil && !errors.Is(err, test.want) {
+			t.Errorf("Authorize(%+v, %s) = %v, want %v", test.subject, test.action, err, test.want)
+		}
+	}
+}
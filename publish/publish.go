@@ -0,0 +1,39 @@
+package publish
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/utils"
+)
+
+// Worker publishes scheduled posts whose time has come. Several servers
+// may run one at once; a post is only ever published once.
+type Worker struct {
+	store  model.PublishStore
+	config config.Publish
+}
+
+func NewWorker(store model.PublishStore, c config.Publish) *Worker {
+	return &Worker{store: store, config: c}
+}
+
+// Once publishes the posts that are due now.
+func (w *Worker) Once() (int64, error) {
+	return w.store.PublishDue(time.Now())
+}
+
+// Run publishes due posts every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	utils.Every(ctx, w.config.Interval, func() {
+		n, err := w.Once()
+		if err != nil {
+			log.Println("publish:", err)
+		} else if n > 0 {
+			log.Printf("publish: published %d scheduled posts", n)
+		}
+	})
+}
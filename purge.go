@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/purge"
+)
+
+func runPurge(args []string) {
+	c, _, err := config.Load("purge", args)
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = c.Database.Validate()
+	if err == nil {
+		err = c.Purge.Validate()
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	store, err := openStore(c)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer store.Close()
+
+	result, err := purge.NewWorker(store, c.Purge).Once()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("removed %d posts and %d comments deleted more than %s ago\n", result.Posts, result.Comments, c.Purge.Retention)
+}
@@ -0,0 +1,39 @@
+package purge
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+	"github.com/n-inja/go-blog/utils"
+)
+
+// Worker hard-deletes posts and comments once they have been soft-deleted
+// for longer than the retention window.
+type Worker struct {
+	store  model.PurgeStore
+	config config.Purge
+}
+
+func NewWorker(store model.PurgeStore, c config.Purge) *Worker {
+	return &Worker{store: store, config: c}
+}
+
+// Once runs a single purge pass.
+func (w *Worker) Once() (model.PurgeResult, error) {
+	return w.store.PurgeDeleted(time.Now().Add(-w.config.Retention), w.config.BatchSize)
+}
+
+// Run purges every interval until ctx is cancelled.
+func (w *Worker) Run(ctx context.Context) {
+	utils.Every(ctx, w.config.Interval, func() {
+		result, err := w.Once()
+		if err != nil {
+			log.Println("purge:", err)
+		} else if result.Posts > 0 || result.Comments > 0 {
+			log.Printf("purge: removed %d posts and %d comments", result.Posts, result.Comments)
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/n-inja/go-blog/config"
+	"github.com/n-inja/go-blog/model"
+)
+
+// sweepInterval is how often buckets that have refilled are removed.
+const sweepInterval = 10 * time.Minute
+
+// Result describes a bucket after a request has tried to take from it.
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until the next request is allowed, 0 when
+	// this one was.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Limiter takes tokens from buckets kept in a model.RateLimitStore.
+type Limiter struct {
+	store model.RateLimitStore
+	now   func() time.Time
+
+	mu    sync.Mutex
+	swept time.Time
+}
+
+func New(store model.RateLimitStore) *Limiter {
+	return &Limiter{store: store, now: time.Now}
+}
+
+// Take spends one token from the bucket under key, which refills at the
+// pace of limit.
+func (l *Limiter) Take(key string, limit config.Limit) (Result, error) {
+	now := l.now()
+	l.sweep(now)
+	result := Result{Limit: limit.Burst}
+	err := l.store.UpdateBucket(key, func(bucket *model.Bucket) {
+		burst := float64(limit.Burst)
+		tokens := refill(*bucket, limit, now)
+		if tokens >= 1 {
+			tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = time.Duration((1 - tokens) * float64(limit.Every))
+		}
+		result.Remaining = int(tokens)
+		result.Reset = time.Duration((burst - tokens) * float64(limit.Every))
+		*bucket = model.Bucket{Tokens: tokens, UpdatedAt: now, ExpiresAt: now.Add(result.Reset)}
+	})
+	return result, err
+}
+
+// Give puts back a token taken from the bucket under key, for a request
+// that another bucket refused.
+func (l *Limiter) Give(key string, limit config.Limit) error {
+	now := l.now()
+	return l.store.UpdateBucket(key, func(bucket *model.Bucket) {
+		burst := float64(limit.Burst)
+		tokens := math.Min(burst, refill(*bucket, limit, now)+1)
+		*bucket = model.Bucket{Tokens: tokens, UpdatedAt: now, ExpiresAt: now.Add(time.Duration((burst - tokens) * float64(limit.Every)))}
+	})
+}
+
+// refill returns the tokens in bucket at now, a never used bucket being
+// full.
+func refill(bucket model.Bucket, limit config.Limit, now time.Time) float64 {
+	burst := float64(limit.Burst)
+	if bucket.UpdatedAt.IsZero() {
+		return burst
+	}
+	return math.Min(burst, bucket.Tokens+float64(now.Sub(bucket.UpdatedAt))/float64(limit.Every))
+}
+
+// sweep removes refilled buckets in the background, at most once per
+// sweepInterval.
+func (l *Limiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.Sub(l.swept) < sweepInterval {
+		return
+	}
+	l.swept = now
+	go func() {
+		err := l.store.DeleteExpiredBuckets(now)
+		if err != nil {
+			log.Println("ratelimit:", err)
+		}
+	}()
+}
+
+// Memory keeps buckets in process memory, so each server counts on its own.
+type Memory struct {
+	mu      sync.Mutex
+	buckets map[string]model.Bucket
+}
+
+func NewMemory() *Memory {
+	return &Memory{buckets: map[string]model.Bucket{}}
+}
+
+func (m *Memory) UpdateBucket(key string, update func(bucket *model.Bucket)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	bucket := m.buckets[key]
+	update(&bucket)
+	m.buckets[key] = bucket
+	return nil
+}
+
+func (m *Memory) DeleteExpiredBuckets(now time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, bucket := range m.buckets {
+		if bucket.ExpiresAt.Before(now) {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
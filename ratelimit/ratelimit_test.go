@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/n-inja/go-blog/config"
+)
+
+func TestTake(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	memory := NewMemory()
+	l := New(memory)
+	l.now = func() time.Time { return now }
+	limit := config.Limit{Burst: 3, Every: time.Minute}
+
+	tests := []struct {
+		wait       time.Duration
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+		reset      time.Duration
+	}{
+		{0, true, 2, 0, time.Minute},
+		{0, true, 1, 0, 2 * time.Minute},
+		{0, true, 0, 0, 3 * time.Minute},
+		{0, false, 0, time.Minute, 3 * time.Minute},
+		{30 * time.Second, false, 0, 30 * time.Second, 150 * time.Second},
+		{30 * time.Second, true, 0, 0, 3 * time.Minute},
+		{10 * time.Minute, true, 2, 0, time.Minute},
+	}
+	for i, test := range tests {
+		now = now.Add(test.wait)
+		result, err := l.Take("comments:user:alice", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := Result{Allowed: test.allowed, Limit: 3, Remaining: test.remaining, RetryAfter: test.retryAfter, Reset: test.reset}
+		if result != want {
+			t.Errorf("%d: Take = %+v, want %+v", i, result, want)
+		}
+	}
+
+	l.Take("comments:user:bob", limit)
+	result, _ := l.Take("comments:user:bob", limit)
+	if !result.Allowed || result.Remaining != 1 {
+		t.Errorf("bob's bucket = %+v, want it separate from alice's", result)
+	}
+
+	// a token given back is there for the next request, up to the burst
+	l.Give("comments:user:bob", limit)
+	l.Give("comments:user:bob", limit)
+	l.Give("comments:user:bob", limit)
+	result, _ = l.Take("comments:user:bob", limit)
+	if !result.Allowed || result.Remaining != 2 {
+		t.Errorf("bob's bucket after Give = %+v, want it full before the take", result)
+	}
+	l.Take("comments:user:bob", limit)
+
+	memory.DeleteExpiredBuckets(now.Add(90 * time.Second))
+	if _, ok := memory.buckets["comments:user:alice"]; ok {
+		t.Error("alice's refilled bucket was kept")
+	}
+	if _, ok := memory.buckets["comments:user:bob"]; !ok {
+		t.Error("bob's bucket was removed before it refilled")
+	}
+}
@@ -0,0 +1,132 @@
+package render
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/n-inja/go-blog/config"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// version changes whenever the same configuration starts rendering
+// differently, so that HTML cached by an older build is rendered again.
+const version = "1"
+
+// extensions are the GFM extensions a markdown configuration can enable,
+// by the name it lists them under.
+var extensions = map[string]goldmark.Extender{
+	"table":         extension.Table,
+	"strikethrough": extension.Strikethrough,
+	"linkify":       extension.Linkify,
+	"tasklist":      extension.TaskList,
+	"footnote":      extension.Footnote,
+}
+
+// Renderer turns post content into HTML that is safe to embed in a page.
+// It is safe for concurrent use.
+type Renderer struct {
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+	key      string
+}
+
+// New builds the pipeline for c. The configuration has been validated
+// except for the extensions, which only this package knows.
+func New(c config.Render) (*Renderer, error) {
+	r := &Renderer{policy: policy(c.Sanitize)}
+	if c.Format == "markdown" {
+		var options []renderer.Option
+		if c.HardWraps {
+			options = append(options, goldmarkhtml.WithHardWraps())
+		}
+		if c.RawHTML {
+			// the sanitizer still has the last word
+			options = append(options, goldmarkhtml.WithUnsafe())
+		}
+		var enabled []goldmark.Extender
+		for _, name := range c.Extensions {
+			extension, ok := extensions[name]
+			if !ok {
+				return nil, fmt.Errorf("render extension %q must be one of %s (render.extensions, RENDER_EXTENSIONS, -render-extensions)", name, strings.Join(extensionNames(), ", "))
+			}
+			enabled = append(enabled, extension)
+		}
+		r.markdown = goldmark.New(goldmark.WithExtensions(enabled...), goldmark.WithRendererOptions(options...))
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s %s %v %t %t %s", version, c.Format, c.Extensions, c.HardWraps, c.RawHTML, c.Sanitize)))
+	r.key = hex.EncodeToString(sum[:8])
+	return r, nil
+}
+
+func extensionNames() []string {
+	names := make([]string, 0, len(extensions))
+	for name := range extensions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Key names the configuration of the pipeline. HTML rendered under another
+// key is out of date.
+func (r *Renderer) Key() string {
+	return r.key
+}
+
+// Render returns the sanitized HTML of content.
+func (r *Renderer) Render(content string) (string, error) {
+	if r.markdown == nil {
+		return r.policy.Sanitize(text(content)), nil
+	}
+	var buf bytes.Buffer
+	err := r.markdown.Convert([]byte(content), &buf)
+	if err != nil {
+		return "", err
+	}
+	return r.policy.Sanitize(buf.String()), nil
+}
+
+// text lays plain content out as paragraphs, split at blank lines, keeping
+// its line breaks.
+func text(content string) string {
+	var b strings.Builder
+	for _, paragraph := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n\n") {
+		paragraph = strings.Trim(paragraph, "\n")
+		if paragraph == "" {
+			continue
+		}
+		b.WriteString("<p>")
+		b.WriteString(strings.ReplaceAll(html.EscapeString(paragraph), "\n", "<br>\n"))
+		b.WriteString("</p>\n")
+	}
+	return b.String()
+}
+
+var (
+	codeLanguage = regexp.MustCompile(`^language-[\w+#-]+$`)
+	checkbox     = regexp.MustCompile(`^checkbox$`)
+)
+
+// policy builds the allowlist named by the configuration. ugc extends
+// bluemonday's policy for user content with what the markdown renderer
+// emits: code block languages and task list checkboxes.
+func policy(name string) *bluemonday.Policy {
+	if name == "strict" {
+		return bluemonday.StrictPolicy()
+	}
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("class").Matching(codeLanguage).OnElements("code")
+	p.AllowAttrs("type").Matching(checkbox).OnElements("input")
+	p.AllowAttrs("checked", "disabled").OnElements("input")
+	return p
+}
@@ -0,0 +1,82 @@
+package render
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/n-inja/go-blog/config"
+)
+
+func TestRender(t *testing.T) {
+	markdown := config.Default().Render
+	raw := markdown
+	raw.RawHTML = true
+	strict := markdown
+	strict.Sanitize = "strict"
+	text := markdown
+	text.Format = "text"
+
+	tests := []struct {
+		name    string
+		config  config.Render
+		content string
+		want    []string
+		notWant []string
+	}{
+		{"markdown", markdown, "# Title\n\n*hi* ~~no~~", []string{"<h1>Title</h1>", "<em>hi</em>", "<del>no</del>"}, nil},
+		{"table", markdown, "| a |\n|---|\n| b |", []string{"<table>", "<td>b</td>"}, nil},
+		{"tasks", markdown, "- [x] done", []string{`<input checked="" disabled="" type="checkbox"`}, nil},
+		{"code", markdown, "```go\nx := 1\n```", []string{`<code class="language-go">x := 1`}, nil},
+		{"links", markdown, "see https://example.com", []string{`<a href="https://example.com" rel="nofollow">`}, nil},
+		{"raw html dropped", markdown, "<b>bold</b>", nil, []string{"<b>"}},
+		{"script", raw, "<script>alert(1)</script><b onclick=\"x()\">bold</b>", []string{"<b>bold</b>"}, []string{"script", "onclick"}},
+		{"javascript link", markdown, "[x](javascript:alert(1))", nil, []string{"javascript"}},
+		{"strict", strict, "# Title", []string{"Title"}, []string{"<h1>"}},
+		{"text", text, "a <b>\nb\n\nc", []string{"<p>a &lt;b&gt;<br>\nb</p>", "<p>c</p>"}, nil},
+	}
+	for _, tt := range tests {
+		html, err := mustNew(t, tt.config).Render(tt.content)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(html, want) {
+				t.Errorf("%s: %q does not contain %q", tt.name, html, want)
+			}
+		}
+		for _, notWant := range tt.notWant {
+			if strings.Contains(html, notWant) {
+				t.Errorf("%s: %q contains %q", tt.name, html, notWant)
+			}
+		}
+	}
+}
+
+func mustNew(t *testing.T, c config.Render) *Renderer {
+	t.Helper()
+	r, err := New(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestKey(t *testing.T) {
+	c := config.Default().Render
+	if mustNew(t, c).Key() != mustNew(t, c).Key() {
+		t.Fatal("the same configuration has different keys")
+	}
+	c.HardWraps = true
+	if mustNew(t, c).Key() == mustNew(t, config.Default().Render).Key() {
+		t.Fatal("a different configuration has the same key")
+	}
+}
+
+func TestUnknownExtension(t *testing.T) {
+	c := config.Default().Render
+	c.Extensions = []string{"table", "mermaid"}
+	_, err := New(c)
+	if err == nil || !strings.Contains(err.Error(), `"mermaid"`) {
+		t.Fatalf("err = %v, want the unknown extension named", err)
+	}
+}
@@ -0,0 +1,255 @@
+package search
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// fold maps a rune to the form it is indexed and matched in: lower case,
+// with full-width ASCII, common in Japanese text, made half-width.
+func fold(r rune) rune {
+	if r >= '！' && r <= '～' {
+		r -= '！' - '!'
+	}
+	return unicode.ToLower(r)
+}
+
+// unspaced reports whether r belongs to a script written without spaces
+// between words.
+func unspaced(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) || r == 'ー' || r == '々'
+}
+
+// Tokens splits text into the terms the index keys on. Runs of letters and
+// digits are taken whole in scripts that put spaces between words. Runs in
+// scripts that do not, such as Japanese, are cut into overlapping bigrams,
+// so a search finds words nobody marked the boundaries of.
+func Tokens(text string) []string {
+	var tokens []string
+	var run []rune
+	bigrams := false
+	flush := func() {
+		switch {
+		case len(run) == 0:
+		case !bigrams || len(run) == 1:
+			tokens = append(tokens, string(run))
+		default:
+			for i := 0; i+1 < len(run); i++ {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+		run = run[:0]
+	}
+	for _, r := range text {
+		r = fold(r)
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && !unspaced(r) {
+			flush()
+			continue
+		}
+		if len(run) > 0 && unspaced(r) != bigrams {
+			flush()
+		}
+		bigrams = unspaced(r)
+		run = append(run, r)
+	}
+	flush()
+	return tokens
+}
+
+// Match is a document found by Index.Search.
+type Match struct {
+	ID    string
+	Score float64
+}
+
+// titleWeight is how many times more a term counts in a title than in a
+// body.
+const titleWeight = 3
+
+// Index is an inverted index over documents with a title and a body. It is
+// safe for concurrent use.
+type Index struct {
+	mu sync.RWMutex
+	// postings maps each term to the weighted number of times it occurs
+	// in each document, and terms each document to its terms
+	postings map[string]map[string]float64
+	terms    map[string][]string
+}
+
+func NewIndex() *Index {
+	return &Index{postings: map[string]map[string]float64{}, terms: map[string][]string{}}
+}
+
+// Put indexes a document, replacing what was indexed under id before.
+func (ix *Index) Put(id, title, body string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+	counts := map[string]float64{}
+	for _, token := range Tokens(title) {
+		counts[token] += titleWeight
+	}
+	for _, token := range Tokens(body) {
+		counts[token]++
+	}
+	terms := make([]string, 0, len(counts))
+	for term, count := range counts {
+		if ix.postings[term] == nil {
+			ix.postings[term] = map[string]float64{}
+		}
+		ix.postings[term][id] = count
+		terms = append(terms, term)
+	}
+	ix.terms[id] = terms
+}
+
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.remove(id)
+}
+
+func (ix *Index) remove(id string) {
+	for _, term := range ix.terms[id] {
+		delete(ix.postings[term], id)
+		if len(ix.postings[term]) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+	delete(ix.terms, id)
+}
+
+// Search returns the documents holding every term of query, best first.
+// A document scores the sum over the terms of their count in it, damped,
+// times how rare they are across documents.
+func (ix *Index) Search(query string) []Match {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	terms := Tokens(query)
+	if len(terms) == 0 {
+		return nil
+	}
+	scores := map[string]float64{}
+	for i, term := range terms {
+		postings := ix.postingsOf(term)
+		idf := math.Log(1 + float64(len(ix.terms))/float64(len(postings)+1))
+		next := map[string]float64{}
+		for id, count := range postings {
+			if _, ok := scores[id]; ok || i == 0 {
+				next[id] = scores[id] + (1+math.Log(count))*idf
+			}
+		}
+		scores = next
+	}
+	matches := make([]Match, 0, len(scores))
+	for id, score := range scores {
+		matches = append(matches, Match{ID: id, Score: score})
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches
+}
+
+// postingsOf returns the postings of a query term. A lone character of a
+// script indexed in bigrams only has postings of its own where it stood
+// alone, so it also takes those of the bigrams it starts or ends; a
+// document counts it as often as it begins bigrams or ends them,
+// whichever is more.
+func (ix *Index) postingsOf(term string) map[string]float64 {
+	runes := []rune(term)
+	if len(runes) != 1 || !unspaced(runes[0]) {
+		return ix.postings[term]
+	}
+	starts, ends := map[string]float64{}, map[string]float64{}
+	for indexed, postings := range ix.postings {
+		r := []rune(indexed)
+		for id, count := range postings {
+			if indexed == term || len(r) == 2 && r[0] == runes[0] {
+				starts[id] += count
+			}
+			if indexed == term || len(r) == 2 && r[1] == runes[0] {
+				ends[id] += count
+			}
+		}
+	}
+	for id, count := range ends {
+		if count > starts[id] {
+			starts[id] = count
+		}
+	}
+	return starts
+}
+
+// Highlight cuts a snippet of about length runes out of text around the
+// first place a word of query appears, and marks every such word in it
+// with <mark>. The snippet is HTML, with text escaped.
+func Highlight(text, query string, length int) string {
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	folded := make([]rune, len(runes))
+	for i, r := range runes {
+		folded[i] = fold(r)
+	}
+	var words [][]rune
+	for _, word := range strings.Fields(query) {
+		w := make([]rune, 0, len(word))
+		for _, r := range word {
+			w = append(w, fold(r))
+		}
+		words = append(words, w)
+	}
+	// at reports the length of the longest word found at i, if any
+	at := func(i int) int {
+		longest := 0
+		for _, w := range words {
+			if len(w) > longest && i+len(w) <= len(folded) && string(folded[i:i+len(w)]) == string(w) {
+				longest = len(w)
+			}
+		}
+		return longest
+	}
+
+	start := 0
+	for i := range folded {
+		if at(i) > 0 {
+			start = i - length/4
+			break
+		}
+	}
+	if start < 0 || len(runes) <= length {
+		start = 0
+	}
+	end := start + length
+	if end > len(runes) {
+		end = len(runes)
+		if start = end - length; start < 0 {
+			start = 0
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; {
+		if n := at(i); n > 0 {
+			b.WriteString("<mark>" + html.EscapeString(string(runes[i:i+n])) + "</mark>")
+			i += n
+			continue
+		}
+		b.WriteString(html.EscapeString(string(runes[i])))
+		i++
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
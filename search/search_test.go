@@ -0,0 +1,81 @@
+package search
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hello, World!", []string{"hello", "world"}},
+		{"東京都に行く", []string{"東京", "京都", "都に", "に行", "行く"}},
+		{"Go言語で書く", []string{"go", "言語", "語で", "で書", "書く"}},
+		{"ＧＯ１２３ と ラーメン", []string{"go123", "と", "ラー", "ーメ", "メン"}},
+		{"東", []string{"東"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := Tokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokens(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	ix := NewIndex()
+	ix.Put("a", "東京の天気", "今日は晴れ")
+	ix.Put("b", "京都", "東京から京都へ行った")
+	ix.Put("c", "weather", "sunny in Tokyo")
+
+	ids := func(query string) []string {
+		var ids []string
+		for _, match := range ix.Search(query) {
+			ids = append(ids, match.ID)
+		}
+		return ids
+	}
+	if got := ids("東京"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("東京 finds %v, want the title match first", got)
+	}
+	if got := ids("京都 行った"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("京都 行った finds %v", got)
+	}
+	if got := ids("都"); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("都 finds %v, want the single character matched inside words", got)
+	}
+	if got := ids("東 天"); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Fatalf("東 天 finds %v", got)
+	}
+	if got := ids("TOKYO sunny"); !reflect.DeepEqual(got, []string{"c"}) {
+		t.Fatalf("TOKYO sunny finds %v", got)
+	}
+	ix.Put("c", "weather", "rainy")
+	ix.Remove("b")
+	if got := ids("tokyo"); got != nil {
+		t.Fatalf("tokyo finds %v after the update", got)
+	}
+	if got := ids("京都"); got != nil {
+		t.Fatalf("京都 finds %v after the removal", got)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	tests := []struct {
+		text, query string
+		length      int
+		want        string
+	}{
+		{"Go is <fun>", "GO", 20, "<mark>Go</mark> is &lt;fun&gt;"},
+		{"ラーメンとうどん", "うどん", 20, "ラーメンと<mark>うどん</mark>"},
+		{"0123456789 needle 0123456789", "needle", 12, "…89 <mark>needle</mark> 01…"},
+		{"no match\nhere at all", "x", 8, "no match…"},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.text, tt.query, tt.length); got != tt.want {
+			t.Errorf("Highlight(%q, %q) = %q, want %q", tt.text, tt.query, got, tt.want)
+		}
+	}
+}
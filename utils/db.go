@@ -2,121 +2,49 @@ package utils
 
 import (
 	"database/sql"
-	"errors"
-	"log"
-	"os"
 	"regexp"
 
 	_ "github.com/go-sql-driver/mysql"
+	"github.com/n-inja/go-blog/config"
 )
 
-var DB *sql.DB = nil
-var RegexProjectName *regexp.Regexp
+var RegexProjectName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
 
-func init() {
-	address := ""
-	if os.Getenv("DATABASE_ADDRESS") != "" {
-		address = os.Getenv("DATABASE_ADDRESS")
-	}
-	userName := os.Getenv("DATABASE_USERNAME")
-	password := os.Getenv("DATABASE_PASSWORD")
-	databaseName := os.Getenv("DATABASE_NAME")
-
-	var err error
-	DB, err = sql.Open("mysql", userName+":"+password+"@tcp("+address+")/"+databaseName)
-	if err != nil {
-		log.Fatal(err)
-	}
-	DB.SetMaxIdleConns(0)
-	RegexProjectName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
-
-	err = initDB()
-	if err != nil {
-		log.Fatal(err)
-	}
+func OpenDB(c config.Database) (*sql.DB, error) {
+	return open(c, c.Username+":"+c.Password+"@tcp("+c.Address+")/"+c.Name)
 }
 
-func Close() {
-	DB.Close()
-}
-
-func initDB() error {
-	rows, err := DB.Query("show tables like 'users'")
-	if err != nil {
-		return err
-	}
-	if !rows.Next() {
-		rows.Close()
-		return errors.New("users table not found")
-	}
-	rows.Close()
-
-	rows, err = DB.Query("show tables like 'projects'")
-	if err != nil {
-		return err
-	}
-	if !rows.Next() {
-		_, err = DB.Exec("create table projects (id varchar(20) NOT NULL PRIMARY KEY, name varchar(32) NOT NULL UNIQUE, display_name varchar(64) unicode NOT NULL, user_id varchar(32) NOT NULL, description text unicode NULL, foreign key(user_id) references users(id)) engine=innodb")
-		if err != nil {
-			return err
-		}
-	}
-	rows.Close()
-
-	rows, err = DB.Query("show tables like 'member'")
-	if err != nil {
-		return err
-	}
-	if !rows.Next() {
-		_, err = DB.Exec("create table member (user_id varchar(32) NOT NULL, project_id varchar(20) NOT NULL, PRIMARY KEY(user_id, project_id), index(user_id), index(project_id), foreign key(user_id) references users(id)) engine=innodb")
-		if err != nil {
-			return err
-		}
-	}
-	rows.Close()
-
-	rows, err = DB.Query("show tables like 'posts'")
-	if err != nil {
-		return err
-	}
-	if !rows.Next() {
-		_, err = DB.Exec("create table posts (id varchar(20) NOT NULL PRIMARY KEY, title text unicode NOT NULL, content longtext unicode NOT NULL, thumb_src varchar(64) NULL, user_id varchar(32) NOT NULL, number int NOT NULL, created_at timestamp NOT NULL default current_timestamp, updated_at timestamp NOT NULL default current_timestamp on update current_timestamp, project_id varchar(20) NOT NULL, views int NOT NULL, is_deleted boolean NOT NULL, index(user_id), index(created_at), index(is_deleted), index(project_id), unique(project_id, number), foreign key(user_id) references users(id)) engine=innodb")
-		if err != nil {
-			return err
-		}
-	}
-	rows.Close()
-
-	rows, err = DB.Query("show tables like 'comments'")
-	if err != nil {
-		return err
-	}
-	if !rows.Next() {
-		_, err = DB.Exec("create table comments (id varchar(20) NOT NULL PRIMARY KEY, content text unicode NOT NULL, user_id varchar(32) NOT NULL, post_id varchar(20) NOT NULL, created_at timestamp NOT NULL, is_deleted boolean NOT NULL, index(user_id), index(created_at), index(is_deleted), index(post_id), foreign key(post_id) references posts(id), foreign key(user_id) references users(id)) engine=innodb")
+// OpenReplicas opens one pool per configured replica DSN, sized like the primary.
+func OpenReplicas(c config.Database) ([]*sql.DB, error) {
+	replicas := make([]*sql.DB, 0, len(c.Replicas))
+	for _, dsn := range c.Replicas {
+		db, err := open(c, dsn)
 		if err != nil {
-			return err
+			for _, replica := range replicas {
+				replica.Close()
+			}
+			return nil, err
 		}
+		replicas = append(replicas, db)
 	}
-	rows.Close()
+	return replicas, nil
+}
 
-	rows, err = DB.Query("show tables like 'profiles'")
+func open(c config.Database, dsn string) (*sql.DB, error) {
+	db, err := sql.Open("mysql", dsn)
 	if err != nil {
-		return err
-	}
-	if !rows.Next() {
-		_, err = DB.Exec("create table profiles (id varchar(32) NOT NULL PRIMARY KEY, description text unicode NULL, twitter_id varchar(32) NULL, github_id varchar(64) NULL, icon_src varchar(64) NULL, foreign key(id) references users(id)) engine=innodb")
-		if err != nil {
-			return err
-		}
+		return nil, err
 	}
-	rows.Close()
+	db.SetMaxOpenConns(c.MaxOpenConns)
+	db.SetMaxIdleConns(c.MaxIdleConns)
+	db.SetConnMaxLifetime(c.ConnMaxLifetime)
 
-	return nil
+	return db, nil
 }
 
-func Transact(txFunc func(*sql.Tx) error) (err error) {
+func Transact(db *sql.DB, txFunc func(*sql.Tx) error) (err error) {
 
-	tx, err := DB.Begin()
+	tx, err := db.Begin()
 	if err != nil {
 		return
 	}
@@ -133,42 +61,3 @@ func Transact(txFunc func(*sql.Tx) error) (err error) {
 	err = txFunc(tx)
 	return err
 }
-
-func HasAuth(ID string) bool {
-
-	var auth string
-	err := DB.QueryRow("select auth from users where id = ?", ID).Scan(&auth)
-	if err != nil {
-		return false
-	}
-	if auth == "default" {
-		go checkProfile(ID)
-		return true
-	} else {
-		return false
-	}
-}
-
-func HasCommentAuth(ID string) bool {
-
-	rows, err := DB.Query("select id from users where id = ?", ID)
-	if err != nil {
-		return false
-	}
-	defer rows.Close()
-	return rows.Next()
-}
-
-func checkProfile(ID string) {
-
-	rows, err := DB.Query("select id from profiles where id = ?", ID)
-	if err != nil {
-		return
-	}
-	defer rows.Close()
-	if !rows.Next() {
-		rows.Close()
-		DB.Query("insert into profiles (id) value(?)", ID)
-		return
-	}
-}
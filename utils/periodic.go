@@ -0,0 +1,25 @@
+package utils
+
+import (
+	"context"
+	"time"
+)
+
+// Every runs pass right away and then every interval until ctx is
+// cancelled. It returns at once when interval is not positive, which turns
+// the job off.
+func Every(ctx context.Context, interval time.Duration, pass func()) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		pass()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}